@@ -1,9 +1,13 @@
 package vcs
 
 import (
-	"os"
+	"fmt"
+	"io"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dvln/out"
 	"github.com/dvln/util/dir"
@@ -12,6 +16,24 @@ import (
 
 var bzrDetectURL = regexp.MustCompile("parent branch: (?P<foo>.+)\n")
 var defaultBzrSchemes []string
+var bzrUserRegex = regexp.MustCompile(`^(.*?)\s*<([^>]*)>$`)
+
+// bzrErrPatterns classifies failed bzr cmds by their output (see CmdError)
+var bzrErrPatterns = []errPattern{
+	{ErrAuth, regexp.MustCompile(`(?i)permission denied|unable to authenticate|authentication|401 unauthorized|403 forbidden`)},
+	{ErrLocked, regexp.MustCompile(`(?i)could not acquire lock|lockcontention|unable to obtain lock`)},
+	{ErrCorrupt, regexp.MustCompile(`(?i)corrupt|checksum mismatch|inconsistent delta`)},
+	{ErrDirtyTree, regexp.MustCompile(`(?i)uncommitted changes`)},
+	{ErrMergeConflict, regexp.MustCompile(`(?i)conflicts? encountered|text conflict|conflict adding`)},
+	{ErrUnknownRev, regexp.MustCompile(`(?i)requested revision: .* does not exist|no such revision|no namespace registered for string|tag .* not found|revision.* not present`)},
+	{ErrUnreachable, regexp.MustCompile(`(?i)connection error|unable to connect|name or service not known|connection refused|timed out|invalid http response`)},
+}
+
+// bzrLogSeparator is the line 'bzr log --long' puts before each revision
+const bzrLogSeparator = "------------------------------------------------------------"
+
+// bzrTimeFormat is the timestamp layout used by 'bzr log' output
+const bzrTimeFormat = "Mon 2006-01-02 15:04:05 -0700"
 
 func init() {
 	SetDefaultBzrSchemes(nil)
@@ -23,9 +45,9 @@ func BzrGet(g *BzrGetter, rev ...Rev) (Resulter, error) {
 	var err error
 	var result *Result
 	if rev == nil || (rev != nil && rev[0] == "") {
-		result, err = run(bzrTool, "branch", g.Remote(), g.LocalRepoPath())
+		result, err = run(g.Context(), bzrTool, "branch", g.Remote(), g.LocalRepoPath())
 	} else {
-		result, err = run(bzrTool, "branch", "-r", string(rev[0]), g.Remote(), g.LocalRepoPath())
+		result, err = run(g.Context(), bzrTool, "branch", "-r", string(rev[0]), g.Remote(), g.LocalRepoPath())
 	}
 	results.add(result)
 	return results, err
@@ -34,16 +56,16 @@ func BzrGet(g *BzrGetter, rev ...Rev) (Resulter, error) {
 // BzrUpdate performs a Bzr pull and update to an existing checkout.
 func BzrUpdate(u *BzrUpdater, rev ...Rev) (Resulter, error) {
 	results := newResults()
-	result, err := runFromLocalRepoDir(u.LocalRepoPath(), bzrTool, "pull")
+	result, err := runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), bzrTool, "pull")
 	results.add(result)
 	if err != nil {
 		return results, err
 	}
 	var updResult *Result
 	if rev == nil || (rev != nil && rev[0] == "") {
-		updResult, err = runFromLocalRepoDir(u.LocalRepoPath(), bzrTool, "update")
+		updResult, err = runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), bzrTool, "update")
 	} else {
-		updResult, err = runFromLocalRepoDir(u.LocalRepoPath(), bzrTool, "update", "-r", string(rev[0]))
+		updResult, err = runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), bzrTool, "update", "-r", string(rev[0]))
 	}
 	results.add(updResult)
 	return results, err
@@ -56,7 +78,7 @@ func BzrUpdate(u *BzrUpdater, rev ...Rev) (Resulter, error) {
 // error is returned from the bzr update run.
 func BzrRevSet(r RevSetter, rev Rev) (Resulter, error) {
 	results := newResults()
-	result, err := runFromLocalRepoDir(r.LocalRepoPath(), bzrTool, "update", "-r", string(rev))
+	result, err := runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), bzrTool, "update", "-r", string(rev))
 	results.add(result)
 	return results, err
 }
@@ -65,59 +87,538 @@ func BzrRevSet(r RevSetter, rev Rev) (Resulter, error) {
 // pointer is returned (how filled out depends upon if the read is just the
 // basic core/raw VCS revision or full data for the given VCS which will
 // include tags, branches, timestamp info, author/committer, date, comment).
-// Note: this reads one version but that could be expanded to take <N>
-// revisions or a range, eg BzrRevRead(reader, <scope>, rev1, "..", rev2),
-// without changing this methods params or return signature (but code
-// changes  would be needed)
+// A range of revisions may also be given, eg: BzrRevRead(reader, <scope>,
+// rev1, "..", rev2), which is the same as BzrRevLog(reader, <scope>, rev1,
+// rev2, 0) (see that routine for details).
 func BzrRevRead(r RevReader, scope ReadScope, vcsRev ...Rev) ([]Revisioner, Resulter, error) {
+	if from, to, ok := revRange(vcsRev); ok {
+		return BzrRevLog(r, scope, from, to, 0)
+	}
 	results := newResults()
 	specificRev := ""
 	if vcsRev != nil && vcsRev[0] != "" {
 		specificRev = string(vcsRev[0])
 	}
-	oldDir, err := os.Getwd()
-	if err != nil {
-		return nil, nil, err
-	}
-	err = os.Chdir(r.LocalRepoPath())
-	if err != nil {
-		return nil, nil, err
-	}
-	defer os.Chdir(oldDir)
-
 	rev := &Revision{}
 	var revs []Revisioner
 	var result *Result
+	var err error
 	if scope == CoreRev {
 		// client just wants the core/base VCS revision only..
 		if specificRev != "" {
-			result, err = run(bzrTool, "revno", "-r", specificRev)
+			result, err = runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), bzrTool, "revno", "-r", specificRev)
 		} else {
-			result, err = run(bzrTool, "revno", "--tree")
+			result, err = runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), bzrTool, "revno", "--tree")
 		}
 		results.add(result)
 		if err != nil {
 			return nil, results, err
 		}
-		rev.SetCore(Rev(strings.TrimSpace(string(result.Output))))
+		rev.SetCore(Rev(strings.TrimSpace(string(result.Stdout))))
 		revs = append(revs, rev)
 	} else {
-		//FIXME: get additional data about the version if possible (fix this)
-		if specificRev != "" {
-			result, err = run(bzrTool, "revno", "-r", specificRev)
-		} else {
-			result, err = run(bzrTool, "revno", "--tree")
+		// client wants all the data we can get on the revision, if no
+		// revision given use the working tree revno (may not be the tip)
+		if specificRev == "" {
+			result, err = runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), bzrTool, "revno", "--tree")
+			results.add(result)
+			if err != nil {
+				return nil, results, err
+			}
+			specificRev = strings.TrimSpace(result.Stdout)
 		}
+		result, err = runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), bzrTool, "log", "--long", "--show-ids", "--levels=1", "--timezone=original", "-r", specificRev)
 		results.add(result)
 		if err != nil {
 			return nil, results, err
 		}
-		rev.SetCore(Rev(strings.TrimSpace(result.Output)))
-		revs = append(revs, rev)
+		revs, err = bzrParseRevs(result.Stdout, r.SemVerPrefix())
+		if err != nil {
+			return nil, results, err
+		}
 	}
 	return revs, results, err
 }
 
+// BzrRevLog retrieves the mainline revisions after the 'from' rev up to and
+// including the 'to' rev in topological order, newest first (ie: bzr log
+// -r from..to without the 'from' rev).  Params:
+//	r (Describer): describes the local branch to read the history from
+//	scope (ReadScope): CoreRev for just the revnos, AllData for full revisions
+//	from (Rev): start of the range (excluded), "" to walk all history
+//	to (Rev): end of the range (included), "" for the working tree revno
+//	max (int): max number of revisions to return, 0 for no limit
+//	paths (...string): optional; only revisions changing these paths (relative
+//	                   to the branch root) are returned
+// Returns the revisions, results (vcs cmds run, output) and any error
+func BzrRevLog(r Describer, scope ReadScope, from, to Rev, max int, paths ...string) ([]Revisioner, Resulter, error) {
+	results := newResults()
+	var result *Result
+	var err error
+	if to == "" {
+		result, err = run(r.Context(), bzrTool, "revno", "--tree", r.LocalRepoPath())
+		results.add(result)
+		if err != nil {
+			return nil, results, err
+		}
+		to = Rev(strings.TrimSpace(result.Stdout))
+	}
+	// the 'from' rev is excluded, resolve it so it can be dropped from the log
+	fromRevno := ""
+	start := "1"
+	if from != "" {
+		result, err = run(r.Context(), bzrTool, "revno", "-r", string(from), r.LocalRepoPath())
+		results.add(result)
+		if err != nil {
+			return nil, results, err
+		}
+		fromRevno = strings.TrimSpace(result.Stdout)
+		start = fromRevno
+	}
+	limitOpt := ""
+	if max > 0 {
+		limit := max
+		if fromRevno != "" {
+			limit++ // one extra as the 'from' rev itself is dropped
+		}
+		limitOpt = fmt.Sprintf("--limit=%d", limit)
+	}
+	args := []string{"log", "--long", "--show-ids", "--levels=1", "--timezone=original", "-r", fmt.Sprintf("%s..%s", start, to), limitOpt}
+	if paths == nil {
+		args = append(args, r.LocalRepoPath())
+	}
+	for _, path := range paths {
+		args = append(args, filepath.Join(r.LocalRepoPath(), path))
+	}
+	result, err = run(r.Context(), bzrTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	allRevs, err := bzrParseRevs(result.Stdout, r.SemVerPrefix())
+	if err != nil {
+		return nil, results, err
+	}
+	var revs []Revisioner
+	for _, rev := range allRevs {
+		if string(rev.Core()) == fromRevno {
+			continue
+		}
+		if scope == CoreRev {
+			coreRev := &Revision{}
+			coreRev.SetCore(rev.Core())
+			rev = coreRev
+		}
+		revs = append(revs, rev)
+	}
+	if max > 0 && len(revs) > max {
+		revs = revs[:max]
+	}
+	return revs, results, nil
+}
+
+// bzrParseRevs takes 'bzr log --long --show-ids' output and turns each
+// revision found into a fully populated Revision (dotted revno as the core
+// rev, the revision id as a ref version, committer/author, timestamp,
+// message, tags and semvers (split by semver prefix) and the branch nick as
+// the branch).  Revisions are returned in the order bzr listed them along
+// with any parse error.
+func bzrParseRevs(output, semVerPrefix string) ([]Revisioner, error) {
+	var revs []Revisioner
+	for _, entry := range strings.Split(output, bzrLogSeparator+"\n") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		rev := &Revision{}
+		authorSeen := false
+		inMessage := false
+		var message []string
+		for _, line := range strings.Split(strings.TrimRight(entry, "\n"), "\n") {
+			if inMessage {
+				message = append(message, strings.TrimPrefix(line, "  "))
+				continue
+			}
+			parts := strings.SplitN(line, ": ", 2)
+			value := ""
+			if len(parts) == 2 {
+				value = strings.TrimSpace(parts[1])
+			}
+			switch parts[0] {
+			case "revno":
+				// eg: "revno: 3 [merge]", just the dotted revno is wanted
+				if fields := strings.Fields(value); len(fields) != 0 {
+					rev.SetCore(Rev(fields[0]))
+				}
+			case "revision-id":
+				rev.SetRefVers([]Rev{Rev(value)})
+			case "committer":
+				name, id := bzrParseUser(value)
+				rev.SetUserInfo(Committer, name, id)
+				if !authorSeen {
+					rev.SetUserInfo(Author, name, id)
+				}
+			case "author", "authors":
+				// multiple authors are comma separated, use the first
+				name, id := bzrParseUser(strings.SplitN(value, ", ", 2)[0])
+				rev.SetUserInfo(Author, name, id)
+				authorSeen = true
+			case "branch nick":
+				rev.SetBranches([]Rev{Rev(value)})
+			case "tags":
+				var allTags []Rev
+				for _, tag := range strings.Split(value, ", ") {
+					allTags = append(allTags, Rev(tag))
+				}
+				semVers, tags := splitSemVers(semVerPrefix, allTags)
+				rev.SetSemVers(semVers)
+				rev.SetTags(tags)
+			case "timestamp":
+				tstamp, err := time.Parse(bzrTimeFormat, value)
+				if err != nil {
+					return nil, out.WrapErrf(err, 4524, "Unable to parse bzr timestamp: \"%s\"", value)
+				}
+				rev.SetTStamp(AuthComm, &tstamp)
+			case "message:":
+				inMessage = true
+			}
+		}
+		if rev.Core() == "" {
+			return nil, out.NewErrf(4523, "Unable to parse bzr revision data, no revno found:\n%s", entry)
+		}
+		rev.SetComment(strings.Join(message, "\n"))
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// bzrParseUser splits a bzr user string, eg: "Jane Doe <jane@example.com>",
+// into the name and the id (email) portions (in that order)
+func bzrParseUser(user string) (string, string) {
+	if m := bzrUserRegex.FindStringSubmatch(user); m != nil {
+		return m[1], m[2]
+	}
+	return user, ""
+}
+
+// BzrCommit adds any unknown files for the given paths (or the whole tree if
+// no paths given) and commits the changes (missing files are removed) with
+// the comment, author and committer identity and timestamp from the given
+// revision.  Bzr has a single commit time, the committer timestamp is used
+// if set, otherwise the author timestamp (the bzr config and current time
+// are used for any not set).  Params:
+//	c (Describer): describes the local branch to commit in
+//	rev (*Revision): the comment (required), user info and timestamp to use
+//	paths (...string): optional; only commit these paths (relative to the branch)
+// Returns the new revision, results (vcs cmds run, output) and any error
+func BzrCommit(c Describer, rev *Revision, paths ...string) (Revisioner, Resulter, error) {
+	results := newResults()
+	if err := checkCommitRev(c, rev); err != nil {
+		return nil, results, err
+	}
+	runDir := c.LocalRepoPath()
+	result, err := runFromLocalRepoDir(c.Context(), runDir, bzrTool, append([]string{"add", "-q"}, paths...)...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	args := []string{"commit", "-q", "-m", rev.Comment()}
+	if author := commitUser(rev, Author); author != "" {
+		args = append(args, "--author", author)
+	}
+	tstamp := rev.TStamp(Committer)
+	if tstamp == nil {
+		tstamp = rev.TStamp(Author)
+	}
+	if tstamp != nil {
+		args = append(args, "--commit-time", tstamp.Format("2006-01-02 15:04:05 -0700"))
+	}
+	var env []string
+	if committer := commitUser(rev, Committer); committer != "" {
+		env = []string{"BZR_EMAIL=" + committer}
+	}
+	result, err = runInDir(c.Context(), runDir, env, bzrTool, append(args, paths...)...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	revs, logResults, err := BzrRevLog(c, AllData, "", "", 1)
+	for _, logResult := range logResults.All() {
+		results.add(logResult)
+	}
+	if err != nil || len(revs) == 0 {
+		return nil, results, err
+	}
+	return revs[0], results, nil
+}
+
+// BzrDiff returns the parsed diff between two revisions, or a revision and
+// the working tree, see the Differ interface for details.  Params:
+//	r (Describer): describes the local branch to diff
+//	from (Rev): the old revision, "" for the working tree basis (last) revision
+//	to (Rev): the new revision, "" for the working tree
+//	paths (...string): optional; only diff these paths (relative to the branch)
+// Returns the file diffs, results (vcs cmds run, output) and any error
+func BzrDiff(r Describer, from, to Rev, paths ...string) ([]*FileDiff, Resulter, error) {
+	results := newResults()
+	args := []string{"diff"}
+	if to != "" {
+		if from == "" {
+			from = "-1"
+		}
+		args = append(args, "-r", string(from)+".."+string(to))
+	} else if from != "" {
+		args = append(args, "-r", string(from))
+	}
+	args = append(args, paths...)
+	result, err := runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), bzrTool, args...)
+	results.add(result)
+	// bzr diff exits 1 if there are changes, 2 if some can't be shown
+	if err != nil && result.ExitCode != 1 && result.ExitCode != 2 {
+		return nil, results, err
+	}
+	files, err := parseDiff(result.Stdout)
+	return files, results, err
+}
+
+// bzrMissingRegex matches the extra (ahead) and missing (behind) revision
+// counts in 'bzr missing' output
+var bzrMissingRegex = regexp.MustCompile(`You (have|are missing) (\d+) (extra )?revision`)
+
+// BzrStatus reads the status of the working tree: the files with local
+// changes, unknown files and text conflicts, along with the number of local
+// revisions not in the remote branch (Ahead) and the number of remote
+// revisions not yet pulled (Behind).  The remote branch is the RemoteRepoName
+// location, or the parent branch if that is "", it is contacted to get the
+// counts.  Params:
+//	s (Describer): describes the local branch to get the status of
+// Returns the status, results (vcs cmds run, output) and any error
+func BzrStatus(s Describer) (*Status, Resulter, error) {
+	results := newResults()
+	result, err := runFromLocalRepoDir(s.Context(), s.LocalRepoPath(), bzrTool, "status", "--short")
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	conflictsResult, err := runFromLocalRepoDir(s.Context(), s.LocalRepoPath(), bzrTool, "conflicts", "--text")
+	results.add(conflictsResult)
+	if err != nil {
+		return nil, results, err
+	}
+	status := bzrParseStatus(result.Stdout, conflictsResult.Stdout)
+
+	// missing exits 1 if the branches have diverged in either direction
+	result, err = runFromLocalRepoDir(s.Context(), s.LocalRepoPath(), bzrTool, "missing", "--line", s.RemoteRepoName())
+	results.add(result)
+	if err != nil && result.ExitCode != 1 {
+		return nil, results, err
+	}
+	for _, m := range bzrMissingRegex.FindAllStringSubmatch(result.Stdout, -1) {
+		count, _ := strconv.Atoi(m[2])
+		if m[1] == "have" {
+			status.Ahead = count
+		} else {
+			status.Behind = count
+		}
+	}
+	return status, results, nil
+}
+
+// bzrParseStatus parses 'bzr status --short' output, with the text conflict
+// paths from 'bzr conflicts --text' output, into the working tree status
+func bzrParseStatus(output, conflictsOutput string) *Status {
+	status := &Status{}
+	for _, line := range strings.Split(output, "\n") {
+		if len(line) < 5 {
+			continue
+		}
+		path := strings.TrimRight(line[4:], "/*@")
+		origPath := ""
+		if parts := strings.SplitN(path, " => ", 2); len(parts) == 2 {
+			origPath, path = strings.TrimRight(parts[0], "/*@"), parts[1]
+		}
+		var state FileState
+		switch {
+		case line[0] == 'C' || line[0] == 'P': // conflicts (see below), pending merges
+			continue
+		case line[0] == '?':
+			state = FileUntracked
+		case line[0] == 'R':
+			state = FileRenamed
+		case line[0] == '+' || line[1] == 'N':
+			state = FileAdded
+		case line[0] == '-' || line[1] == 'D' || line[1] == '!':
+			state = FileDeleted
+		case line[1] == 'M' || line[1] == 'K' || line[2] == '*':
+			state = FileModified
+		default:
+			continue
+		}
+		status.Files = append(status.Files, FileStatus{Path: path, OrigPath: origPath, State: state})
+	}
+	for _, line := range strings.Split(conflictsOutput, "\n") {
+		if path := strings.TrimSpace(line); path != "" {
+			status.addFile(path, "", FileConflicted)
+		}
+	}
+	return status
+}
+
+// bzrPushedRegex matches the new revno in 'bzr push' output
+var bzrPushedRegex = regexp.MustCompile(`Pushed up to revision (\d+)`)
+
+// BzrPush publishes the local branch revisions and tags to the remote
+// branch (the options RemoteName or RemoteRepoName location, or the Remote
+// if neither is set).  A bzr push is for the whole branch so the refs are
+// not used and a single status (Local ".") is returned, force overwrites
+// the remote branch if it has diverged, there is no force-with-lease
+// support.  Params:
+//	p (Describer): describes the local branch to push from
+//	opts (*PushOptions): how to push, nil for the bzr defaults
+// Returns the branch status, results (vcs cmds run, output) and any error
+func BzrPush(p Describer, opts *PushOptions) ([]*RefPushStatus, Resulter, error) {
+	results := newResults()
+	if opts == nil {
+		opts = &PushOptions{}
+	}
+	if opts.ForceWithLease {
+		return nil, results, out.WrapErr(ErrNotImplemented, "Bzr push has no force-with-lease support", 4544)
+	}
+	location := pushRemoteName(p, opts)
+	if location == "" {
+		location = p.Remote()
+	}
+	args := []string{"push"}
+	if opts.Force {
+		args = append(args, "--overwrite")
+	}
+	result, err := runFromLocalRepoDir(p.Context(), p.LocalRepoPath(), bzrTool, append(args, location)...)
+	results.add(result)
+	return []*RefPushStatus{bzrParsePush(result.Output, location, err != nil)}, results, err
+}
+
+// bzrParsePush parses 'bzr push' output into the status of the branch
+func bzrParsePush(output, location string, failed bool) *RefPushStatus {
+	status := &RefPushStatus{Local: ".", Remote: location, State: PushUpdated}
+	if m := bzrPushedRegex.FindStringSubmatch(output); m != nil {
+		status.NewRev = Rev(m[1])
+	}
+	switch {
+	case failed:
+		status.State = PushRejected
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "bzr: ERROR: ") {
+				status.Reason = strings.TrimSpace(strings.TrimPrefix(line, "bzr: ERROR: "))
+				break
+			}
+		}
+	case strings.Contains(output, "No new revisions"):
+		status.State = PushUpToDate
+	case strings.Contains(output, "Created new branch"):
+		status.State = PushNew
+	}
+	return status
+}
+
+// BzrNested is not supported, bzr has no nested branch support to speak of
+// (nested trees never left experimental), ErrNotImplemented is returned
+func BzrNested(r Describer) ([]*NestedRepo, Resulter, error) {
+	return nil, newResults(), out.WrapErr(ErrNotImplemented, "Bzr has no nested repo support", 4558)
+}
+
+// BzrExport writes an archive of the given revision ("" for the last
+// revision) of the local branch to the writer, see Exporter.  Params:
+//	r (RevReader): describes the local branch to export from
+//	w (io.Writer): where the archive is written
+//	rev (Rev): the revision to export, "" for the last revision
+//	opts (*ExportOptions): archive format, subdir and prefix (nil for a tar)
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func BzrExport(r RevReader, w io.Writer, rev Rev, opts *ExportOptions) (Resulter, error) {
+	return exportArchive(r, w, rev, opts, bzrExportTree)
+}
+
+// bzrExportTree exports the files of a revision into the dir via 'bzr
+// export', only the subdir if one is given
+func bzrExportTree(d Describer, dir string, rev Rev, subdir string) (string, Resulter, error) {
+	results := newResults()
+	result, err := runFromLocalRepoDir(d.Context(), d.LocalRepoPath(), bzrTool, "export", "-q", "--format=dir", "-r", string(rev), dir, filepath.FromSlash(subdir))
+	results.add(result)
+	if err != nil {
+		return "", results, err
+	}
+	return dir, results, nil
+}
+
+// BzrRefs lists the branch (by its nick, a bzr branch is the whole location)
+// and the tags of the local branch or of the remote branch, with the revno
+// each targets (or "revid:<id>" for tags not in the branch history).
+// Params:
+//	r (Describer): describes the local branch and remote
+//	l (Location): LocalPath for the local branch refs, Remote for the remote refs
+// Returns the refs, results (vcs cmds run, output) and any error
+func BzrRefs(r Describer, l Location) ([]*Ref, Resulter, error) {
+	results := newResults()
+	location := r.LocalRepoPath()
+	nick := filepath.Base(location)
+	if l == LocalPath {
+		result, err := runFromLocalRepoDir(r.Context(), location, bzrTool, "nick")
+		results.add(result)
+		if err != nil {
+			return nil, results, err
+		}
+		nick = strings.TrimSpace(result.Stdout)
+	} else {
+		location = r.Remote()
+		nick = filepath.Base(strings.TrimRight(location, "/"))
+	}
+	result, err := run(r.Context(), bzrTool, "revno", location)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	refs := []*Ref{{Name: nick, Type: RefBranch, Rev: Rev(strings.TrimSpace(result.Stdout))}}
+	result, err = run(r.Context(), bzrTool, "tags", "-d", location)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	tags := bzrParseTags(result.Stdout)
+	for _, tag := range tags {
+		if tag.Rev == "?" { // not in the branch history, use the revids
+			result, err = run(r.Context(), bzrTool, "tags", "--show-ids", "-d", location)
+			results.add(result)
+			if err != nil {
+				return nil, results, err
+			}
+			revIDs := make(map[string]Rev)
+			for _, idTag := range bzrParseTags(result.Stdout) {
+				revIDs[idTag.Name] = "revid:" + idTag.Rev
+			}
+			for _, tag := range tags {
+				if tag.Rev == "?" {
+					tag.Rev = revIDs[tag.Name]
+				}
+			}
+			break
+		}
+	}
+	return append(refs, tags...), results, nil
+}
+
+// bzrParseTags parses 'bzr tags' output ("<name>   <revno or revid>" lines)
+// into tag refs
+func bzrParseTags(output string) []*Ref {
+	var refs []*Ref
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		i := strings.LastIndexAny(line, " \t")
+		if i < 0 {
+			continue
+		}
+		refs = append(refs, &Ref{Name: strings.TrimSpace(line[:i]), Type: RefTag, Rev: Rev(line[i+1:])})
+	}
+	return refs
+}
+
 // BzrExists verifies the local repo or remote location is of the Bzr repo type,
 // returns where it was found ("" if not found) and any error.  If it does not
 // exist a wrapped ErrNoExist error is returned (use out.IsError() to check)
@@ -136,7 +637,7 @@ func BzrExists(e Existence, l Location) (string, Resulter, error) {
 		// if we have a scheme then just see if the repo exists...
 		if scheme != "" {
 			var result *Result
-			result, err = run(bzrTool, "info", remote)
+			result, err = run(e.Context(), bzrTool, "info", remote)
 			results.add(result)
 			if err == nil {
 				path = remote
@@ -145,7 +646,7 @@ func BzrExists(e Existence, l Location) (string, Resulter, error) {
 			vcsSchemes := e.Schemes()
 			for _, scheme = range vcsSchemes {
 				var result *Result
-				result, err = run(bzrTool, "info", scheme+"://"+remote)
+				result, err = run(e.Context(), bzrTool, "info", scheme+"://"+remote)
 				results.add(result)
 				if err == nil {
 					path = scheme + "://" + remote
@@ -183,21 +684,12 @@ func BzrCheckRemote(e Existence, remote string) (string, Resulter, error) {
 				results.add(existResult)
 			}
 		}
-		oldDir, err := os.Getwd()
-		if err != nil {
-			return remote, nil, err
-		}
-		err = os.Chdir(e.LocalRepoPath())
-		if err != nil {
-			return remote, nil, err
-		}
-		defer os.Chdir(oldDir)
-		result, err := run(bzrTool, "info")
+		result, err := runFromLocalRepoDir(e.Context(), e.LocalRepoPath(), bzrTool, "info")
 		results.add(result)
 		if err != nil {
 			return remote, results, err
 		}
-		outStr = string(result.Output)
+		outStr = result.Stdout
 		m := bzrDetectURL.FindStringSubmatch(outStr)
 
 		// If no remote was passed in but one is configured for the locally
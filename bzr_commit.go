@@ -0,0 +1,38 @@
+package vcs
+
+// BzrCommitter implements the ChangeCommitter interface for the
+// Bzr source control.
+type BzrCommitter struct {
+	Description
+}
+
+// NewBzrCommitter creates a new instance of BzrCommitter. The remote and
+// local directories need to be passed in.
+func NewBzrCommitter(remote, localPath string) (*BzrCommitter, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Bzr. Need to report an error.
+	if err == nil && ltype != Bzr {
+		return nil, ErrWrongVCS
+	}
+	c := &BzrCommitter{}
+	c.setDescription(remote, "", localPath, defaultBzrSchemes, Bzr)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		c.setRemoteCheck(func() (string, Resulter, error) {
+			return BzrCheckRemote(c, remote)
+		})
+		if _, err = c.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Commit support for bzr committer
+func (c *BzrCommitter) Commit(rev *Revision, paths ...string) (Revisioner, Resulter, error) {
+	return BzrCommit(c, rev, paths...)
+}
+
+// Exists support for bzr committer
+func (c *BzrCommitter) Exists(l Location) (string, Resulter, error) {
+	return BzrExists(c, l)
+}
@@ -18,11 +18,12 @@ func NewBzrGetter(remote, remoteName, localPath string, mirror bool) (Getter, er
 	g.mirror = mirror
 	g.setDescription(remote, "", localPath, defaultBzrSchemes, Bzr)
 	if err == nil { // Have a localPath FS repo, try to improve the remote..
-		remote, _, err = BzrCheckRemote(g, remote)
-		if err != nil {
+		g.setRemoteCheck(func() (string, Resulter, error) {
+			return BzrCheckRemote(g, remote)
+		})
+		if _, err = g.Check(); err != nil {
 			return nil, err
 		}
-		g.setRemote(remote)
 	}
 	return g, nil
 }
@@ -0,0 +1,38 @@
+package vcs
+
+// BzrPusher implements the Pusher interface for the Bzr source control.
+type BzrPusher struct {
+	Description
+}
+
+// NewBzrPusher creates a new instance of BzrPusher. The remote and local
+// directories need to be passed in, the remote name is the bzr location to
+// push to ("" to push to the remote).
+func NewBzrPusher(remote, remoteName, localPath string) (*BzrPusher, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Bzr. Need to report an error.
+	if err == nil && ltype != Bzr {
+		return nil, ErrWrongVCS
+	}
+	p := &BzrPusher{}
+	p.setDescription(remote, remoteName, localPath, defaultBzrSchemes, Bzr)
+	if err == nil { // Have a localPath FS repo, try to improve the remote..
+		p.setRemoteCheck(func() (string, Resulter, error) {
+			return BzrCheckRemote(p, remote)
+		})
+		if _, err = p.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Push support for bzr pusher
+func (p *BzrPusher) Push(opts *PushOptions) ([]*RefPushStatus, Resulter, error) {
+	return BzrPush(p, opts)
+}
+
+// Exists support for bzr pusher
+func (p *BzrPusher) Exists(l Location) (string, Resulter, error) {
+	return BzrExists(p, l)
+}
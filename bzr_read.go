@@ -1,5 +1,7 @@
 package vcs
 
+import "io"
+
 // BzrReader implements the Repo interface for the Bzr source control.
 type BzrReader struct {
 	Description
@@ -16,11 +18,12 @@ func NewBzrReader(remote, localPath string) (*BzrReader, error) {
 	r := &BzrReader{}
 	r.setDescription(remote, "", localPath, defaultBzrSchemes, Bzr)
 	if err == nil { // Have a localPath FS repo, try to improve the remote..
-		remote, _, err = BzrCheckRemote(r, remote)
-		if err != nil {
+		r.setRemoteCheck(func() (string, Resulter, error) {
+			return BzrCheckRemote(r, remote)
+		})
+		if _, err = r.Check(); err != nil {
 			return nil, err
 		}
-		r.setRemote(remote)
 	}
 	return r, nil
 }
@@ -35,6 +38,31 @@ func (r *BzrReader) RevRead(scope ReadScope, vcsRev ...Rev) ([]Revisioner, Resul
 	return BzrRevRead(r, scope, vcsRev...)
 }
 
+// RevLog support for bzr reader
+func (r *BzrReader) RevLog(scope ReadScope, from, to Rev, max int, paths ...string) ([]Revisioner, Resulter, error) {
+	return BzrRevLog(r, scope, from, to, max, paths...)
+}
+
+// Diff support for bzr reader
+func (r *BzrReader) Diff(from, to Rev, paths ...string) ([]*FileDiff, Resulter, error) {
+	return BzrDiff(r, from, to, paths...)
+}
+
+// Nested support for bzr reader
+func (r *BzrReader) Nested() ([]*NestedRepo, Resulter, error) {
+	return BzrNested(r)
+}
+
+// Export support for bzr reader
+func (r *BzrReader) Export(w io.Writer, rev Rev, opts *ExportOptions) (Resulter, error) {
+	return BzrExport(r, w, rev, opts)
+}
+
+// Refs support for bzr reader
+func (r *BzrReader) Refs(l Location) ([]*Ref, Resulter, error) {
+	return BzrRefs(r, l)
+}
+
 // Exists support for bzr reader
 func (r *BzrReader) Exists(l Location) (string, Resulter, error) {
 	return BzrExists(r, l)
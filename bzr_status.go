@@ -0,0 +1,40 @@
+package vcs
+
+// BzrStatusReader implements the StatusReader interface for the Bzr source
+// control.
+type BzrStatusReader struct {
+	Description
+}
+
+// NewBzrStatusReader creates a new instance of BzrStatusReader. The remote
+// and local directories need to be passed in, the remote name is the bzr
+// location to compare with for the ahead/behind counts ("" for the parent
+// branch).
+func NewBzrStatusReader(remote, remoteName, localPath string) (*BzrStatusReader, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Bzr. Need to report an error.
+	if err == nil && ltype != Bzr {
+		return nil, ErrWrongVCS
+	}
+	s := &BzrStatusReader{}
+	s.setDescription(remote, remoteName, localPath, defaultBzrSchemes, Bzr)
+	if err == nil { // Have a localPath FS repo, try to improve the remote..
+		s.setRemoteCheck(func() (string, Resulter, error) {
+			return BzrCheckRemote(s, remote)
+		})
+		if _, err = s.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Status support for bzr status reader
+func (s *BzrStatusReader) Status() (*Status, Resulter, error) {
+	return BzrStatus(s)
+}
+
+// Exists support for bzr status reader
+func (s *BzrStatusReader) Exists(l Location) (string, Resulter, error) {
+	return BzrExists(s, l)
+}
@@ -1,16 +1,18 @@
 package vcs
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
 )
 
 // Canary test to ensure BzrReader implements the Reader interface.
 var _ Reader = &BzrReader{}
 
-// To verify bzr is working we perform intergration testing
-// with a known bzr service.
+// To verify bzr is working we perform integration testing
+// against a local fixture repo (see fixture_test.go).
 
 func TestBzr(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "go-vcs-bzr-tests")
@@ -23,8 +25,11 @@ func TestBzr(t *testing.T) {
 			t.Error(err)
 		}
 	}()
+	fixture := newFixture(t, Bzr, tempDir)
+	secondRev := fixture.revs["second"]
+	tipRev := fixture.revs["tip"]
 
-	bzrGetter, err := NewBzrGetter("https://launchpad.net/dvlnbzrtest", "", tempDir+"/govcstestbzrrepo", false)
+	bzrGetter, err := NewBzrGetter(fixture.remote, "", tempDir+"/govcstestbzrrepo", false)
 	if err != nil {
 		t.Fatalf("Unable to instantiate new Bzr VCS reader, Err: %s", err)
 	}
@@ -34,7 +39,7 @@ func TestBzr(t *testing.T) {
 	}
 
 	// Check the basic getters.
-	if bzrGetter.Remote() != "https://launchpad.net/dvlnbzrtest" {
+	if bzrGetter.Remote() != fixture.remote {
 		t.Error("Remote not set properly")
 	}
 	if bzrGetter.LocalRepoPath() != tempDir+"/govcstestbzrrepo" {
@@ -67,7 +72,7 @@ func TestBzr(t *testing.T) {
 
 	// Test NewReader on existing checkout. This should simply provide a working
 	// instance without error based on looking at the local directory.
-	bzrReader, err := NewReader("https://launchpad.net/dvlnbzrtest", tempDir+"/govcstestbzrrepo")
+	bzrReader, err := NewReader(fixture.remote, tempDir+"/govcstestbzrrepo")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -80,15 +85,15 @@ func TestBzr(t *testing.T) {
 		t.Error("Don't see the new bzr repo in the workspace")
 	}
 
-	results, err := bzrReader.RevSet("2")
+	results, err := bzrReader.RevSet(secondRev)
 	if err != nil {
 		t.Errorf("Unable to update Bzr repo version. Err was %s, results were:\n%s", err, results)
 	}
 
 	// Use Version to verify we are on the right version.
 	v, _, err := bzrReader.RevRead(CoreRev)
-	if string(v[0].Core()) != "2" {
-		t.Error("Error checking checked out Bzr version")
+	if v[0].Core() != secondRev {
+		t.Errorf("Error checking checked out Bzr version, expected \"%s\", found: %s", secondRev, v[0].Core())
 	}
 	if err != nil {
 		t.Error(err)
@@ -96,7 +101,7 @@ func TestBzr(t *testing.T) {
 
 	// Perform an update.
 	mirror := true
-	bzrUpdater, err := NewUpdater("https://launchpad.net/dvlnbzrtest", "", tempDir+"/govcstestbzrrepo", !mirror, RebaseFalse, nil)
+	bzrUpdater, err := NewUpdater(fixture.remote, "", tempDir+"/govcstestbzrrepo", !mirror, RebaseFalse, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -106,12 +111,24 @@ func TestBzr(t *testing.T) {
 	}
 
 	v, _, err = bzrReader.RevRead(CoreRev)
-	if string(v[0].Core()) != "3" {
-		t.Errorf("Error, unexpected Bzr version read, wanted \"3\", received: %s", string(v[0].Core()))
+	if v[0].Core() != tipRev {
+		t.Errorf("Error, unexpected Bzr version read, wanted \"%s\", received: %s", tipRev, v[0].Core())
 	}
 	if err != nil {
 		t.Error(err)
 	}
+
+	// Read the full data for the current revision
+	v, _, err = bzrReader.RevRead(AllData)
+	if err != nil {
+		t.Fatalf("Unable to read full Bzr revision data, err: %s", err)
+	}
+	if v[0].Core() != tipRev || len(v[0].RefVers()) != 1 {
+		t.Errorf("Error reading full Bzr revision, found revno: %s, revids: %v", v[0].Core(), v[0].RefVers())
+	}
+	if name, _ := v[0].UserInfo(Committer); name == "" || v[0].TStamp(Committer) == nil || v[0].Comment() == "" {
+		t.Error("Full Bzr revision read did not populate the committer, timestamp or message")
+	}
 }
 
 func TestBzrExists(t *testing.T) {
@@ -144,9 +161,30 @@ func TestBzrExists(t *testing.T) {
 		t.Fatal(nrerr)
 	}
 
-	// Try remote Bzr existence checks via a Getter
+	// Try remote Bzr existence checks via a Getter on a local fixture repo
+	fixture := newFixture(t, Bzr, tempDir)
+	bzrGetter, err := NewBzrGetter(fixture.url, "", tempDir+"/govcstestbzrrepo", false)
+	if err != nil {
+		t.Fatalf("Failed to initialize new Bzr getter, error: %s", err)
+	}
+	path, _, err = bzrGetter.Exists(Remote)
+	if err != nil || path != fixture.url {
+		t.Fatalf("Failed to find remote repo that should exist (URL: %s), found: %s, error: %s", fixture.url, path, err)
+	}
+	badurl := fixture.url + "-notexist"
+	bzrGetter, err = NewBzrGetter(badurl, "", tempDir+"/govcstestbzrrepo", false)
+	if err != nil {
+		t.Fatalf("Failed to initialize \"bad\" Bzr getter, init should work, error: %s", err)
+	}
+	path, _, err = bzrGetter.Exists(Remote)
+	if err == nil || path != "" {
+		t.Fatalf("Failed to detect an error scanning for bad VCS location (loc: %s), found path: %s", badurl, path)
+	}
+
+	// Remote scheme detection needs a real remote host
+	skipUnlessNetwork(t)
 	url1 := "launchpad.net/dvlnbzrtest"
-	bzrGetter, err := NewBzrGetter(url1, "", tempDir, false)
+	bzrGetter, err = NewBzrGetter(url1, "", tempDir, false)
 	if err != nil {
 		t.Fatalf("Failed to initialize new Bzr getter, error: %s", err)
 	}
@@ -189,3 +227,67 @@ func TestBzrExists(t *testing.T) {
 		t.Fatalf("Unexpectedly found a repo when shouldn't have (URL: %s), found path: %s", badurl1, err)
 	}
 }
+
+// TestBzrParseRevs verifies full revision data parsing of bzr log output
+func TestBzrParseRevs(t *testing.T) {
+	output := `------------------------------------------------------------
+revno: 12.1.3 [merge]
+revision-id: jane@example.com-20150101170000-0123456789abcdef
+parent: john@example.com-20141231120000-fedcba9876543210
+tags: v1.2.0, stable
+author: Jane Doe <jane@example.com>
+committer: John Doe <john@example.com>
+branch nick: trunk
+timestamp: Thu 2015-01-01 12:00:00 -0500
+message:
+  Merge the feature
+
+  With a longer description
+`
+	revs, err := bzrParseRevs(output, "")
+	if err != nil {
+		t.Fatalf("Failed to parse bzr revision data, err: %s", err)
+	}
+	if len(revs) != 1 {
+		t.Fatalf("Expected 1 parsed bzr revision, found: %d", len(revs))
+	}
+	rev := revs[0]
+	if rev.Core() != "12.1.3" || fmt.Sprint(rev.RefVers()) != "[jane@example.com-20150101170000-0123456789abcdef]" {
+		t.Errorf("Parsed bzr revno/revid incorrect, found: %s / %v", rev.Core(), rev.RefVers())
+	}
+	if name, id := rev.UserInfo(Author); name != "Jane Doe" || id != "jane@example.com" {
+		t.Errorf("Parsed bzr author incorrect, found: %s <%s>", name, id)
+	}
+	if name, id := rev.UserInfo(Committer); name != "John Doe" || id != "john@example.com" {
+		t.Errorf("Parsed bzr committer incorrect, found: %s <%s>", name, id)
+	}
+	if tstamp := rev.TStamp(Committer); tstamp == nil || tstamp.Unix() != 1420131600 {
+		t.Errorf("Parsed bzr timestamp incorrect, found: %v", tstamp)
+	}
+	if fmt.Sprint(rev.SemVers()) != "[v1.2.0]" || fmt.Sprint(rev.Tags()) != "[stable]" {
+		t.Errorf("Parsed bzr semvers/tags incorrect, found: %v / %v", rev.SemVers(), rev.Tags())
+	}
+	if fmt.Sprint(rev.Branches()) != "[trunk]" {
+		t.Errorf("Parsed bzr branch incorrect, found: %v", rev.Branches())
+	}
+	if rev.Comment() != "Merge the feature\n\nWith a longer description" {
+		t.Errorf("Parsed bzr comment incorrect, found: %q", rev.Comment())
+	}
+}
+
+// TestParallelBzrGetUpd runs several Bzr get/update/read cycles at the same
+// time to look for race issues (run with: go test -race)
+func TestParallelBzrGetUpd(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-bzr-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Bzr, tempDir)
+	var wg sync.WaitGroup
+	wg.Add(4)
+	for i := 1; i <= 4; i++ {
+		go runGetUpdRead(t, &wg, fixture.remote, Bzr)
+	}
+	wg.Wait()
+}
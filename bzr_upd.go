@@ -33,11 +33,12 @@ func NewBzrUpdater(remote, remoteName, localPath string, mirror bool, rebase Reb
 	}
 	u.setDescription(remote, remoteName, localPath, defaultBzrSchemes, Bzr)
 	if err == nil { // Have a localPath FS repo, try to improve the remote..
-		remote, _, err = BzrCheckRemote(u, remote)
-		if err != nil {
+		u.setRemoteCheck(func() (string, Resulter, error) {
+			return BzrCheckRemote(u, remote)
+		})
+		if _, err = u.Check(); err != nil {
 			return nil, err
 		}
-		u.setRemote(remote)
 	}
 	return u, nil
 }
@@ -0,0 +1,159 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// Classes of VCS cmd failures, a failed cmd returns a *CmdError and if the
+// failure could be classified errors.Is(err, <class>) is true for one of
+// these, eg: errors.Is(err, ErrAuth)
+var (
+	// ErrAuth indicates authentication or authorization with the remote failed
+	ErrAuth = errors.New("VCS authentication failed")
+
+	// ErrUnreachable indicates the remote host could not be reached
+	ErrUnreachable = errors.New("VCS remote host unreachable")
+
+	// ErrUnknownRev indicates a revision, branch, tag or ref could not be found
+	ErrUnknownRev = errors.New("VCS revision unknown")
+
+	// ErrMergeConflict indicates a merge, rebase or update hit conflicts
+	ErrMergeConflict = errors.New("VCS merge conflict")
+
+	// ErrDirtyTree indicates local modifications blocked the operation
+	ErrDirtyTree = errors.New("VCS working tree has local modifications")
+
+	// ErrLocked indicates the repo or working copy is locked by another process
+	ErrLocked = errors.New("VCS repository locked")
+
+	// ErrCorrupt indicates the repo or working copy data is damaged
+	ErrCorrupt = errors.New("VCS repository corrupt")
+)
+
+// errPattern maps VCS cmd output matching the regex to a failure class
+type errPattern struct {
+	class error
+	regex *regexp.Regexp
+}
+
+// cLocaleEnv is added to the env of git and bzr cmds so their messages are
+// never translated as the failure classes are found from the English wording
+// (hg cmds are run with HGPLAIN, svn failures are classified by error code)
+var cLocaleEnv = []string{"LC_ALL=C", "LANGUAGE="}
+
+// errPatterns has the failure classification patterns for each VCS, the
+// first pattern that matches the cmd output wins (so order matters)
+var errPatterns = map[Type][]errPattern{
+	Git: gitErrPatterns,
+	Hg:  hgErrPatterns,
+	Svn: svnErrPatterns,
+	Bzr: bzrErrPatterns,
+}
+
+// CmdError is the error returned when a VCS cmd fails (other than for a
+// timeout or cancel, see ErrTimeout and ErrCanceled).  Use errors.Is() to
+// check the class of failure (eg: ErrAuth) and errors.As() to get at the
+// details, eg: the cmd result or the underlying *exec.ExitError
+type CmdError struct {
+	// Class is the failure class (eg: ErrAuth), nil if unclassified
+	Class error
+
+	// Vcs is the VCS type of the tool run, NoVCS if not known
+	Vcs Type
+
+	// Result has the cmd run, its output, exit code, etc
+	Result *Result
+
+	// Err is the underlying error from running the cmd
+	Err error
+}
+
+// Error implements the error interface for the *CmdError type
+func (e *CmdError) Error() string {
+	if e.Class != nil {
+		return fmt.Sprintf("%s (cmd: %s): %v", e.Class, e.Result.Cmd, e.Err)
+	}
+	return fmt.Sprintf("VCS cmd failed (cmd: %s): %v", e.Result.Cmd, e.Err)
+}
+
+// Unwrap returns the underlying error from running the cmd
+func (e *CmdError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if the target is the failure class of this error
+func (e *CmdError) Is(target error) bool {
+	return e.Class != nil && e.Class == target
+}
+
+// Retryable returns true if the class of failure is one that may well go
+// away if the cmd is tried again later (ie: ErrUnreachable and ErrLocked)
+func (e *CmdError) Retryable() bool {
+	return e.Class == ErrUnreachable || e.Class == ErrLocked
+}
+
+// newCmdError creates a *CmdError for the failed cmd result, classifying
+// the failure based on the cmd output if the VCS tool run is known
+func newCmdError(result *Result, err error) *CmdError {
+	cmdErr := &CmdError{Result: result, Err: err}
+	if len(result.Args) != 0 {
+		cmdErr.Vcs = toolType(result.Args[0])
+	}
+	cmdErr.Class = classifyOutput(cmdErr.Vcs, result.Output)
+	return cmdErr
+}
+
+// classifyOutput returns the failure class for the given VCS cmd output,
+// nil if the output doesn't match any known failure for that VCS
+func classifyOutput(vcsType Type, output string) error {
+	for _, pattern := range errPatterns[vcsType] {
+		if pattern.regex.MatchString(output) {
+			return pattern.class
+		}
+	}
+	return nil
+}
+
+// toolType returns the VCS type for the given tool cmd (eg: "git" or
+// "/path/to/hg"), NoVCS if it isn't a known VCS tool
+func toolType(cmd string) Type {
+	mutex.Lock()
+	defer mutex.Unlock()
+	switch cmd {
+	case gitTool:
+		return Git
+	case hgTool:
+		return Hg
+	case svnTool:
+		return Svn
+	case bzrTool:
+		return Bzr
+	}
+	switch filepath.Base(cmd) {
+	case "git":
+		return Git
+	case "hg":
+		return Hg
+	case "svn", "svnversion", "svnadmin":
+		return Svn
+	case "bzr":
+		return Bzr
+	}
+	return NoVCS
+}
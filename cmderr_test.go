@@ -0,0 +1,86 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// TestClassifyOutput verifies failed cmd output is mapped to the right class
+func TestClassifyOutput(t *testing.T) {
+	tests := []struct {
+		vcsType Type
+		output  string
+		class   error
+	}{
+		{Git, "remote: Invalid username or password.\nfatal: Authentication failed for 'https://github.com/x/y/'\n", ErrAuth},
+		{Git, "git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.\n", ErrAuth},
+		{Git, "fatal: unable to access 'https://nohost.example/x/': Could not resolve host: nohost.example\n", ErrUnreachable},
+		{Git, "fatal: ambiguous argument 'nosuchrev': unknown revision or path not in the working tree.\n", ErrUnknownRev},
+		{Git, "fatal: couldn't find remote ref refs/heads/nosuchbranch\n", ErrUnknownRev},
+		{Git, "Auto-merging a.txt\nCONFLICT (content): Merge conflict in a.txt\nAutomatic merge failed; fix conflicts and then commit the result.\n", ErrMergeConflict},
+		{Git, "error: Your local changes to the following files would be overwritten by merge:\n\ta.txt\nPlease commit your changes or stash them before you merge.\nAborting\n", ErrDirtyTree},
+		{Git, "fatal: Unable to create '/x/.git/index.lock': File exists.\n\nAnother git process seems to be running in this repository\n", ErrLocked},
+		{Git, "error: object file .git/objects/3f/1a is empty\nfatal: loose object 3f1a (stored in .git/objects/3f/1a) is corrupt\n", ErrCorrupt},
+		{Git, "fatal: 'nosuchrepo' does not appear to be a git repository\nfatal: Could not read from remote repository.\n", nil},
+		{Git, "fatal: some new failure\n", nil},
+		{Hg, "abort: authorization failed\n", ErrAuth},
+		{Hg, "abort: error: Name or service not known\n", ErrUnreachable},
+		{Hg, "abort: unknown revision 'nosuchrev'!\n", ErrUnknownRev},
+		{Hg, "abort: no match found!\n", ErrUnknownRev},
+		{Hg, "abort: uncommitted changes\n", ErrDirtyTree},
+		{Hg, "waiting for lock on repository /x held by process '1234' on host 'h'\nabort: timeout while waiting for lock\n", ErrLocked},
+		{Svn, "svn: E170013: Unable to connect to a repository at URL 'https://x/y'\nsvn: E215004: No more credentials or we tried too many times.\nAuthentication failed\n", ErrAuth},
+		{Svn, "svn: E170013: Unable to connect to a repository at URL 'https://x/y'\nsvn: E670002: Name or service not known\n", ErrUnreachable},
+		{Svn, "svn: E160006: No such revision 99\n", ErrUnknownRev},
+		{Svn, "svn: E155004: Run 'svn cleanup' to remove locks (type 'svn help cleanup' for details)\n", ErrLocked},
+		{Bzr, "bzr: ERROR: Requested revision: '99' does not exist in branch: BzrBranch7(file:///x/)\n", ErrUnknownRev},
+		{Bzr, "bzr: ERROR: Could not acquire lock \"(local)\": /x/.bzr/checkout/lock\n", ErrLocked},
+		{Bzr, "bzr: ERROR: An inconsistent delta was supplied involving 'a.txt', 'a-id'\n", ErrCorrupt},
+		{Bzr, "bzr: ERROR: Inconsistent line endings setting for a.txt\n", nil},
+		{NoVCS, "fatal: Authentication failed\n", nil},
+	}
+	for _, test := range tests {
+		if class := classifyOutput(test.vcsType, test.output); class != test.class {
+			t.Errorf("Incorrect %s failure class for output %q, expected: %v, found: %v", test.vcsType, test.output, test.class, class)
+		}
+	}
+}
+
+// TestCmdError verifies failed cmds return a classified error that works
+// with errors.Is() and errors.As()
+func TestCmdError(t *testing.T) {
+	result := newResult()
+	result.Cmd = "git fetch origin"
+	result.Args = []string{"git", "fetch", "origin"}
+	result.Output = "fatal: unable to access 'https://x/y/': Failed to connect to x port 443: Connection refused\n"
+	var err error = newCmdError(result, errors.New("exit status 128"))
+	if !errors.Is(err, ErrUnreachable) || errors.Is(err, ErrAuth) {
+		t.Errorf("Failed git fetch not classified as unreachable, err: %s", err)
+	}
+	var cmdErr *CmdError
+	if !errors.As(err, &cmdErr) || cmdErr.Vcs != Git || cmdErr.Result != result || !cmdErr.Retryable() {
+		t.Errorf("Unable to get the details of the failed git fetch, err: %s", err)
+	}
+
+	// git and bzr messages are never translated, the failure classes are
+	// found from the English wording
+	t.Setenv("LANGUAGE", "de")
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	result, err = run(context.Background(), gitTool, "--version")
+	if err != nil || !reflect.DeepEqual(result.Env, cLocaleEnv) {
+		t.Errorf("Expected git to be run in the C locale, err: %v, env: %q", err, result.Env)
+	}
+	if result, _ = run(context.Background(), "sh", "-c", "true"); len(result.Env) != 0 {
+		t.Errorf("Expected no locale settings for non-VCS cmds, env: %q", result.Env)
+	}
+
+	// failures running a cmd directly give a *CmdError wrapping the exec error
+	_, err = run(context.Background(), "sh", "-c", "exit 2")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &cmdErr) || cmdErr.Class != nil || !errors.As(err, &exitErr) {
+		t.Errorf("Failed cmd run did not return an unclassified *CmdError, err: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dvln/out"
+)
+
+// ChangeCommitter records new revisions from the changes in a workspace (it
+// isn't named Committer as that is the committer UserType)
+type ChangeCommitter interface {
+	// Describer access to VCS system details (Remote, LocalRepoPath, ..)
+	Describer
+
+	// Commit stages the given paths (relative to the local repo path), or
+	// all changes (including new and removed files) if no paths are given,
+	// and commits them.  The revision given has the commit comment and
+	// optionally the author and committer identity (see SetUserInfo) and
+	// timestamps (see SetTStamp), the VCS defaults are used for any not
+	// set.  The new revision is returned as read back from the VCS.
+	Commit(*Revision, ...string) (Revisioner, Resulter, error)
+}
+
+// NewCommitter returns a VCS ChangeCommitter based on trying to detect the VCS sys
+// from the remote and local repo locations (the local repo must exist).
+// The appropriate implementation will be returned or an ErrCannotDetectVCS
+// if the VCS type cannot be detected.
+func NewCommitter(remote, localPath string, vcsType ...Type) (ChangeCommitter, error) {
+	vtype, remote, err := detectVCSType(remote, localPath, vcsType...)
+	if err != nil {
+		return nil, err
+	}
+	switch vtype {
+	case Git:
+		return NewGitCommitter(remote, localPath)
+	case Svn:
+		return NewSvnCommitter(remote, localPath)
+	case Hg:
+		return NewHgCommitter(remote, localPath)
+	case Bzr:
+		return NewBzrCommitter(remote, localPath)
+	}
+
+	// Should never fall through to here but just in case.
+	return nil, ErrCannotDetectVCS
+}
+
+// checkCommitRev verifies a revision to commit has a comment
+func checkCommitRev(c Describer, rev *Revision) error {
+	if rev == nil || strings.TrimSpace(rev.Comment()) == "" {
+		return out.NewErrf(4540, "%s commit requires a revision with a comment, clone: %s", c.Vcs(), c.LocalRepoPath())
+	}
+	return nil
+}
+
+// commitUser returns the user identity of the given type from the revision
+// as "name <id>" (or just the name or id if only one is set), "" if not set
+func commitUser(rev *Revision, utype UserType) string {
+	name, id := rev.UserInfo(utype)
+	switch {
+	case name != "" && id != "":
+		return fmt.Sprintf("%s <%s>", name, id)
+	case name != "":
+		return name
+	}
+	return id
+}
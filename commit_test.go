@@ -0,0 +1,88 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCommit verifies workspace changes can be committed for each VCS type
+// (skipping those with no tools installed)
+func TestCommit(t *testing.T) {
+	for _, vcsType := range []Type{Git, Hg, Svn, Bzr} {
+		vcsType := vcsType
+		t.Run(string(vcsType), func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "go-vcs-commit-tests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+			fixture := newFixture(t, vcsType, tempDir)
+			localPath := filepath.Join(tempDir, "VCSTestRepo")
+			fixture.checkout(localPath)
+			committer, err := NewCommitter(fixture.remote, localPath, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS committer, err: %s", vcsType, err)
+			}
+			committer.SetRunner(envRunner{fixtureEnv})
+			if _, _, err = committer.Commit(NewRevision()); err == nil {
+				t.Errorf("Expected an error committing a %s revision with no comment", vcsType)
+			}
+
+			// commit just one of the changed files
+			fixture.write(localPath, "README", "bumped")
+			fixture.write(localPath, "VERSION", "1.0.1")
+			fixture.write(localPath, "OTHER", "other")
+			rev := NewRevision()
+			rev.SetComment("bump version\n\nmore detail")
+			rev.SetUserInfo(Author, "Version Bot", "bot@example.com")
+			tstamp := time.Date(2016, 2, 3, 4, 5, 6, 0, time.FixedZone("", 3600))
+			rev.SetTStamp(AuthComm, &tstamp)
+			newRev, results, err := committer.Commit(rev, "README", "VERSION")
+			if err != nil {
+				t.Fatalf("Unable to commit %s changes, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if newRev.Core() == "" || newRev.Core() == fixture.revs["tip"] || newRev.Comment() != "bump version\n\nmore detail" {
+				t.Errorf("Incorrect new %s revision, core: %s, comment: %q", vcsType, newRev.Core(), newRev.Comment())
+			}
+			if vcsType != Svn { // svn takes the user and time from the repo
+				if name, id := newRev.UserInfo(Author); name != "Version Bot" || id != "bot@example.com" {
+					t.Errorf("Incorrect new %s revision author, found: %s <%s>", vcsType, name, id)
+				}
+				if newTStamp := newRev.TStamp(Author); newTStamp == nil || !newTStamp.Equal(tstamp) {
+					t.Errorf("Incorrect new %s revision timestamp, found: %v", vcsType, newTStamp)
+				}
+			}
+
+			reader, err := NewStatusReader(fixture.remote, "", localPath, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS status reader, err: %s", vcsType, err)
+			}
+			status, results, err := reader.Status()
+			if err != nil {
+				t.Fatalf("Unable to read %s workspace status, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if !sameFiles(status.Files, []FileStatus{{Path: "OTHER", State: FileUntracked}}) {
+				t.Errorf("Expected only the uncommitted %s file left, found: %+v", vcsType, status.Files)
+			}
+
+			// commit everything else, with only a committer name given
+			rev = NewRevision()
+			rev.SetComment("add other")
+			rev.SetUserInfo(Committer, "Release Bot", "")
+			if newRev, results, err = committer.Commit(rev); err != nil {
+				t.Fatalf("Unable to commit all %s changes, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if vcsType == Git { // the email is still taken from the config (env)
+				if name, id := newRev.UserInfo(Committer); name != "Release Bot" || id != "tester@example.com" {
+					t.Errorf("Incorrect new git revision committer, found: %s <%s>", name, id)
+				}
+			}
+			if status, results, err = reader.Status(); err != nil || status.Dirty() || status.Untracked() {
+				t.Errorf("Expected a clean %s workspace after commit, err: %v, status: %+v", vcsType, err, status)
+			}
+		})
+	}
+}
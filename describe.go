@@ -13,6 +13,10 @@
 
 package vcs
 
+import (
+	"context"
+)
+
 // Describer provides a small interface to get basic vcs definition data such
 // as where the VCS lives remotely and where it belongs locally, as well as
 // a method for determining the type of repo it is (if it is known yet)
@@ -39,6 +43,44 @@ type Describer interface {
 	// for the repo under "git://<remote>", "https://..", "http://.." and,
 	// finally, "git+ssh://..").  Only used if no scheme provided.
 	Schemes() []string
+
+	// Context retrieves the context VCS cmds are run with, if it is canceled
+	// or its deadline passes any running VCS cmd is killed (see SetContext)
+	Context() context.Context
+
+	// SetContext sets the context to run all following VCS cmds with, this
+	// allows one to cancel or put a deadline on ops (eg: Get, Update, Exists)
+	SetContext(context.Context)
+
+	// Runner retrieves the Runner set to run the VCS cmds of this op, nil
+	// if none is set (ie: the global Runner is used, see SetRunner func)
+	Runner() Runner
+
+	// SetRunner sets the Runner to run all following VCS cmds with for this
+	// op (eg: a RecordingRunner or ReplayRunner), nil uses the global Runner
+	SetRunner(Runner)
+
+	// Check checks the remote against the local repo again (the constructor
+	// already did), with the Runner and context set for the op, returning
+	// the results and any error (eg: ErrWrongRemote)
+	Check() (Resulter, error)
+
+	// SemVerPrefix retrieves the prefix semantic version tags have, eg: "v"
+	// or "pkg/v", the default "" matches both "1.2.3" and "v1.2.3" tags
+	SemVerPrefix() string
+
+	// SetSemVerPrefix sets the prefix a tag must have, followed by the
+	// semantic version (an optional "v" allowed), to be read as a semver
+	// (see Revision.SemVers() and ResolveSemVer())
+	SetSemVerPrefix(string)
+
+	// Recursive retrieves if nested repos (eg: git submodules) are brought
+	// in and updated along with the repo by Get and Update ops
+	Recursive() bool
+
+	// SetRecursive sets if Get and Update ops also init and update nested
+	// repos (see NestedLister for how to list them)
+	SetRecursive(bool)
 }
 
 // Description is a structure that satisfies the VCS Describer implementation, used
@@ -47,6 +89,11 @@ type Description struct {
 	localPath, remote, remoteRepoName string
 	schemes                           []string
 	vcsType                           Type
+	ctx                               context.Context
+	runner                            Runner
+	semVerPrefix                      string
+	recursive                         bool
+	remoteCheck                       func() (string, Resulter, error) // see Check
 }
 
 // Remote retrieves the remote location for a repo.
@@ -76,10 +123,94 @@ func (d *Description) Vcs() Type {
 	return d.vcsType
 }
 
+// Context retrieves the context VCS cmds are run with, if none has been
+// set a background context (no cancel, no deadline) is returned.  If a
+// Runner has been set the returned context carries it for the VCS cmds,
+// the local repo path is carried for the Runner as well.
+func (d *Description) Context() context.Context {
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if d.runner != nil {
+		ctx = withRunner(ctx, d.runner)
+	}
+	if d.localPath != "" {
+		ctx = withLocalRepoPath(ctx, d.localPath)
+	}
+	return ctx
+}
+
+// SetContext sets the context to run all following VCS cmds with, a nil
+// context means no cancel or deadline (the default)
+func (d *Description) SetContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+// Runner retrieves the Runner set for the VCS cmds, nil if none is set
+func (d *Description) Runner() Runner {
+	return d.runner
+}
+
+// SetRunner sets the Runner to run all following VCS cmds with, a nil
+// Runner means the global Runner is used (the default, see SetRunner func)
+func (d *Description) SetRunner(runner Runner) {
+	d.runner = runner
+}
+
+// SemVerPrefix retrieves the prefix semantic version tags have, "" (the
+// default) if the tag is just the version (with an optional "v")
+func (d *Description) SemVerPrefix() string {
+	return d.semVerPrefix
+}
+
+// SetSemVerPrefix sets the prefix semantic version tags have, eg: "pkg/"
+// for "pkg/v1.2.3" (or "pkg/1.2.3") tags, the tags without it are read
+// as regular tags
+func (d *Description) SetSemVerPrefix(prefix string) {
+	d.semVerPrefix = prefix
+}
+
+// Recursive retrieves if Get and Update ops bring in nested repos, false
+// (the default) if not
+func (d *Description) Recursive() bool {
+	return d.recursive
+}
+
+// SetRecursive sets if Get and Update ops bring in and update nested repos,
+// for git that's the submodules (recursively), hg subrepos and svn externals
+// are always brought in by hg and svn themselves
+func (d *Description) SetRecursive(recursive bool) {
+	d.recursive = recursive
+}
+
 func (d *Description) setRemote(remote string) {
 	d.remote = remote
 }
 
+// setRemoteCheck sets the check of the remote against the local repo (eg:
+// GitCheckRemote) run by the constructor, see Check
+func (d *Description) setRemoteCheck(check func() (string, Resulter, error)) {
+	d.remoteCheck = check
+}
+
+// Check checks the remote against the local repo, as the constructor did
+// when it found the local repo, but with the Runner and context now set
+// for the op (eg: to record or replay the check too).  The checked remote
+// is used from then on.  Nothing is run if there was no local repo.  The
+// results and any error (eg: ErrWrongRemote) are returned.
+func (d *Description) Check() (Resulter, error) {
+	if d.remoteCheck == nil {
+		return newResults(), nil
+	}
+	remote, results, err := d.remoteCheck()
+	if err != nil {
+		return results, err
+	}
+	d.remote = remote
+	return results, nil
+}
+
 func (d *Description) setRemoteRepoName(remRepoName string) {
 	d.remoteRepoName = remRepoName
 }
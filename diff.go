@@ -0,0 +1,306 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dvln/out"
+)
+
+// hunkRegex matches a unified diff hunk header, eg: "@@ -1,3 +1,4 @@ func x"
+var hunkRegex = regexp.MustCompile(`^@@ -([0-9]+)(?:,([0-9]+))? \+([0-9]+)(?:,([0-9]+))? @@ ?(.*)$`)
+
+// bzrFileRegex matches a bzr diff file header, eg: "=== modified file 'a'"
+var bzrFileRegex = regexp.MustCompile(`^=== (added|modified|removed|renamed) (file|directory|symlink) '(.*?)'(?: => '(.*?)')?`)
+
+// DiffOp is the type of a line in a diff hunk
+type DiffOp byte
+
+// Diff line types, the same as the unified diff line prefixes
+const (
+	DiffContext DiffOp = ' ' // unchanged line
+	DiffAdd     DiffOp = '+' // added line
+	DiffDelete  DiffOp = '-' // removed line
+)
+
+// DiffLine is a single line of a diff hunk
+type DiffLine struct {
+	// Op is the type of line: context, added or removed
+	Op DiffOp
+
+	// Text is the line content (without the newline)
+	Text string
+
+	// NoNewline is set if the line has no newline (end of file)
+	NoNewline bool
+}
+
+// Hunk is a single block of changes within a file diff
+type Hunk struct {
+	// OldStart and OldLines give the line range in the old file
+	OldStart, OldLines int
+
+	// NewStart and NewLines give the line range in the new file
+	NewStart, NewLines int
+
+	// Section is any text after the hunk range (eg: the enclosing func)
+	Section string
+
+	// Lines are the context, added and removed lines of the hunk
+	Lines []DiffLine
+}
+
+// FileDiff is the diff of a single file
+type FileDiff struct {
+	// OldPath is the path before the change, "" if the file was added
+	OldPath string
+
+	// NewPath is the path after the change, "" if the file was removed
+	NewPath string
+
+	// Binary is set if the file content isn't diffed (no hunks)
+	Binary bool
+
+	// Hunks has the changed blocks of the file
+	Hunks []*Hunk
+
+	// Added and Removed are the number of lines added and removed
+	Added, Removed int
+}
+
+// Differ compares revisions (or a revision and the working tree) of a
+// local repo, returning the parsed unified diff
+type Differ interface {
+	// Describer access to VCS system details (Remote, LocalRepoPath, ..)
+	Describer
+
+	// Diff returns the changes between the 'from' and 'to' revisions for
+	// all files, or just the given paths (relative to the local repo path).
+	// An empty 'from' is the revision checked out, an empty 'to' is the
+	// working tree, so Diff("", "") gives the uncommitted changes.
+	Diff(Rev, Rev, ...string) ([]*FileDiff, Resulter, error)
+}
+
+// NewDiffer returns a VCS Differ based on trying to detect the VCS sys from
+// the remote and local repo locations (the local repo must exist to diff).
+// The appropriate implementation will be returned or an ErrCannotDetectVCS
+// if the VCS type cannot be detected.
+func NewDiffer(remote, localPath string, vcsType ...Type) (Differ, error) {
+	vtype, remote, err := detectVCSType(remote, localPath, vcsType...)
+	if err != nil {
+		return nil, err
+	}
+	switch vtype {
+	case Git:
+		return NewGitReader(remote, localPath)
+	case Svn:
+		return NewSvnReader(remote, localPath)
+	case Hg:
+		return NewHgReader(remote, localPath)
+	case Bzr:
+		return NewBzrReader(remote, localPath)
+	}
+
+	// Should never fall through to here but just in case.
+	return nil, ErrCannotDetectVCS
+}
+
+// parseDiff parses unified diff output into the list of file diffs, it
+// handles the file header dialects of each VCS: git style ("diff --git",
+// also used for hg), svn ("Index: <path>") and bzr ("=== modified file")
+func parseDiff(output string) ([]*FileDiff, error) {
+	var files []*FileDiff
+	var file *FileDiff
+	var hunk *Hunk
+	oldLeft, newLeft := 0, 0 // lines left in the current hunk
+	gitStyle := false        // git style paths have a/ and b/ prefixes
+	skip := false            // skipping svn property changes
+	fixedPaths := false      // file header paths are final (bzr)
+	newFile := func() {
+		file = &FileDiff{}
+		files = append(files, file)
+		hunk = nil
+		skip = false
+		fixedPaths = false
+	}
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if hunk != nil && (oldLeft > 0 || newLeft > 0 || strings.HasPrefix(line, `\`)) {
+			var op DiffOp
+			if line != "" {
+				op = DiffOp(line[0])
+			}
+			switch {
+			case line == "" || op == DiffContext: // some tools drop the space on empty lines
+				oldLeft--
+				newLeft--
+				op = DiffContext
+			case op == DiffDelete:
+				oldLeft--
+				file.Removed++
+			case op == DiffAdd:
+				newLeft--
+				file.Added++
+			case op == '\\': // "\ No newline at end of file"
+				if len(hunk.Lines) != 0 {
+					hunk.Lines[len(hunk.Lines)-1].NoNewline = true
+				}
+				continue
+			default:
+				return nil, out.NewErrf(4539, "Unable to parse diff, unexpected hunk line: %q", line)
+			}
+			text := ""
+			if len(line) > 1 {
+				text = line[1:]
+			}
+			hunk.Lines = append(hunk.Lines, DiffLine{Op: op, Text: text})
+			continue
+		}
+		hunk = nil
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			newFile()
+			gitStyle = true
+			file.OldPath, file.NewPath = diffGitPaths(strings.TrimPrefix(line, "diff --git "))
+		case strings.HasPrefix(line, "Index: "):
+			newFile()
+			gitStyle = false
+			file.OldPath = strings.TrimPrefix(line, "Index: ")
+			file.NewPath = file.OldPath
+		case strings.HasPrefix(line, "=== "):
+			newFile()
+			gitStyle = false
+			m := bzrFileRegex.FindStringSubmatch(line)
+			if m == nil || m[2] == "directory" {
+				files = files[:len(files)-1] // directories have no content
+				file = nil
+				continue
+			}
+			file.OldPath, file.NewPath = m[3], m[3]
+			fixedPaths = true
+			switch m[1] {
+			case "added":
+				file.OldPath = ""
+			case "removed":
+				file.NewPath = ""
+			case "renamed":
+				file.NewPath = m[4]
+			}
+		case skip:
+			continue
+		case strings.HasPrefix(line, "Property changes on: "):
+			skip = true
+			if file != nil && len(file.Hunks) == 0 && !file.Binary && file.NewPath == strings.TrimPrefix(line, "Property changes on: ") {
+				files = files[:len(files)-1] // only properties changed
+				file = nil
+			}
+		case strings.HasPrefix(line, "--- ") && (file == nil || len(file.Hunks) != 0):
+			newFile() // plain unified diff with no file header
+			file.OldPath = diffPath(line[4:], gitStyle, "a/")
+			file.NewPath = file.OldPath
+		case file == nil || (fixedPaths && (strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ "))):
+			continue
+		case strings.HasPrefix(line, "--- "):
+			file.OldPath = diffPath(line[4:], gitStyle, "a/")
+		case strings.HasPrefix(line, "+++ "):
+			file.NewPath = diffPath(line[4:], gitStyle, "b/")
+		case strings.HasPrefix(line, "new file mode"):
+			file.OldPath = ""
+		case strings.HasPrefix(line, "deleted file mode"):
+			file.NewPath = ""
+		case strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "copy from "):
+			file.OldPath = diffUnquote(line[strings.Index(line, " from ")+6:])
+		case strings.HasPrefix(line, "rename to "), strings.HasPrefix(line, "copy to "):
+			file.NewPath = diffUnquote(line[strings.Index(line, " to ")+4:])
+		case strings.HasPrefix(line, "Binary files "), line == "GIT binary patch",
+			strings.HasPrefix(line, "Cannot display: file marked as a binary type"):
+			file.Binary = true
+		case strings.HasPrefix(line, "@@ "):
+			m := hunkRegex.FindStringSubmatch(line)
+			if m == nil {
+				return nil, out.NewErrf(4539, "Unable to parse diff, bad hunk header: %q", line)
+			}
+			hunk = &Hunk{Section: m[5]}
+			hunk.OldStart, _ = strconv.Atoi(m[1])
+			hunk.OldLines = 1
+			if m[2] != "" {
+				hunk.OldLines, _ = strconv.Atoi(m[2])
+			}
+			hunk.NewStart, _ = strconv.Atoi(m[3])
+			hunk.NewLines = 1
+			if m[4] != "" {
+				hunk.NewLines, _ = strconv.Atoi(m[4])
+			}
+			oldLeft, newLeft = hunk.OldLines, hunk.NewLines
+			file.Hunks = append(file.Hunks, hunk)
+		}
+	}
+	return files, nil
+}
+
+// diffPath returns the path from a "---" or "+++" diff line (dropping any
+// timestamp or revision after a tab and the git style a/ or b/ prefix), ""
+// if the file doesn't exist on that side of the diff
+func diffPath(path string, gitStyle bool, prefix string) string {
+	if i := strings.Index(path, "\t"); i >= 0 {
+		if strings.Contains(path[i:], "(nonexistent)") || strings.Contains(path[i:], "(revision 0)") {
+			return ""
+		}
+		path = path[:i]
+	}
+	path = diffUnquote(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	if gitStyle {
+		path = strings.TrimPrefix(path, prefix)
+	}
+	return path
+}
+
+// diffGitPaths returns the old and new paths from a "diff --git a/<old>
+// b/<new>" header, if the paths have spaces (and differ) they can't be
+// told apart here, the "---"/"+++" or rename lines will set them
+func diffGitPaths(paths string) (string, string) {
+	if strings.HasPrefix(paths, `"`) {
+		if oldPath, err := strconv.QuotedPrefix(paths); err == nil {
+			newPath := diffUnquote(strings.TrimSpace(paths[len(oldPath):]))
+			return strings.TrimPrefix(diffUnquote(oldPath), "a/"), strings.TrimPrefix(newPath, "b/")
+		}
+	}
+	if half := len(paths) / 2; len(paths)%2 == 1 && paths[half] == ' ' {
+		oldPath, newPath := paths[:half], paths[half+1:]
+		if strings.TrimPrefix(oldPath, "a/") == strings.TrimPrefix(newPath, "b/") {
+			return strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/")
+		}
+	}
+	if fields := strings.SplitN(paths, " b/", 2); len(fields) == 2 {
+		return strings.TrimPrefix(fields[0], "a/"), fields[1]
+	}
+	return paths, paths
+}
+
+// diffUnquote removes the C style quoting git uses for unusual paths
+func diffUnquote(path string) string {
+	if len(path) > 1 && strings.HasPrefix(path, `"`) && strings.HasSuffix(path, `"`) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			return unquoted
+		}
+	}
+	return path
+}
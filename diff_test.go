@@ -0,0 +1,141 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestDiff verifies revision and working tree diffs for each VCS type
+// (skipping those with no tools installed)
+func TestDiff(t *testing.T) {
+	for _, vcsType := range []Type{Git, Hg, Svn, Bzr} {
+		vcsType := vcsType
+		t.Run(string(vcsType), func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "go-vcs-diff-tests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+			fixture := newFixture(t, vcsType, tempDir)
+			localPath := filepath.Join(tempDir, "VCSTestRepo")
+			fixture.checkout(localPath)
+			differ, err := NewDiffer(fixture.remote, localPath, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS differ, err: %s", vcsType, err)
+			}
+
+			files, results, err := differ.Diff("", "")
+			if err != nil || len(files) != 0 {
+				t.Errorf("Expected no %s working tree changes, err: %v, files: %+v, results:\n%s", vcsType, err, files, results)
+			}
+			files, results, err = differ.Diff(fixture.revs["first"], fixture.revs["second"], "README")
+			if err != nil {
+				t.Fatalf("Unable to diff %s revisions, err: %s, results:\n%s", vcsType, err, results)
+			}
+			expected := []DiffLine{{Op: DiffDelete, Text: "first"}, {Op: DiffAdd, Text: "second"}}
+			if len(files) != 1 || files[0].OldPath != "README" || files[0].NewPath != "README" ||
+				len(files[0].Hunks) != 1 || !reflect.DeepEqual(files[0].Hunks[0].Lines, expected) {
+				t.Errorf("Incorrect %s revision diff, found: %+v, results:\n%s", vcsType, files, results)
+			}
+			// stderr warnings written mid diff don't end up in the hunks
+			differ.SetRunner(noisyRunner{})
+			traced, results, err := differ.Diff(fixture.revs["first"], fixture.revs["second"], "README")
+			if err != nil || !reflect.DeepEqual(traced, files) {
+				t.Errorf("Incorrect %s revision diff with stderr output, err: %v, found: %+v, results:\n%s", vcsType, err, traced, results)
+			}
+			differ.SetRunner(nil)
+
+			fixture.write(localPath, "README", "third\nlocal change")
+			files, results, err = differ.Diff("", "")
+			if err != nil {
+				t.Fatalf("Unable to diff %s working tree, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if len(files) != 1 || files[0].NewPath != "README" || files[0].Added != 1 || files[0].Removed != 0 {
+				t.Errorf("Incorrect %s working tree diff, found: %+v, results:\n%s", vcsType, files, results)
+			}
+		})
+	}
+}
+
+// TestParseDiff verifies the diff output of each VCS is parsed right
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		vcsType Type
+		output  string
+		files   []*FileDiff
+	}{
+		{Git, "diff --git a/a.txt b/a.txt\nindex 1a2b..3c4d 100644\n--- a/a.txt\n+++ b/a.txt\n" +
+			"@@ -1,3 +1,3 @@ func main\n one\n-two\n+--- two\n three\n" +
+			"@@ -10 +10,0 @@\n-last\n\\ No newline at end of file\n" +
+			"diff --git a/new file.txt b/new file.txt\nnew file mode 100644\nindex 0000000..1a2b\n--- /dev/null\n+++ b/new file.txt\n@@ -0,0 +1 @@\n+new\n" +
+			"diff --git a/old.txt b/moved.txt\nsimilarity index 100%\nrename from old.txt\nrename to moved.txt\n" +
+			"diff --git a/image.png b/image.png\ndeleted file mode 100644\nindex 1a2b..0000000\nBinary files a/image.png and /dev/null differ\n" +
+			"diff --git \"a/tab\\tname\" \"b/tab\\tname\"\nindex 1a2b..3c4d 100644\n--- \"a/tab\\tname\"\n+++ \"b/tab\\tname\"\n@@ -1 +1 @@\n-x\n+y\n",
+			[]*FileDiff{
+				{OldPath: "a.txt", NewPath: "a.txt", Added: 1, Removed: 2, Hunks: []*Hunk{
+					{OldStart: 1, OldLines: 3, NewStart: 1, NewLines: 3, Section: "func main", Lines: []DiffLine{
+						{Op: DiffContext, Text: "one"}, {Op: DiffDelete, Text: "two"}, {Op: DiffAdd, Text: "--- two"}, {Op: DiffContext, Text: "three"}}},
+					{OldStart: 10, OldLines: 1, NewStart: 10, NewLines: 0, Lines: []DiffLine{{Op: DiffDelete, Text: "last", NoNewline: true}}},
+				}},
+				{NewPath: "new file.txt", Added: 1, Hunks: []*Hunk{
+					{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 1, Lines: []DiffLine{{Op: DiffAdd, Text: "new"}}}}},
+				{OldPath: "old.txt", NewPath: "moved.txt"},
+				{OldPath: "image.png", Binary: true},
+				{OldPath: "tab\tname", NewPath: "tab\tname", Added: 1, Removed: 1, Hunks: []*Hunk{
+					{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1, Lines: []DiffLine{{Op: DiffDelete, Text: "x"}, {Op: DiffAdd, Text: "y"}}}}},
+			},
+		},
+		{Svn, "Index: a.txt\n===================================================================\n--- a.txt\t(revision 2)\n+++ a.txt\t(working copy)\n" +
+			"@@ -1 +1,2 @@\n a\n+b\n" +
+			"Index: new.txt\n===================================================================\n--- new.txt\t(nonexistent)\n+++ new.txt\t(working copy)\n@@ -0,0 +1 @@\n+new\n" +
+			"Index: .\n===================================================================\n--- .\t(revision 2)\n+++ .\t(working copy)\n\n" +
+			"Property changes on: .\n___________________________________________________________________\nAdded: svn:mergeinfo\n## -0,0 +0,1 ##\n   Merged /branches/testbr1:r3\n" +
+			"Index: image.png\n===================================================================\nCannot display: file marked as a binary type.\nsvn:mime-type = application/octet-stream\n",
+			[]*FileDiff{
+				{OldPath: "a.txt", NewPath: "a.txt", Added: 1, Hunks: []*Hunk{
+					{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 2, Lines: []DiffLine{{Op: DiffContext, Text: "a"}, {Op: DiffAdd, Text: "b"}}}}},
+				{NewPath: "new.txt", Added: 1, Hunks: []*Hunk{
+					{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 1, Lines: []DiffLine{{Op: DiffAdd, Text: "new"}}}}},
+				{OldPath: "image.png", NewPath: "image.png", Binary: true},
+			},
+		},
+		{Bzr, "=== added directory 'dir'\n=== added file 'dir/new.txt'\n--- dir/new.txt\t1970-01-01 00:00:00 +0000\n+++ dir/new.txt\t2016-01-02 03:04:05 +0000\n" +
+			"@@ -0,0 +1,1 @@\n+new\n" +
+			"=== modified file 'a.txt'\n--- a.txt\t2016-01-02 03:04:05 +0000\n+++ a.txt\t2016-01-02 03:05:05 +0000\n@@ -1,1 +1,1 @@\n-a\n+b\n" +
+			"=== removed file 'gone.txt'\n--- gone.txt\t2016-01-02 03:04:05 +0000\n+++ gone.txt\t1970-01-01 00:00:00 +0000\n@@ -1,1 +0,0 @@\n-gone\n" +
+			"=== renamed file 'old.txt' => 'moved.txt'\n",
+			[]*FileDiff{
+				{NewPath: "dir/new.txt", Added: 1, Hunks: []*Hunk{
+					{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 1, Lines: []DiffLine{{Op: DiffAdd, Text: "new"}}}}},
+				{OldPath: "a.txt", NewPath: "a.txt", Added: 1, Removed: 1, Hunks: []*Hunk{
+					{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1, Lines: []DiffLine{{Op: DiffDelete, Text: "a"}, {Op: DiffAdd, Text: "b"}}}}},
+				{OldPath: "gone.txt", Removed: 1, Hunks: []*Hunk{
+					{OldStart: 1, OldLines: 1, NewStart: 0, NewLines: 0, Lines: []DiffLine{{Op: DiffDelete, Text: "gone"}}}}},
+				{OldPath: "old.txt", NewPath: "moved.txt"},
+			},
+		},
+	}
+	for _, test := range tests {
+		files, err := parseDiff(test.output)
+		if err != nil {
+			t.Errorf("Unable to parse %s diff, err: %s", test.vcsType, err)
+			continue
+		}
+		if !reflect.DeepEqual(files, test.files) {
+			t.Errorf("Incorrect %s diff parse, expected:", test.vcsType)
+			for _, file := range test.files {
+				t.Errorf("  %+v", *file)
+			}
+			t.Errorf("found:")
+			for _, file := range files {
+				t.Errorf("  %+v", *file)
+			}
+		}
+	}
+	if _, err := parseDiff("--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n?bad\n"); err == nil {
+		t.Errorf("Expected an error parsing a bad diff hunk")
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dvln/out"
+)
+
+// ArchiveFormat is the kind of archive an Exporter writes
+type ArchiveFormat string
+
+// Archive formats (see ExportOptions)
+const (
+	// ArchiveTar is an uncompressed tar archive
+	ArchiveTar ArchiveFormat = "tar"
+	// ArchiveTarGz is a gzip compressed tar archive
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	// ArchiveZip is a zip archive (deflate compressed)
+	ArchiveZip ArchiveFormat = "zip"
+)
+
+// ExportOptions controls what an Exporter puts in an archive and how, a nil
+// *ExportOptions exports the whole tree as a tar archive
+type ExportOptions struct {
+	// Format is the archive format, "" for a tar archive (or, for
+	// ExportFile, the format matching the file extension)
+	Format ArchiveFormat
+
+	// Subdir limits the export to this dir of the repo (relative to the
+	// repo root), its contents are at the top of the archive
+	Subdir string
+
+	// Prefix is a dir added in front of all paths in the archive (eg:
+	// "proj-1.2"), "" for none
+	Prefix string
+}
+
+// Exporter writes source archives of a revision.  The archives are
+// deterministic, exporting the same revision with the same options always
+// gives a byte identical archive (whatever the VCS or the state of the
+// clone): entries are in path order, all mtimes are the commit time of the
+// revision, file modes are 0644 or 0755 (executable), with no owner info.
+// Only the versioned files are exported, no VCS metadata, nested repos
+// (submodules, subrepos, externals) or local changes.
+type Exporter interface {
+	// Describer access to VCS system details (Remote, LocalRepoPath, ..)
+	Describer
+
+	// Export writes an archive of the given revision ("" for the current
+	// rev of the clone) to the writer as set in the options (nil for a tar
+	// of the whole tree)
+	Export(io.Writer, Rev, *ExportOptions) (Resulter, error)
+}
+
+// NewExporter returns a VCS Exporter based on trying to detect the VCS sys
+// from the remote and local repo locations.  The local clone is exported
+// from (svn contacts the repo for revisions other than the working copy
+// base).  The appropriate implementation will be returned or an
+// ErrCannotDetectVCS if the VCS type cannot be detected.
+func NewExporter(remote, localPath string, vcsType ...Type) (Exporter, error) {
+	vtype, remote, err := detectVCSType(remote, localPath, vcsType...)
+	if err != nil {
+		return nil, err
+	}
+	switch vtype {
+	case Git:
+		return NewGitReader(remote, localPath)
+	case Svn:
+		return NewSvnReader(remote, localPath)
+	case Hg:
+		return NewHgReader(remote, localPath)
+	case Bzr:
+		return NewBzrReader(remote, localPath)
+	}
+
+	// Should never fall through to here but just in case.
+	return nil, ErrCannotDetectVCS
+}
+
+// ExportFile writes an archive of the given revision to a file, if no
+// archive format is given in the options it is picked from the file
+// extension (".zip", ".tar.gz" or ".tgz", otherwise tar).  The file is only
+// replaced once the archive is complete, it is readable by all (mode 0644).
+func ExportFile(e Exporter, file string, rev Rev, opts *ExportOptions) (Resulter, error) {
+	fileOpts := ExportOptions{}
+	if opts != nil {
+		fileOpts = *opts
+	}
+	if fileOpts.Format == "" {
+		switch {
+		case strings.HasSuffix(file, ".zip"):
+			fileOpts.Format = ArchiveZip
+		case strings.HasSuffix(file, ".tar.gz") || strings.HasSuffix(file, ".tgz"):
+			fileOpts.Format = ArchiveTarGz
+		default:
+			fileOpts.Format = ArchiveTar
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file))
+	if err != nil {
+		return newResults(), out.WrapErrf(err, 4559, "Unable to create archive file for %s", file)
+	}
+	defer os.Remove(tmp.Name())
+	results, err := e.Export(tmp, rev, &fileOpts)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = out.WrapErrf(closeErr, 4559, "Unable to write archive file %s", tmp.Name())
+	}
+	if err != nil {
+		return results, err
+	}
+	// the temp file is private (0600), make it a regular archive file
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return results, out.WrapErrf(err, 4559, "Unable to set the mode of archive file %s", tmp.Name())
+	}
+	if err = os.Rename(tmp.Name(), file); err != nil {
+		return results, out.WrapErrf(err, 4559, "Unable to move archive into place as %s", file)
+	}
+	return results, nil
+}
+
+// exportTreeFunc writes the files of the given revision (only those in the
+// subdir if one is given) into the dir, which it creates, and returns the
+// dir the exported tree is rooted at (the subdir) in it
+type exportTreeFunc func(d Describer, dir string, rev Rev, subdir string) (string, Resulter, error)
+
+// exportArchive does the work of the VCS Export() funcs: the revision is
+// read (for its core rev and commit time), the VCS exportTree func exports
+// its files into a temp dir and those are written to the archive
+func exportArchive(r RevReader, w io.Writer, rev Rev, opts *ExportOptions, exportTree exportTreeFunc) (Resulter, error) {
+	results := newResults()
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	format := opts.Format
+	if format == "" {
+		format = ArchiveTar
+	}
+	if format != ArchiveTar && format != ArchiveTarGz && format != ArchiveZip {
+		return results, out.NewErrf(4560, "Unknown archive format: %s", format)
+	}
+	subdir := ""
+	if opts.Subdir != "" {
+		subdir = path.Clean(filepath.ToSlash(opts.Subdir))
+		if filepath.IsAbs(opts.Subdir) || path.IsAbs(subdir) || subdir == ".." || strings.HasPrefix(subdir, "../") {
+			return results, out.NewErrf(4561, "Export subdir must be a dir in the repo: %s", opts.Subdir)
+		}
+		if subdir == "." {
+			subdir = ""
+		}
+	}
+	revs, revResults, err := r.RevRead(AllData, rev)
+	for _, result := range revResults.All() {
+		results.add(result)
+	}
+	if err != nil {
+		return results, err
+	}
+	if len(revs) != 1 {
+		return results, out.WrapErrf(ErrUnknownRev, 4562, "Unable to find revision to export: %s", rev)
+	}
+	mtime := time.Unix(0, 0)
+	if tstamp := revs[0].TStamp(Committer); tstamp != nil {
+		mtime = tstamp.Truncate(time.Second)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "go-vcs-export")
+	if err != nil {
+		return results, out.WrapErr(err, "Unable to create temp dir for export", 4559)
+	}
+	defer os.RemoveAll(tmpDir)
+	root, treeResults, err := exportTree(r, filepath.Join(tmpDir, "tree"), revs[0].Core(), subdir)
+	for _, result := range treeResults.All() {
+		results.add(result)
+	}
+	if err != nil {
+		return results, err
+	}
+	return results, writeArchive(w, root, format, strings.Trim(filepath.ToSlash(opts.Prefix), "/"), mtime)
+}
+
+// archiveEntry is a file, dir or symlink to write to an archive
+type archiveEntry struct {
+	name string      // path in the archive ("/" separated)
+	file string      // path of the exported file on disk
+	mode os.FileMode // normalized mode (see archiveMode)
+	link string      // symlink target
+}
+
+// archiveMode normalizes the file mode for an archive entry, only the type
+// and whether a file is executable are kept
+func archiveMode(mode os.FileMode) os.FileMode {
+	switch {
+	case mode.IsDir():
+		return os.ModeDir | 0755
+	case mode&os.ModeSymlink != 0:
+		return os.ModeSymlink | 0777
+	case mode&0111 != 0:
+		return 0755
+	}
+	return 0644
+}
+
+// writeArchive writes the tree in the dir as an archive of the given format
+// with the prefix dir ("" for none) added to all paths and all mtimes set to
+// the given time, see Exporter for what makes the archive deterministic
+func writeArchive(w io.Writer, dir string, format ArchiveFormat, prefix string, mtime time.Time) error {
+	var entries []archiveEntry
+	if prefix != "" {
+		entries = append(entries, archiveEntry{name: prefix + "/", mode: os.ModeDir | 0755})
+	}
+	// filepath.Walk visits the entries in lexical order
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || file == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		entry := archiveEntry{name: path.Join(prefix, filepath.ToSlash(rel)), file: file, mode: archiveMode(info.Mode())}
+		if info.IsDir() {
+			entry.name += "/"
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			if entry.link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err == nil {
+		switch format {
+		case ArchiveZip:
+			err = writeZip(w, entries, mtime)
+		case ArchiveTarGz:
+			gz := gzip.NewWriter(w) // no name or mtime in the header
+			if err = writeTar(gz, entries, mtime); err == nil {
+				err = gz.Close()
+			}
+		default:
+			err = writeTar(w, entries, mtime)
+		}
+	}
+	if err != nil {
+		return out.WrapErrf(err, 4563, "Unable to write %s archive", format)
+	}
+	return nil
+}
+
+// writeTar writes the entries as a tar archive
+func writeTar(w io.Writer, entries []archiveEntry, mtime time.Time) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		hdr := &tar.Header{
+			Name:     entry.name,
+			Mode:     int64(entry.mode.Perm()),
+			ModTime:  mtime,
+			Typeflag: tar.TypeReg,
+		}
+		switch {
+		case entry.mode.IsDir():
+			hdr.Typeflag = tar.TypeDir
+		case entry.mode&os.ModeSymlink != 0:
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = entry.link
+		default:
+			info, err := os.Stat(entry.file)
+			if err != nil {
+				return err
+			}
+			hdr.Size = info.Size()
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if err := copyFile(tw, entry.file); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// writeZip writes the entries as a zip archive
+func writeZip(w io.Writer, entries []archiveEntry, mtime time.Time) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		hdr := &zip.FileHeader{Name: entry.name, Method: zip.Deflate, Modified: mtime.UTC()}
+		hdr.SetMode(entry.mode)
+		if entry.mode.IsDir() {
+			hdr.Method = zip.Store
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.mode.IsDir():
+		case entry.mode&os.ModeSymlink != 0:
+			_, err = io.WriteString(fw, entry.link)
+		default:
+			err = copyFile(fw, entry.file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// copyFile copies the contents of the file to the writer
+func copyFile(w io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// untar extracts the files, dirs and symlinks in a tar file into the dir,
+// other entries (eg: a pax global header) are skipped
+func untar(file, dir string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)[1:]
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg, tar.TypeRegA:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = writeFile(target, tr, os.FileMode(hdr.Mode).Perm())
+			}
+		case tar.TypeSymlink:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = os.Symlink(hdr.Linkname, target)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeFile creates the file with the given mode and the reader contents
+func writeFile(file string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,244 @@
+package vcs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dvln/out"
+)
+
+// TestExport verifies archives of a revision have the right files, subdir
+// and prefix, and that they are byte identical whatever clone they are
+// exported from (for each VCS type that can be tested, skipping those with
+// no tools installed)
+func TestExport(t *testing.T) {
+	for _, vcsType := range []Type{Git, Hg, Svn, Bzr} {
+		vcsType := vcsType
+		t.Run(string(vcsType), func(t *testing.T) {
+			if !haveFixtureTools(vcsType) {
+				t.Skipf("skipping %s export tests, %s tools not found", vcsType, vcsType)
+			}
+			tempDir, err := ioutil.TempDir("", "go-vcs-export-tests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+
+			// a repo with a subdir and an executable, the README changes
+			// after the revision exported
+			f := &fixture{vcs: vcsType, revs: make(map[string]Rev), t: t}
+			src := filepath.Join(tempDir, "src")
+			remote := src
+			switch vcsType {
+			case Svn: // src is a working copy of the repo
+				repo := filepath.Join(tempDir, "repo")
+				remote = "file://" + repo
+				f.run(tempDir, nil, "svnadmin", "create", repo)
+				f.run(tempDir, nil, svnTool, "checkout", "-q", remote, src)
+			case Bzr:
+				f.run(tempDir, nil, bzrTool, "init", "-q", src)
+			default:
+				f.run(tempDir, nil, string(vcsType), "init", src)
+			}
+			commit := func(files map[string]string) {
+				for file, content := range files {
+					if err := os.MkdirAll(filepath.Join(src, filepath.Dir(file)), 0755); err != nil {
+						t.Fatal(err)
+					}
+					f.write(src, file, content)
+				}
+				if err := os.Chmod(filepath.Join(src, "bin", "run"), 0755); err != nil {
+					t.Fatal(err)
+				}
+				switch vcsType {
+				case Git:
+					f.run(src, fixtureEnv, gitTool, "add", "-A")
+					f.run(src, fixtureEnv, gitTool, "commit", "-q", "-m", "add files")
+				case Hg:
+					f.run(src, nil, hgTool, "commit", "-A", "-m", "add files")
+				case Svn: // svn add sets svn:executable on bin/run
+					f.run(src, nil, svnTool, "add", "-q", "--force", ".")
+					f.run(src, nil, svnTool, "commit", "-q", "-m", "add files", "--username", "tester", "--non-interactive")
+				case Bzr:
+					f.run(src, nil, bzrTool, "add", "-q")
+					f.run(src, nil, bzrTool, "commit", "-q", "-m", "add files")
+				}
+			}
+			commit(map[string]string{"README": "first", "bin/run": "#!/bin/sh", "docs/guide.txt": "guide"})
+			commit(map[string]string{"README": "second"})
+
+			// two clones, at different revs and file times
+			var exporters []Exporter
+			for _, name := range []string{"VCSTestRepo", "VCSTestRepo2"} {
+				localPath := filepath.Join(tempDir, name)
+				switch vcsType {
+				case Svn:
+					f.run(tempDir, nil, svnTool, "checkout", "-q", remote, localPath)
+				case Bzr:
+					f.run(tempDir, nil, bzrTool, "branch", "-q", src, localPath)
+				default:
+					f.run(tempDir, nil, string(vcsType), "clone", "-q", src, localPath)
+				}
+				exporter, err := NewExporter(remote, localPath, vcsType)
+				if err != nil {
+					t.Fatalf("Unable to instantiate new %s VCS exporter, err: %s", vcsType, err)
+				}
+				exporters = append(exporters, exporter)
+			}
+			old := time.Now().Add(-time.Hour)
+			if err = os.Chtimes(filepath.Join(tempDir, "VCSTestRepo2", "README"), old, old); err != nil {
+				t.Fatal(err)
+			}
+			revs, results, err := exporters[0].(RevLogger).RevLog(AllData, "", "", 0)
+			if err != nil || len(revs) != 2 {
+				t.Fatalf("Unable to read %s revisions, err: %v, results:\n%s", vcsType, err, results)
+			}
+			rev := revs[1].Core()
+			mtime := *revs[1].TStamp(Committer)
+
+			var archives []*bytes.Buffer
+			for _, exporter := range exporters {
+				archive := &bytes.Buffer{}
+				if results, err := exporter.Export(archive, rev, &ExportOptions{Prefix: "proj-1.0/"}); err != nil {
+					t.Fatalf("Unable to export %s revision, err: %s, results:\n%s", vcsType, err, results)
+				}
+				archives = append(archives, archive)
+			}
+			if !bytes.Equal(archives[0].Bytes(), archives[1].Bytes()) {
+				t.Errorf("Expected %s archives of the same revision to be byte identical", vcsType)
+			}
+			checkArchive(t, readTar(t, archives[0]), mtime, map[string]string{
+				"proj-1.0/":               "d755",
+				"proj-1.0/README":         "644:first\n",
+				"proj-1.0/bin/":           "d755",
+				"proj-1.0/bin/run":        "755:#!/bin/sh\n",
+				"proj-1.0/docs/":          "d755",
+				"proj-1.0/docs/guide.txt": "644:guide\n",
+			})
+
+			// to files, the format picked from the file extension
+			zipFile := filepath.Join(tempDir, "docs.zip")
+			if results, err := ExportFile(exporters[1], zipFile, rev, &ExportOptions{Subdir: "docs"}); err != nil {
+				t.Fatalf("Unable to export %s subdir, err: %s, results:\n%s", vcsType, err, results)
+			}
+			checkArchive(t, readZip(t, zipFile), mtime, map[string]string{"guide.txt": "644:guide\n"})
+			var tgzs [][]byte
+			for i, exporter := range exporters {
+				tgzFile := filepath.Join(tempDir, "head.tgz")
+				if results, err := ExportFile(exporter, tgzFile, "", nil); err != nil {
+					t.Fatalf("Unable to export %s current rev, err: %s, results:\n%s", vcsType, err, results)
+				}
+				tgz, err := ioutil.ReadFile(tgzFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info, err := os.Stat(tgzFile); err != nil {
+					t.Fatal(err)
+				} else if info.Mode().Perm() != 0644 {
+					t.Errorf("Incorrect %s archive file mode, found: %v", vcsType, info.Mode())
+				}
+				tgzs = append(tgzs, tgz)
+				if i == 0 {
+					gz, err := gzip.NewReader(bytes.NewReader(tgz))
+					if err != nil {
+						t.Fatalf("Unable to read %s tar.gz archive, err: %s", vcsType, err)
+					}
+					entries := readTar(t, gz)
+					if entries["README"] != "644:second\n" {
+						t.Errorf("Incorrect %s README exported for current rev, found: %q", vcsType, entries["README"])
+					}
+				}
+			}
+			if !bytes.Equal(tgzs[0], tgzs[1]) {
+				t.Errorf("Expected %s tar.gz archives of the same revision to be byte identical", vcsType)
+			}
+
+			if _, err = exporters[0].Export(ioutil.Discard, rev, &ExportOptions{Subdir: "../src"}); !out.IsError(err, nil, 4561) {
+				t.Errorf("Expected an error exporting a subdir outside the %s repo, err: %v", vcsType, err)
+			}
+			if _, err = exporters[0].Export(ioutil.Discard, rev, &ExportOptions{Format: "rar"}); !out.IsError(err, nil, 4560) {
+				t.Errorf("Expected an error exporting an unknown %s archive format, err: %v", vcsType, err)
+			}
+		})
+	}
+}
+
+// checkArchive verifies the archive entries ("<mode>:<content>" or "d<mode>"
+// for dirs, by name) and that all the entries have the given mtime
+func checkArchive(t *testing.T, entries map[string]string, mtime time.Time, expected map[string]string) {
+	if mtimes := entries["mtimes"]; mtimes != mtime.Truncate(time.Second).UTC().String() {
+		t.Errorf("Incorrect archive mtimes, expected: %s, found: %s", mtime.UTC(), mtimes)
+	}
+	delete(entries, "mtimes")
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Incorrect archive entries, expected: %q, found: %q", expected, entries)
+	}
+}
+
+// readTar reads a tar archive into its entries (see checkArchive), along
+// with an "mtimes" entry that has the mtime of all entries (or "mixed")
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	entries := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("Unable to read tar archive, err: %s", err)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Unable to read tar archive, err: %s", err)
+		}
+		addArchiveEntry(entries, hdr.Name, hdr.FileInfo().Mode(), hdr.ModTime, content)
+	}
+}
+
+// readZip reads a zip archive file into its entries (see readTar)
+func readZip(t *testing.T, file string) map[string]string {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		t.Fatalf("Unable to open zip archive, err: %s", err)
+	}
+	defer zr.Close()
+	entries := make(map[string]string)
+	for _, zf := range zr.File {
+		r, err := zf.Open()
+		if err != nil {
+			t.Fatalf("Unable to read zip archive, err: %s", err)
+		}
+		content, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("Unable to read zip archive, err: %s", err)
+		}
+		addArchiveEntry(entries, zf.Name, zf.Mode(), zf.Modified, content)
+	}
+	return entries
+}
+
+// addArchiveEntry adds an archive entry read to the entries (see readTar)
+func addArchiveEntry(entries map[string]string, name string, mode os.FileMode, mtime time.Time, content []byte) {
+	if mode.IsDir() {
+		entries[name] = "d" + strconv.FormatUint(uint64(mode.Perm()), 8)
+	} else {
+		entries[name] = strconv.FormatUint(uint64(mode.Perm()), 8) + ":" + string(content)
+	}
+	if mtimes, ok := entries["mtimes"]; !ok {
+		entries["mtimes"] = mtime.UTC().String()
+	} else if mtimes != mtime.UTC().String() {
+		entries["mtimes"] = "mixed"
+	}
+}
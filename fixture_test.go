@@ -0,0 +1,388 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Local fixture repos are built for the Getter/Updater/Reader tests so the
+// test suite can run offline, the few tests that need a real remote host
+// (eg: remote scheme detection) only run if VCS_NETWORK_TESTS is set.
+
+// fixtureUser is the user all fixture history is committed as
+const fixtureUser = "VCS Tester <tester@example.com>"
+
+// fixtureEnv has the settings used for all fixture cmds so the history
+// built doesn't depend upon the user config (and never prompts)
+var fixtureEnv = []string{
+	"GIT_AUTHOR_NAME=VCS Tester",
+	"GIT_AUTHOR_EMAIL=tester@example.com",
+	"GIT_COMMITTER_NAME=VCS Tester",
+	"GIT_COMMITTER_EMAIL=tester@example.com",
+	"GIT_CONFIG_NOSYSTEM=1",
+	"GIT_CONFIG_GLOBAL=" + os.DevNull,
+	"HGUSER=" + fixtureUser,
+	"HGRCPATH=",
+	"HGPLAIN=1",
+	"BZR_EMAIL=" + fixtureUser,
+	"LC_ALL=C",
+}
+
+// fixtureTime is the commit time of the first commit in the fixture
+// history, each following commit is a minute later (where the VCS allows)
+var fixtureTime = time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// fixtureTools has the tools needed to build a fixture for each VCS
+var fixtureTools = map[Type][]string{
+	Git: {gitTool},
+	Hg:  {hgTool},
+	Svn: {svnTool, "svnadmin"},
+	Bzr: {bzrTool},
+}
+
+// fixture is a repo built on local disk with a scripted history for offline
+// testing, the history (the same for all VCS types) has these named revs:
+//	first:  first commit on the main line (master|default|trunk)
+//	second: second commit on the main line, tagged v1.0.0
+//	branch: commit on the testbr1 branch (branched from second)
+//	third:  third commit on the main line
+//	merge:  merge of testbr1 into the main line, tagged testtag
+//	tip:    latest revision, for hg and svn (which record tags as new
+//	        revisions) this is the testtag tag revision, else the merge
+type fixture struct {
+	vcs     Type
+	remote  string         // remote to get from (as the VCS records it)
+	url     string         // file:// URL of the fixture repo
+	path    string         // path to the fixture repo on disk
+	revs    map[string]Rev // revs in the history by name (see above)
+	t       *testing.T
+	commits int // number of commits done, used for commit times
+}
+
+// haveFixtureTools returns true if the tools needed to build a fixture
+// for the given VCS are available
+func haveFixtureTools(vcsType Type) bool {
+	for _, tool := range fixtureTools[vcsType] {
+		if _, err := exec.LookPath(tool); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// newFixture builds a fixture repo of the given VCS type under the given
+// dir, if the VCS tools aren't available the test is skipped
+func newFixture(t *testing.T, vcsType Type, dir string) *fixture {
+	if !haveFixtureTools(vcsType) {
+		t.Skipf("skipping %s fixture tests, %s tools not found", vcsType, vcsType)
+	}
+	f := &fixture{vcs: vcsType, revs: make(map[string]Rev), t: t}
+	switch vcsType {
+	case Git:
+		f.buildGit(dir)
+	case Hg:
+		f.buildHg(dir)
+	case Svn:
+		f.buildSvn(dir)
+	case Bzr:
+		f.buildBzr(dir)
+	default:
+		t.Fatalf("No fixture support for VCS type: %s", vcsType)
+	}
+	return f
+}
+
+// skipUnlessNetwork skips the rest of a test unless network tests are on
+func skipUnlessNetwork(t *testing.T) {
+	if os.Getenv("VCS_NETWORK_TESTS") == "" {
+		t.Skip("skipping remaining checks needing the network, set VCS_NETWORK_TESTS=1 to run them")
+	}
+}
+
+// run runs a VCS cmd (with the fixture env) to build the fixture, the test
+// fails if the cmd fails, else the trimmed cmd output is returned
+func (f *fixture) run(dir string, env []string, cmd string, args ...string) string {
+	fullEnv := append(append([]string{}, fixtureEnv...), env...)
+	result, err := runInDir(context.Background(), dir, fullEnv, cmd, args...)
+	if err != nil {
+		f.t.Fatalf("Unable to build %s fixture, err: %s, result:\n%s", f.vcs, err, result)
+	}
+	return strings.TrimSpace(result.Output)
+}
+
+// checkout gets the fixture repo into the local path (clone, checkout or
+// branch) and updates it, as the tests working in a workspace start out,
+// the test fails if either fails
+func (f *fixture) checkout(localPath string) {
+	getter, err := NewGetter(f.remote, "", localPath, false, f.vcs)
+	if err != nil {
+		f.t.Fatalf("Unable to instantiate new %s VCS getter, err: %s", f.vcs, err)
+	}
+	if results, err := getter.Get(); err != nil {
+		f.t.Fatalf("Unable to get %s repo, err: %s, results:\n%s", f.vcs, err, results)
+	}
+	updater, err := NewUpdater(f.remote, "", localPath, false, RebaseFalse, nil, f.vcs)
+	if err != nil {
+		f.t.Fatalf("Unable to instantiate new %s VCS updater, err: %s", f.vcs, err)
+	}
+	if results, err := updater.Update(); err != nil {
+		f.t.Fatalf("Unable to update %s repo, err: %s, results:\n%s", f.vcs, err, results)
+	}
+}
+
+// write writes a file in the given dir with the given content
+func (f *fixture) write(dir, name, content string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0644); err != nil {
+		f.t.Fatalf("Unable to write %s fixture file, err: %s", f.vcs, err)
+	}
+}
+
+// commitTime returns the time to use for the next fixture commit
+func (f *fixture) commitTime() time.Time {
+	commitTime := fixtureTime.Add(time.Duration(f.commits) * time.Minute)
+	f.commits++
+	return commitTime
+}
+
+// buildGit builds a bare git fixture repo (git-fixture.git) by scripting
+// the history in a work clone and then bare cloning it
+func (f *fixture) buildGit(dir string) {
+	work := filepath.Join(dir, "git-fixture-work")
+	f.path = filepath.Join(dir, "git-fixture.git")
+	f.url = "file://" + f.path
+	f.remote = f.url
+	f.run(dir, nil, gitTool, "init", "-q", work)
+	f.run(work, nil, gitTool, "symbolic-ref", "HEAD", "refs/heads/master")
+	f.write(work, "README", "first")
+	f.gitCommit(work, "first", "commit", "-q", "-m", "first commit")
+	f.write(work, "README", "second")
+	f.gitCommit(work, "second", "commit", "-q", "-m", "second commit")
+	f.run(work, nil, gitTool, "tag", "v1.0.0")
+	f.run(work, nil, gitTool, "checkout", "-q", "-b", "testbr1")
+	f.write(work, "BRANCH", "branch")
+	f.gitCommit(work, "branch", "commit", "-q", "-m", "testbr1 commit")
+	f.run(work, nil, gitTool, "checkout", "-q", "master")
+	f.write(work, "README", "third")
+	f.gitCommit(work, "third", "commit", "-q", "-m", "third commit")
+	f.gitCommit(work, "merge", "merge", "-q", "--no-ff", "-m", "merge testbr1", "testbr1")
+	f.run(work, nil, gitTool, "tag", "testtag")
+	f.revs["tip"] = f.revs["merge"]
+	f.run(dir, nil, gitTool, "clone", "-q", "--bare", work, f.path)
+}
+
+// gitCommit runs the given git commit (or merge) cmd in the work clone,
+// the resulting commit is recorded under the given name
+func (f *fixture) gitCommit(work, name string, args ...string) {
+	stamp := fmt.Sprintf("%d +0000", f.commitTime().Unix())
+	env := []string{"GIT_AUTHOR_DATE=" + stamp, "GIT_COMMITTER_DATE=" + stamp}
+	if args[0] == "commit" {
+		f.run(work, nil, gitTool, "add", "-A")
+	}
+	f.run(work, env, gitTool, args...)
+	f.revs[name] = Rev(f.run(work, nil, gitTool, "rev-parse", "HEAD"))
+}
+
+// buildHg builds an hg fixture repo (hg-fixture), the testbr1 branch is a
+// named branch and the tags are added (as revisions) after the merge
+func (f *fixture) buildHg(dir string) {
+	f.path = filepath.Join(dir, "hg-fixture")
+	f.url = "file://" + f.path
+	f.remote = f.path // hg records local remotes as plain paths
+	f.run(dir, nil, hgTool, "init", f.path)
+	f.write(f.path, "README", "first")
+	f.hgCommit("first", "commit", "-A", "-m", "first commit")
+	f.write(f.path, "README", "second")
+	f.hgCommit("second", "commit", "-m", "second commit")
+	f.run(f.path, nil, hgTool, "branch", "-q", "testbr1")
+	f.write(f.path, "BRANCH", "branch")
+	f.hgCommit("branch", "commit", "-A", "-m", "testbr1 commit")
+	f.run(f.path, nil, hgTool, "update", "-q", "default")
+	f.write(f.path, "README", "third")
+	f.hgCommit("third", "commit", "-m", "third commit")
+	f.run(f.path, nil, hgTool, "merge", "-q", "testbr1")
+	f.hgCommit("merge", "commit", "-m", "merge testbr1")
+	f.hgCommit("", "tag", "-r", string(f.revs["second"]), "-m", "tag v1.0.0", "v1.0.0")
+	f.hgCommit("tip", "tag", "-r", string(f.revs["merge"]), "-m", "tag testtag", "testtag")
+}
+
+// hgCommit runs the given hg commit (or tag) cmd in the fixture repo, the
+// resulting revision (full node) is recorded under the given name if set
+func (f *fixture) hgCommit(name string, args ...string) {
+	args = append(args, "-d", fmt.Sprintf("%d 0", f.commitTime().Unix()))
+	f.run(f.path, nil, hgTool, args...)
+	if name != "" {
+		f.revs[name] = Rev(f.run(f.path, nil, hgTool, "log", "-r", ".", "--template", "{node}"))
+	}
+}
+
+// buildSvn builds an svn fixture repo (svn-fixture) with the standard
+// trunk, branches and tags layout, the remote is the trunk URL
+func (f *fixture) buildSvn(dir string) {
+	f.path = filepath.Join(dir, "svn-fixture")
+	f.url = "file://" + f.path
+	f.remote = f.url + "/trunk"
+	f.run(dir, nil, "svnadmin", "create", f.path)
+	f.svnCommit(dir, "", "mkdir", "-m", "initial layout", f.url+"/trunk", f.url+"/branches", f.url+"/tags")
+	work := filepath.Join(dir, "svn-fixture-trunk")
+	f.run(dir, nil, svnTool, "checkout", "-q", f.remote, work)
+	f.write(work, "README", "first")
+	f.run(work, nil, svnTool, "add", "-q", "README")
+	f.svnCommit(work, "first", "commit", "-m", "first commit")
+	f.write(work, "README", "second")
+	f.svnCommit(work, "second", "commit", "-m", "second commit")
+	second := string(f.revs["second"])
+	f.svnCommit(dir, "", "copy", "-m", "tag v1.0.0", f.remote+"@"+second, f.url+"/tags/v1.0.0")
+	f.svnCommit(dir, "", "copy", "-m", "create testbr1", f.remote+"@"+second, f.url+"/branches/testbr1")
+	branchWork := filepath.Join(dir, "svn-fixture-testbr1")
+	f.run(dir, nil, svnTool, "checkout", "-q", f.url+"/branches/testbr1", branchWork)
+	f.write(branchWork, "BRANCH", "branch")
+	f.run(branchWork, nil, svnTool, "add", "-q", "BRANCH")
+	f.svnCommit(branchWork, "branch", "commit", "-m", "testbr1 commit")
+	f.write(work, "README", "third")
+	f.svnCommit(work, "third", "commit", "-m", "third commit")
+	f.run(work, nil, svnTool, "update", "-q")
+	f.run(work, nil, svnTool, "merge", "-q", "^/branches/testbr1")
+	f.svnCommit(work, "merge", "commit", "-m", "merge testbr1")
+	f.svnCommit(dir, "tip", "copy", "-m", "tag testtag", f.remote+"@"+string(f.revs["merge"]), f.url+"/tags/testtag")
+}
+
+// svnCommit runs the given svn cmd that commits (eg: commit, mkdir or copy
+// with URLs), the committed revision is recorded under the given name if set
+func (f *fixture) svnCommit(dir, name string, args ...string) {
+	args = append(args, "--username", "tester", "--non-interactive")
+	output := f.run(dir, nil, svnTool, args...)
+	m := svnCommittedRegex.FindStringSubmatch(output)
+	if m == nil {
+		f.t.Fatalf("Unable to find committed revision building svn fixture, output:\n%s", output)
+	}
+	if name != "" {
+		f.revs[name] = Rev(m[1])
+	}
+}
+
+// buildBzr builds a bzr fixture branch (bzr-fixture), testbr1 is a branch
+// of it (bzr-fixture-testbr1) that is merged back in
+func (f *fixture) buildBzr(dir string) {
+	f.path = filepath.Join(dir, "bzr-fixture")
+	f.url = "file://" + f.path
+	f.remote = f.url
+	f.run(dir, nil, bzrTool, "init", "-q", f.path)
+	f.write(f.path, "README", "first")
+	f.run(f.path, nil, bzrTool, "add", "-q")
+	f.bzrCommit(f.path, "first", "first commit")
+	f.write(f.path, "README", "second")
+	f.bzrCommit(f.path, "second", "second commit")
+	f.run(f.path, nil, bzrTool, "tag", "-q", "v1.0.0")
+	branchPath := filepath.Join(dir, "bzr-fixture-testbr1")
+	f.run(dir, nil, bzrTool, "branch", "-q", f.path, branchPath)
+	f.write(branchPath, "BRANCH", "branch")
+	f.run(branchPath, nil, bzrTool, "add", "-q")
+	f.bzrCommit(branchPath, "branch", "testbr1 commit")
+	f.write(f.path, "README", "third")
+	f.bzrCommit(f.path, "third", "third commit")
+	f.run(f.path, nil, bzrTool, "merge", "-q", branchPath)
+	f.bzrCommit(f.path, "merge", "merge testbr1")
+	f.run(f.path, nil, bzrTool, "tag", "-q", "testtag")
+	f.revs["tip"] = f.revs["merge"]
+}
+
+// bzrCommit commits the bzr branch at the given path, the revno of the
+// commit is recorded under the given name (for the testbr1 branch commit
+// the revid is recorded, ie: "revid:<id>", as revnos differ by branch)
+func (f *fixture) bzrCommit(branchPath, name, msg string) {
+	commitTime := f.commitTime().Format("2006-01-02 15:04:05 -0700")
+	f.run(branchPath, nil, bzrTool, "commit", "-q", "-m", msg, "--commit-time", commitTime)
+	revInfo := strings.Fields(f.run(branchPath, nil, bzrTool, "revision-info"))
+	if len(revInfo) != 2 {
+		f.t.Fatalf("Unable to read bzr fixture revision info, found: %v", revInfo)
+	}
+	if branchPath != f.path {
+		f.revs[name] = Rev("revid:" + revInfo[1])
+	} else {
+		f.revs[name] = Rev(revInfo[0])
+	}
+}
+
+// TestFixtures verifies the fixture repos for each VCS can be built and
+// have the scripted history
+func TestFixtures(t *testing.T) {
+	for _, vcsType := range []Type{Git, Hg, Svn, Bzr} {
+		if !haveFixtureTools(vcsType) {
+			t.Logf("skipping %s fixture build, %s tools not found", vcsType, vcsType)
+			continue
+		}
+		tempDir, err := ioutil.TempDir("", "go-vcs-fixture-tests")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tempDir)
+		f := newFixture(t, vcsType, tempDir)
+		for _, name := range []string{"first", "second", "branch", "third", "merge", "tip"} {
+			if f.revs[name] == "" {
+				t.Errorf("The %s fixture has no %s revision", vcsType, name)
+			}
+		}
+		if ltype, err := DetectVcsFromFS(f.path); vcsType != Svn && (err != nil || ltype != vcsType) {
+			t.Errorf("The %s fixture repo was not detected as %s, found: %s, err: %v", vcsType, vcsType, ltype, err)
+		}
+	}
+}
+
+// The test runners below wrap (or stand in for) ExecRunner to set up the
+// cmds or their output the way a test needs, set them with SetRunner
+
+// envRunner runs cmds with the given env settings added, eg: to commit as
+// the fixture user with no user config or to have git trace to stderr
+// (GIT_TRACE=1)
+type envRunner struct {
+	env []string
+}
+
+// Run implements the Runner interface for the envRunner type
+func (r envRunner) Run(ctx context.Context, dir string, env []string, cmd string, args ...string) (*Result, error) {
+	return ExecRunner{}.Run(ctx, dir, append(append([]string{}, r.env...), env...), cmd, args...)
+}
+
+// traceEnv has git write trace lines to stderr along with its normal output
+// (other tools ignore it)
+var traceEnv = []string{"GIT_TRACE=1"}
+
+// noisyRunner adds a stderr warning in the middle of the combined output of
+// the cmds run, as when a tool warns while writing its output
+type noisyRunner struct{}
+
+// Run implements the Runner interface for the noisyRunner type
+func (noisyRunner) Run(ctx context.Context, dir string, env []string, cmd string, args ...string) (*Result, error) {
+	result, err := ExecRunner{}.Run(ctx, dir, env, cmd, args...)
+	if result != nil {
+		warning := "warning: LF will be replaced by CRLF in README\n"
+		lines := strings.SplitAfter(result.Stdout, "\n")
+		mid := len(lines) - len(lines)/3 // in the (last) hunk of a diff
+		result.Output = strings.Join(lines[:mid], "") + warning + strings.Join(lines[mid:], "")
+		result.Stderr += warning
+	}
+	return result, err
+}
+
+// cmdLogRunner logs the cmds it is asked to run (not running them), each
+// cmd succeeds with no output
+type cmdLogRunner struct {
+	cmds []string
+}
+
+// Run implements the Runner interface for the cmdLogRunner type
+func (r *cmdLogRunner) Run(ctx context.Context, dir string, env []string, cmd string, args ...string) (*Result, error) {
+	result := newResult()
+	result.Cmd = strings.Join(append([]string{cmd}, args...), " ")
+	result.Args = append([]string{cmd}, args...)
+	result.Dir = dir
+	r.cmds = append(r.cmds, result.Cmd)
+	return result, nil
+}
@@ -1,15 +1,21 @@
 package vcs
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/dvln/out"
 	"github.com/dvln/util/dir"
@@ -20,6 +26,22 @@ import (
 var defaultGitSchemes []string
 var refsRegex = regexp.MustCompile(`^refs/heads/(.*)$`)
 
+// gitRevFormat is the 'git log' format used for full revision data reads,
+// fields are split by unit separators (0x1f) and each revision record ends
+// with a record separator (0x1e) so multi-line comments parse cleanly
+const gitRevFormat = "--format=%H%x1f%an%x1f%ae%x1f%at%x1f%cn%x1f%ce%x1f%ct%x1f%D%x1f%B%x1e"
+
+// gitErrPatterns classifies failed git cmds by their output (see CmdError)
+var gitErrPatterns = []errPattern{
+	{ErrAuth, regexp.MustCompile(`(?i)authentication failed|permission denied \(publickey|could not read (username|password)|terminal prompts disabled|access denied|returned error: 40[13]`)},
+	{ErrLocked, regexp.MustCompile(`(?i)\.lock'?: file exists|unable to create '[^']*\.lock'|another git process seems to be running|cannot lock ref`)},
+	{ErrCorrupt, regexp.MustCompile(`(?i)corrupt|fatal: bad object|object file .* is empty|unable to read tree|missing (blob|tree|commit) `)},
+	{ErrDirtyTree, regexp.MustCompile(`(?i)local changes to the following files would be overwritten|please commit your changes or stash them|untracked working tree files would be overwritten|you have unstaged changes|your index contains uncommitted changes`)},
+	{ErrMergeConflict, regexp.MustCompile(`CONFLICT \(|Automatic merge failed|(?i:could not apply|fix conflicts and then|you have unmerged paths)`)},
+	{ErrUnknownRev, regexp.MustCompile(`(?i)unknown revision|did not match any file\(s\) known to git|couldn't find remote ref|bad revision|not a valid object name|invalid reference|needed a single revision|not a valid ref`)},
+	{ErrUnreachable, regexp.MustCompile(`(?i)could not resolve host|failed to connect to|connection refused|connection timed out|operation timed out|network is unreachable|no route to host|ssh: connect to host|temporary failure in name resolution`)},
+}
+
 // RemoteMode describes how remote URL and checking/updating works
 type RemoteMode string
 
@@ -35,18 +57,41 @@ func init() {
 	SetDefaultGitSchemes(nil)
 }
 
-// isBareRepo is a simple routine to see if a repo has a .git/ dir (non-bare),
-// otherwise it it assumed to be a bare repo, Param:
+// isBareRepo is a simple routine to see if a repo has a .git/ dir (non-bare)
+// or a .git file (a worktree), otherwise it it assumed to be a bare repo, Param:
 //	path (string): path to repo (should already have existence check done)
 // Returns true if (likely) bare, false otherwise
 func isBareRepo(path string) bool {
 	bare := true
-	if exists, err := dir.Exists(filepath.Join(path, ".git")); exists && err == nil {
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
 		bare = false // see if it's bare or not
 	}
 	return bare
 }
 
+// isShallowRepo is a simple routine to see if a git repo is a shallow clone
+// (ie: has a shallow file in the git dir), Param:
+//	path (string): path to repo (should already have existence check done)
+// Returns true if shallow, false otherwise
+func isShallowRepo(path string) bool {
+	gitDir, _, err := findGitDirs(path)
+	if err != nil {
+		return false
+	}
+	exists, err := file.Exists(filepath.Join(gitCommonDir(gitDir), "shallow"))
+	return exists && err == nil
+}
+
+// gitHookPath returns the path of the named hook in a git clone, for a
+// worktree the hooks are those of the main clone
+func gitHookPath(path, name string) string {
+	hooksDir := filepath.Join(path, ".git", "hooks")
+	if gitDir, _, err := findGitDirs(path); err == nil {
+		hooksDir = filepath.Join(gitCommonDir(gitDir), "hooks")
+	}
+	return filepath.Join(hooksDir, name)
+}
+
 // GitHookRemove is used to remove a hook from a git clone, params:
 //	h (*GitHookMgr): the hook mgr structure (find location of repo/etc)
 //	name (string): name of the hook to rm (git filename under hooks/)
@@ -54,10 +99,7 @@ func isBareRepo(path string) bool {
 func GitHookRemove(h *GitHookMgr, name string) error {
 	path, _, err := h.Exists(LocalPath)
 	if err == nil && path != "" { // if the local path exists...
-		hookPath := filepath.Join(path, ".git", "hooks", name)
-		if isBareRepo(path) {
-			hookPath = filepath.Join(path, "hooks", name)
-		}
+		hookPath := gitHookPath(path, name)
 		err = os.Remove(hookPath)
 	}
 	return err
@@ -73,10 +115,7 @@ func GitHookInstall(h *GitHookMgr, path, name string, link bool) (string, error)
 	repoPath, _, err := h.Exists(LocalPath)
 	hookInstallPath := ""
 	if err == nil && repoPath != "" { // if the local path exists...
-		hookInstallPath = filepath.Join(repoPath, ".git", "hooks", name)
-		if isBareRepo(repoPath) {
-			hookInstallPath = filepath.Join(repoPath, "hooks", name)
-		}
+		hookInstallPath = gitHookPath(repoPath, name)
 		if there, err := file.Exists(hookInstallPath); err == nil && there {
 			err = os.Remove(hookInstallPath)
 			if err != nil {
@@ -119,10 +158,7 @@ func GitHookInstalled(h *GitHookMgr, path, name string, link bool) bool {
 	hookInstalled := false
 	hookInstallPath := ""
 	if err == nil && repoPath != "" { // if the local path exists...
-		hookInstallPath = filepath.Join(repoPath, ".git", "hooks", name)
-		if isBareRepo(repoPath) {
-			hookInstallPath = filepath.Join(repoPath, "hooks", name)
-		}
+		hookInstallPath = gitHookPath(repoPath, name)
 		if link { // if client wants a link, see if link is there already...
 			fileInfo, err := os.Lstat(hookInstallPath)
 			if err != nil {
@@ -172,16 +208,18 @@ func GitHookInstalled(h *GitHookMgr, path, name string, link bool) bool {
 }
 
 // GitGet is used to perform an initial clone of a repository, optionally
-// can check out a rev, params:
+// can check out a rev, the getter clone options (if any) decide if it's a
+// shallow, single branch or partial clone and the getter sparse paths (if
+// any) make it a sparse checkout, if recursive the submodules are brought
+// in too (see gitUpdateSubmodules), params:
 //	g (*GitGetter): the getter data we need to run the pull
 //	rev (Rev): optional; revision to checkout after getting the clone
 // Returns results (vcs cmds run, output) and any error that may have occurred
 func GitGet(g *GitGetter, rev ...Rev) (Resulter, error) {
-	mirrorStr := ""
-	if g.mirror { // if in mirror clone mode add in --mirror
-		mirrorStr = "--mirror"
-	}
 	results := newResults()
+	if g.mirror && len(g.sparse) != 0 {
+		return results, out.NewErrf(4550, "Get: a sparse checkout needs a non-mirror clone, clone: %s", g.LocalRepoPath())
+	}
 	var result *Result
 	path, _, err := g.Exists(LocalPath)
 	update := false
@@ -199,34 +237,269 @@ func GitGet(g *GitGetter, rev ...Rev) (Resulter, error) {
 		runOpt := "-C"
 		runDir := g.LocalRepoPath()
 		if g.mirror { // if mirror type update desired do remote update
-			result, err = run(gitTool, runOpt, runDir, "remote", "update", "--prune", g.RemoteRepoName())
+			result, err = run(g.Context(), gitTool, runOpt, runDir, "remote", "update", "--prune", g.RemoteRepoName())
 		} else { // otherwise run git fetch
-			result, err = run(gitTool, runOpt, runDir, "fetch", g.RemoteRepoName())
+			result, err = run(g.Context(), gitTool, runOpt, runDir, "fetch", g.RemoteRepoName())
 		}
 	} else {
+		args := []string{"clone"}
 		// origin is the default remote name and if doing bare/mirror
 		// clone the -o option will not function
-		if g.mirror || g.RemoteRepoName() == "origin" {
-			result, err = run(gitTool, "clone", mirrorStr, g.Remote(), g.LocalRepoPath())
-		} else {
-			result, err = run(gitTool, "clone", "-o", g.RemoteRepoName(), mirrorStr, g.Remote(), g.LocalRepoPath())
+		if g.mirror {
+			args = append(args, "--mirror")
+		} else if g.RemoteRepoName() != "origin" {
+			args = append(args, "-o", g.RemoteRepoName())
+		}
+		args = append(args, gitCloneArgs(g.clone)...)
+		var cacheLock *os.File
+		if g.cache != "" { // borrow what objects we can from the cache mirror
+			cachePath, lock, cacheResults, cacheErr := gitCacheMirror(g)
+			for _, cacheResult := range cacheResults.All() {
+				results.add(cacheResult)
+			}
+			if cacheErr == nil { // cache trouble only costs speed, clone anyway
+				args = append(args, "--reference", cachePath, "--dissociate")
+				cacheLock = lock
+			}
+		}
+		if len(g.sparse) != 0 { // only check out top level files until set
+			args = append(args, "--sparse")
+		}
+		args = append(args, g.Remote(), g.LocalRepoPath())
+		result, err = run(g.Context(), gitTool, args...)
+		if cacheLock != nil { // clone done with the mirror objects
+			cacheLock.Close()
 		}
 	}
 
 	results.add(result)
-	if err != nil && rev != nil {
-		// Be careful to append more results from cmds run in RevSet
+	if err == nil && len(g.sparse) != 0 {
+		var sparseResults Resulter
+		sparseResults, err = GitSetSparse(g, g.sparse...)
+		for _, sparseResult := range sparseResults.All() {
+			results.add(sparseResult)
+		}
+	}
+	if err == nil && rev != nil {
+		// Be careful to append more results from cmds run in RevSet, a
+		// shallow clone may need deepening to have the rev to check out
 		var setResults Resulter
-		setResults, err = g.RevSet(rev[0])
+		setResults, err = gitDeepen(g, rev[0])
+		if err == nil {
+			for _, revResult := range setResults.All() {
+				results.add(revResult)
+			}
+			setResults, err = g.RevSet(rev[0])
+		}
 		if setResults != nil {
 			for _, revResult := range setResults.All() {
 				results.add(revResult)
 			}
 		}
 	}
+	if err == nil && g.Recursive() && !g.mirror {
+		var subResults Resulter
+		subResults, err = gitUpdateSubmodules(g)
+		for _, subResult := range subResults.All() {
+			results.add(subResult)
+		}
+	}
 	return results, err
 }
 
+// gitCacheLockPoll is how often a busy git cache mirror lock is retried
+const gitCacheLockPoll = 100 * time.Millisecond
+
+// gitCacheMirror brings the mirror clone of the getter remote in the getter
+// cache dir up to date, cloning it if not there yet, using the standard git
+// mirror get/update (see GitGet).  The mirror is named after the remote
+// (and a hash of the remote URL to keep it unique).  A lock file next to
+// the mirror makes sure only one Get() (in any process) clones or updates
+// it at a time (exclusive lock), once up to date the lock is turned into a
+// shared lock that the caller holds while cloning with the mirror objects
+// (--reference) so no update or reclone of the mirror happens under such a
+// clone, close the returned lock file to release it.  A new mirror is cloned to a temp path and renamed into
+// place when done, a mirror that isn't a valid bare repo (eg: left by an
+// older clone that was killed) or whose update finds it corrupt is removed
+// and cloned again.  Params:
+//	g (*GitGetter): the getter with the remote and cache dir
+// Returns the path to the mirror clone, the (shared) lock file for it (nil
+// on error), results (vcs cmds run, output) and any error that occurred
+func gitCacheMirror(g *GitGetter) (string, *os.File, Resulter, error) {
+	results := newResults()
+	if err := os.MkdirAll(g.cache, 0755); err != nil {
+		return "", nil, results, out.WrapErrf(err, 4553, "Unable to create git cache dir: %s", g.cache)
+	}
+	sum := sha256.Sum256([]byte(g.Remote()))
+	name := strings.TrimSuffix(filepath.Base(g.Remote()), ".git")
+	cachePath := filepath.Join(g.cache, fmt.Sprintf("%s-%s.git", name, hex.EncodeToString(sum[:])[:12]))
+	lock, err := gitCacheLock(g.Context(), cachePath)
+	if err != nil {
+		return "", nil, results, err
+	}
+	cachePath, err = gitCacheUpdate(g, cachePath, results)
+	if err == nil {
+		err = gitCacheFlock(g.Context(), lock, syscall.LOCK_SH)
+	}
+	if err != nil {
+		lock.Close() // releases the lock
+		return cachePath, nil, results, err
+	}
+	return cachePath, lock, results, nil
+}
+
+// gitCacheUpdate updates or (re)clones the git cache mirror at the given
+// path for gitCacheMirror, with its lock held, adding the cmds run to the
+// given results.  Returns the mirror path and any error that occurred.
+func gitCacheUpdate(g *GitGetter, cachePath string, results *Results) (string, error) {
+	if _, err := os.Stat(cachePath); err == nil {
+		result, err := run(g.Context(), gitTool, "--git-dir", cachePath, "rev-parse", "--is-bare-repository")
+		results.add(result)
+		if err == nil && strings.TrimSpace(result.Stdout) == "true" {
+			updResults, err := GitGet(gitCacheGetter(g, cachePath))
+			for _, updResult := range updResults.All() {
+				results.add(updResult)
+			}
+			if !errors.Is(err, ErrCorrupt) {
+				return cachePath, err
+			}
+		}
+		if err = os.RemoveAll(cachePath); err != nil {
+			return "", out.WrapErrf(err, 4566, "Unable to remove invalid git cache mirror: %s", cachePath)
+		}
+	}
+	// We hold the lock so any temp clone is one that was cut short
+	tempPath := cachePath + ".tmp"
+	os.RemoveAll(tempPath)
+	cloneResults, err := GitGet(gitCacheGetter(g, tempPath))
+	for _, cloneResult := range cloneResults.All() {
+		results.add(cloneResult)
+	}
+	if err == nil {
+		if err = os.Rename(tempPath, cachePath); err != nil {
+			err = out.WrapErrf(err, 4567, "Unable to move git cache mirror clone into place: %s", cachePath)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(tempPath)
+	}
+	return cachePath, err
+}
+
+// gitCacheGetter returns a mirror getter for the remote of the given getter
+// with the given local path, running its cmds with the getter context
+func gitCacheGetter(g *GitGetter, localPath string) *GitGetter {
+	mirror := &GitGetter{mirror: true}
+	mirror.setDescription(g.Remote(), "origin", localPath, g.Schemes(), Git)
+	mirror.SetContext(g.Context())
+	return mirror
+}
+
+// gitCacheLock takes the (exclusive) lock for the git cache mirror at the
+// given path, a lock on a file next to it so it works across processes (see
+// gitCacheFlock).  Close the returned lock file to release the lock.
+func gitCacheLock(ctx context.Context, cachePath string) (*os.File, error) {
+	lockPath := cachePath + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, out.WrapErrf(err, 4568, "Unable to open git cache mirror lock file: %s", lockPath)
+	}
+	if err = gitCacheFlock(ctx, lock, syscall.LOCK_EX); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return lock, nil
+}
+
+// gitCacheFlock takes the given lock (syscall.LOCK_EX or LOCK_SH) on the git
+// cache mirror lock file, turning any lock already held into it.  If the
+// lock is busy it is retried until the ctx is canceled or its deadline
+// passes.  Returns any error that occurred.
+func gitCacheFlock(ctx context.Context, lock *os.File, how int) error {
+	for {
+		err := syscall.Flock(int(lock.Fd()), how|syscall.LOCK_NB)
+		if err == nil {
+			return nil
+		}
+		if err != syscall.EWOULDBLOCK && err != syscall.EINTR {
+			return out.WrapErrf(err, 4568, "Unable to lock git cache mirror lock file: %s", lock.Name())
+		}
+		select {
+		case <-ctx.Done():
+			ctxErr := ErrCanceled
+			if ctx.Err() == context.DeadlineExceeded {
+				ctxErr = ErrTimeout
+			}
+			return out.WrapErrf(ctxErr, 4569, "Gave up waiting for git cache mirror lock: %s", lock.Name())
+		case <-time.After(gitCacheLockPoll):
+		}
+	}
+}
+
+// gitCloneArgs returns the git clone options to use for the given getter
+// clone options (none if nil), note that git makes a shallow clone a single
+// branch clone by default so that is turned off unless asked for
+func gitCloneArgs(opts *GitCloneOptions) []string {
+	var args []string
+	if opts == nil {
+		return args
+	}
+	shallow := false
+	if opts.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(opts.Depth))
+		shallow = true
+	}
+	if !opts.ShallowSince.IsZero() {
+		args = append(args, "--shallow-since="+opts.ShallowSince.Format(time.RFC3339))
+		shallow = true
+	}
+	if opts.SingleBranch {
+		args = append(args, "--single-branch")
+	} else if shallow {
+		args = append(args, "--no-single-branch")
+	}
+	if opts.Branch != "" {
+		args = append(args, "--branch", opts.Branch)
+	}
+	if opts.Filter != "" {
+		args = append(args, "--filter="+opts.Filter)
+	}
+	return args
+}
+
+// gitDeepenStep is the number of commits a shallow clone is deepened by on
+// the first pass looking for a missing rev, it doubles on each later pass
+const gitDeepenStep = 32
+
+// gitDeepen makes sure a shallow clone has the given rev and enough history
+// to connect it with the checked out history (so it can be checked out or
+// merged), the clone is deepened until that is so or it's no longer shallow.
+// Nothing is run for a clone that isn't shallow.  Params:
+//	d (Describer): describes the local clone and remote to deepen from
+//	rev (Rev): the revision needed (sha, tag or remote branch name)
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func gitDeepen(d Describer, rev Rev) (Resulter, error) {
+	results := newResults()
+	runOpt := "-C"
+	runDir := d.LocalRepoPath()
+	target := string(rev)
+	for depth := gitDeepenStep; isShallowRepo(runDir); depth *= 2 {
+		result, err := run(d.Context(), gitTool, runOpt, runDir, "merge-base", "HEAD", target)
+		results.add(result)
+		if err == nil { // have the rev and a common ancestor, deep enough
+			break
+		}
+		deepen := fmt.Sprintf("--deepen=%d", depth)
+		result, err = run(d.Context(), gitTool, runOpt, runDir, "fetch", deepen, d.RemoteRepoName(), string(rev))
+		results.add(result)
+		if err != nil {
+			return results, err
+		}
+		target = "FETCH_HEAD"
+	}
+	return results, nil
+}
+
 // gitUpdateRefs is fired if GitUpdate() gets specific refs to operate
 // on... meaning fetch or delete ops (at this point).  Params:
 //	u (*GitUpdater): has all the data we need to run the update
@@ -240,21 +513,21 @@ func gitUpdateRefs(u *GitUpdater) (Resulter, error) {
 		var result *Result
 		switch refOp {
 		case RefDelete:
-			result, err = run(gitTool, runOpt, runDir, "update-ref", "-d", ref)
+			result, err = run(u.Context(), gitTool, runOpt, runDir, "update-ref", "-d", ref)
 			results.add(result)
 		case RefFetch:
 			if u.mirror { // request is to mirror refs exactly, do so
 				refSpec := fmt.Sprintf("+%s:%s", ref, ref)
-				result, err = run(gitTool, runOpt, runDir, "fetch", u.RemoteRepoName(), refSpec)
+				result, err = run(u.Context(), gitTool, runOpt, runDir, "fetch", u.RemoteRepoName(), refSpec)
 			} else { // normal fetch requested, heads remapped, all else comes in "as-is"
 				m := refsRegex.FindStringSubmatch(ref) // look for refs/heads/<name> refs
 				if m[1] != "" {                        // if it was a refs/heads then map it:
 					remoteRef := fmt.Sprintf("refs/remotes/%s/%s", u.RemoteRepoName(), m[1])
 					refSpec := fmt.Sprintf("+%s:%s", ref, remoteRef)
-					result, err = run(gitTool, runOpt, runDir, "fetch", u.RemoteRepoName(), refSpec)
+					result, err = run(u.Context(), gitTool, runOpt, runDir, "fetch", u.RemoteRepoName(), refSpec)
 				} else { // bring in tags/etc under the same namespace
 					refSpec := fmt.Sprintf("+%s:%s", ref, ref)
-					result, err = run(gitTool, runOpt, runDir, "fetch", u.RemoteRepoName(), refSpec)
+					result, err = run(u.Context(), gitTool, runOpt, runDir, "fetch", u.RemoteRepoName(), refSpec)
 				}
 			}
 			results.add(result)
@@ -266,7 +539,9 @@ func gitUpdateRefs(u *GitUpdater) (Resulter, error) {
 }
 
 // GitUpdate performs a git fetch and merge to an existing checkout (ie:
-// a git pull).  Params:
+// a git pull).  If a rev is given and the clone is shallow the history is
+// deepened as needed to bring in the rev (see gitDeepen), if recursive the
+// submodules are updated after the pull (see gitUpdateSubmodules).  Params:
 //	u (*GitUpdater): git upd struct, gives kind of update needed, stores cmds run
 //	rev (Rev): optional; revision to update to (if given only 1 used)
 // Returns results (vcs cmds run, output) and any error that may have occurred
@@ -290,9 +565,9 @@ func GitUpdate(u *GitUpdater, rev ...Rev) (Resulter, error) {
 	runDir := u.LocalRepoPath()
 	var result *Result
 	if u.mirror {
-		result, err = run(gitTool, runOpt, runDir, "remote", "update", "--prune", u.RemoteRepoName())
+		result, err = run(u.Context(), gitTool, runOpt, runDir, "remote", "update", "--prune", u.RemoteRepoName())
 	} else {
-		result, err = run(gitTool, runOpt, runDir, "fetch", u.RemoteRepoName())
+		result, err = run(u.Context(), gitTool, runOpt, runDir, "fetch", u.RemoteRepoName())
 	}
 	results.add(result)
 	if err != nil {
@@ -320,12 +595,29 @@ func GitUpdate(u *GitUpdater, rev ...Rev) (Resulter, error) {
 		default: // likely RebaseUser, meaning don't provide any rebase opt
 		}
 		var pullResult *Result
+		if rev != nil && rev[0] != "" { // a shallow clone may need deepening first
+			var deepenResults Resulter
+			deepenResults, err = gitDeepen(u, rev[0])
+			for _, deepenResult := range deepenResults.All() {
+				results.add(deepenResult)
+			}
+			if err != nil {
+				return results, err
+			}
+		}
 		if rev == nil || (rev != nil && rev[0] == "") {
-			pullResult, err = run(gitTool, runOpt, runDir, "pull", rebaseStr, u.RemoteRepoName())
+			pullResult, err = run(u.Context(), gitTool, runOpt, runDir, "pull", rebaseStr, u.RemoteRepoName())
 		} else { // if user asks for a specific version on pull, use that
-			pullResult, err = run(gitTool, runOpt, runDir, "pull", rebaseStr, u.RemoteRepoName(), string(rev[0]))
+			pullResult, err = run(u.Context(), gitTool, runOpt, runDir, "pull", rebaseStr, u.RemoteRepoName(), string(rev[0]))
 		}
 		results.add(pullResult)
+		if err == nil && u.Recursive() {
+			var subResults Resulter
+			subResults, err = gitUpdateSubmodules(u)
+			for _, subResult := range subResults.All() {
+				results.add(subResult)
+			}
+		}
 	}
 	return results, err
 }
@@ -339,7 +631,7 @@ func GitRevSet(r RevSetter, rev Rev) (Resulter, error) {
 	runOpt := "-C"
 	runDir := r.LocalRepoPath()
 	results := newResults()
-	result, err := run(gitTool, runOpt, runDir, "checkout", string(rev))
+	result, err := run(r.Context(), gitTool, runOpt, runDir, "checkout", string(rev))
 	results.add(result)
 	return results, err
 }
@@ -348,11 +640,13 @@ func GitRevSet(r RevSetter, rev Rev) (Resulter, error) {
 // pointer is returned (how filled out depends upon if the read is just the
 // basic core/raw VCS revision or full data for the given VCS which will
 // include tags, branches, timestamp info, author/committer, date, comment).
-// Note: this reads one version but that could be expanded to take <N>
-// revisions or a range, eg GitRevRead(reader, <scope>, rev1, "..", rev2),
-// without changing this methods params or return signature (but code
-// changes  would be needed)
+// A range of revisions may also be given, eg: GitRevRead(reader, <scope>,
+// rev1, "..", rev2), which is the same as GitRevLog(reader, <scope>, rev1,
+// rev2, 0) (see that routine for details).
 func GitRevRead(r RevReader, scope ReadScope, vcsRev ...Rev) ([]Revisioner, Resulter, error) {
+	if from, to, ok := revRange(vcsRev); ok {
+		return GitRevLog(r, scope, from, to, 0)
+	}
 	results := newResults()
 	runOpt := "-C"
 	runDir := r.LocalRepoPath()
@@ -367,37 +661,958 @@ func GitRevRead(r RevReader, scope ReadScope, vcsRev ...Rev) ([]Revisioner, Resu
 	if scope == CoreRev {
 		// client just wants the core/base VCS revision only..
 		if specificRev != "" {
-			result, err = run(gitTool, runOpt, runDir, "log", "-1", "--format=%H", specificRev)
+			result, err = run(r.Context(), gitTool, runOpt, runDir, "log", "-1", "--format=%H", specificRev)
 		} else {
-			result, err = run(gitTool, runOpt, runDir, "log", "-1", "--format=%H")
+			result, err = run(r.Context(), gitTool, runOpt, runDir, "log", "-1", "--format=%H")
 		}
 		results.add(result)
 		if err != nil {
 			return nil, results, err
 		}
-		rev.SetCore(Rev(strings.TrimSpace(result.Output)))
+		rev.SetCore(Rev(strings.TrimSpace(result.Stdout)))
 		revs = append(revs, rev)
 	} else {
-		//FIXME: correct the full data one to run something like this:
-		//% git log -1 --format='%H [%cD]%d'
-		//a862506d017d643091368d53128447d032a03f54 [Thu, 11 Sep 2014 17:45:32 -0700] (HEAD -> topic, tag: main/7353, tag: acme__main__new__1410482753, origin/main, origin/HEAD)
-		//should also add author+authorid+committer+committerid and then add in the
-		//revision comment on the line following that data
+		// client wants all the data we can get on the revision, note that
+		// full ref names are used so branches and tags can be told apart
 		if specificRev != "" {
-			result, err = run(gitTool, runOpt, runDir, "log", "-1", "--format=%H", specificRev)
+			result, err = run(r.Context(), gitTool, runOpt, runDir, "log", "-1", "--decorate=full", gitRevFormat, specificRev)
 		} else {
-			result, err = run(gitTool, runOpt, runDir, "log", "-1", "--format=%H")
+			result, err = run(r.Context(), gitTool, runOpt, runDir, "log", "-1", "--decorate=full", gitRevFormat)
 		}
 		results.add(result)
 		if err != nil {
 			return nil, results, err
 		}
-		rev.SetCore(Rev(strings.TrimSpace(result.Output)))
-		revs = append(revs, rev)
+		revs, err = gitParseRevs(result.Stdout, r.SemVerPrefix())
+		if err != nil {
+			return nil, results, err
+		}
 	}
 	return revs, results, nil
 }
 
+// GitRevLog retrieves the revisions reachable from the 'to' rev but not from
+// the 'from' rev (ie: git log from..to) in topological order, newest first.
+// Params:
+//	r (Describer): describes the local repo to read the history from
+//	scope (ReadScope): CoreRev for just the sha1's, AllData for full revisions
+//	from (Rev): start of the range (excluded), "" to walk all history
+//	to (Rev): end of the range (included), "" for the current rev (HEAD)
+//	max (int): max number of revisions to return, 0 for no limit
+//	paths (...string): optional; only revisions changing these paths (relative
+//	                   to the repo root) are returned
+// Returns the revisions, results (vcs cmds run, output) and any error
+func GitRevLog(r Describer, scope ReadScope, from, to Rev, max int, paths ...string) ([]Revisioner, Resulter, error) {
+	results := newResults()
+	logRange := string(to)
+	if logRange == "" {
+		logRange = "HEAD"
+	}
+	if from != "" {
+		logRange = fmt.Sprintf("%s..%s", from, logRange)
+	}
+	maxOpt := ""
+	if max > 0 {
+		maxOpt = fmt.Sprintf("--max-count=%d", max)
+	}
+	format := "--format=%H"
+	decorateOpt := ""
+	if scope != CoreRev {
+		format = gitRevFormat
+		decorateOpt = "--decorate=full"
+	}
+	args := []string{"-C", r.LocalRepoPath(), "log", "--topo-order", maxOpt, decorateOpt, format, logRange, "--"}
+	args = append(args, paths...)
+	result, err := run(r.Context(), gitTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var revs []Revisioner
+	if scope == CoreRev {
+		for _, sha := range strings.Fields(result.Stdout) {
+			rev := &Revision{}
+			rev.SetCore(Rev(sha))
+			revs = append(revs, rev)
+		}
+		return revs, results, nil
+	}
+	revs, err = gitParseRevs(result.Stdout, r.SemVerPrefix())
+	if err != nil {
+		return nil, results, err
+	}
+	return revs, results, nil
+}
+
+// gitParseRevs takes the output from a 'git log --decorate=full' run using
+// the gitRevFormat format and turns each revision record found into a fully
+// populated Revision (core rev, author/committer, timestamps, comment, tags,
+// semvers, ie: tags with the given semver prefix, and branches).  Revisions
+// are returned in the order git listed them along with any error seen
+// parsing the output.
+func gitParseRevs(output, semVerPrefix string) ([]Revisioner, error) {
+	var revs []Revisioner
+	for _, record := range strings.Split(output, "\x1e") {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+		fields := strings.Split(record, "\x1f")
+		if len(fields) != 9 {
+			return nil, out.NewErrf(4515, "Unable to parse git revision data, unexpected format:\n%s", record)
+		}
+		rev := &Revision{}
+		rev.SetCore(Rev(fields[0]))
+		rev.SetUserInfo(Author, fields[1], fields[2])
+		rev.SetUserInfo(Committer, fields[4], fields[5])
+		for _, tstamp := range []struct {
+			utype UserType
+			secs  string
+		}{{Author, fields[3]}, {Committer, fields[6]}} {
+			secs, err := strconv.ParseInt(tstamp.secs, 10, 64)
+			if err != nil {
+				return nil, out.WrapErrf(err, 4516, "Unable to parse git %s timestamp for revision %s", tstamp.utype, fields[0])
+			}
+			t := time.Unix(secs, 0)
+			rev.SetTStamp(tstamp.utype, &t)
+		}
+		tags, branches := gitParseDecorations(fields[7])
+		semVers, tags := splitSemVers(semVerPrefix, tags)
+		rev.SetSemVers(semVers)
+		rev.SetTags(tags)
+		rev.SetBranches(branches)
+		rev.SetComment(strings.TrimRight(fields[8], "\n"))
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// gitParseDecorations takes full ref name decorations from git (ie: the %D
+// format with --decorate=full) and returns the tags and branches found in
+// it (in that order).  Local branches are returned as their short name and
+// remote tracking branches as "<remote>/<branch>", symbolic HEAD refs are
+// skipped, eg: "HEAD -> refs/heads/topic, tag: refs/tags/v1.0.0,
+// refs/remotes/origin/main, refs/remotes/origin/HEAD" results in tags of
+// "v1.0.0" and branches of "topic" and "origin/main".
+func gitParseDecorations(decorations string) ([]Rev, []Rev) {
+	var tags, branches []Rev
+	for _, ref := range strings.Split(decorations, ", ") {
+		ref = strings.TrimSpace(strings.TrimPrefix(ref, "HEAD -> "))
+		switch {
+		case strings.HasPrefix(ref, "tag: refs/tags/"):
+			tags = append(tags, Rev(strings.TrimPrefix(ref, "tag: refs/tags/")))
+		case strings.HasPrefix(ref, "refs/heads/"):
+			branches = append(branches, Rev(strings.TrimPrefix(ref, "refs/heads/")))
+		case strings.HasPrefix(ref, "refs/remotes/") && !strings.HasSuffix(ref, "/HEAD"):
+			branches = append(branches, Rev(strings.TrimPrefix(ref, "refs/remotes/")))
+		}
+	}
+	return tags, branches
+}
+
+// GitRevCommit makes sure the tags, semvers and local branches stored in the
+// given revision all point at the revision's core rev in the local clone,
+// creating or moving them as needed (remote tracking branches such as
+// "origin/master" are skipped).  Any refs created or moved are remembered
+// so a following GitRevPush() can publish them.  Params:
+//	w (*GitRevWriter): the git rev writer (local clone, remote name, etc)
+//	rev (*Revision): revision with the core rev and refs that should exist
+// Returns results (vcs cmds run, output) and any error that may have occurred
+// Note: a branch that is checked out can't be moved, git will fail the op
+func GitRevCommit(w *GitRevWriter, rev *Revision) (Resulter, error) {
+	results := newResults()
+	if rev == nil || rev.Core() == "" {
+		return results, out.NewErrf(4525, "Git revision commit requires a core revision, clone: %s", w.LocalRepoPath())
+	}
+	runOpt := "-C"
+	runDir := w.LocalRepoPath()
+	result, err := run(w.Context(), gitTool, runOpt, runDir, "log", "-1", "--decorate=full", gitRevFormat, string(rev.Core()))
+	results.add(result)
+	if err != nil {
+		return results, err
+	}
+	current, err := gitParseRevs(result.Stdout, w.SemVerPrefix())
+	if err != nil {
+		return results, err
+	}
+	if len(current) != 1 {
+		return results, out.NewErrf(4526, "Git revision commit unable to find revision %s, clone: %s", rev.Core(), w.LocalRepoPath())
+	}
+	sha := string(current[0].Core())
+	existing := make(map[Rev]bool)
+	for _, ref := range current[0].Tags() {
+		existing[ref] = true
+	}
+	for _, ref := range current[0].SemVers() {
+		existing[ref] = true
+	}
+	for _, ref := range current[0].Branches() {
+		existing[ref] = true
+	}
+
+	for _, tags := range [][]Rev{rev.Tags(), rev.SemVers()} {
+		for _, tag := range tags {
+			if existing[tag] {
+				continue
+			}
+			refName := "refs/tags/" + string(tag)
+			// if the tag exists elsewhere it is being moved, needs a forced push
+			refSpec := fmt.Sprintf("%s:%s", refName, refName)
+			if _, err = run(w.Context(), gitTool, runOpt, runDir, "rev-parse", "-q", "--verify", refName); err == nil {
+				refSpec = "+" + refSpec
+			}
+			result, err = run(w.Context(), gitTool, runOpt, runDir, "tag", "-f", string(tag), sha)
+			results.add(result)
+			if err != nil {
+				return results, err
+			}
+			w.addPushRef(refSpec)
+		}
+	}
+	for _, branch := range rev.Branches() {
+		if existing[branch] || strings.HasPrefix(string(branch), w.RemoteRepoName()+"/") {
+			continue
+		}
+		result, err = run(w.Context(), gitTool, runOpt, runDir, "branch", "-f", string(branch), sha)
+		results.add(result)
+		if err != nil {
+			return results, err
+		}
+		refName := "refs/heads/" + string(branch)
+		w.addPushRef(fmt.Sprintf("%s:%s", refName, refName))
+	}
+	return results, nil
+}
+
+// GitRevPush publishes any refs created or moved by GitRevCommit() to the
+// remote repo (via the remote name, eg: "origin").  Tags that were moved
+// are force pushed, branches are not (so the remote must fast-forward).
+// If there is nothing to publish no git cmds are run.  Params:
+//	w (*GitRevWriter): the git rev writer (local clone, remote name, etc)
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func GitRevPush(w *GitRevWriter) (Resulter, error) {
+	results := newResults()
+	if len(w.pushRefs) == 0 {
+		return results, nil
+	}
+	args := []string{"-C", w.LocalRepoPath(), "push", w.RemoteRepoName()}
+	args = append(args, w.pushRefs...)
+	result, err := run(w.Context(), gitTool, args...)
+	results.add(result)
+	if err == nil {
+		w.pushRefs = nil
+	}
+	return results, err
+}
+
+// GitCommit stages the given paths (or all changes, including new and
+// removed files, if no paths given) and commits them with the comment,
+// author and committer identity and timestamps from the given revision (the
+// git config and current time are used for any not set).  Params:
+//	c (Describer): describes the local repo to commit in
+//	rev (*Revision): the comment (required), user info and timestamps to use
+//	paths (...string): optional; only commit these paths (relative to the repo)
+// Returns the new revision, results (vcs cmds run, output) and any error
+func GitCommit(c Describer, rev *Revision, paths ...string) (Revisioner, Resulter, error) {
+	results := newResults()
+	if err := checkCommitRev(c, rev); err != nil {
+		return nil, results, err
+	}
+	runOpt := "-C"
+	runDir := c.LocalRepoPath()
+	args := append([]string{runOpt, runDir, "add", "-A", "--"}, paths...)
+	result, err := run(c.Context(), gitTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var env []string
+	for _, user := range []struct {
+		utype UserType
+		key   string
+	}{{Author, "AUTHOR"}, {Committer, "COMMITTER"}} {
+		// only what's set, git takes the rest from the config (an empty
+		// name or email would be used as is)
+		name, id := rev.UserInfo(user.utype)
+		if name != "" {
+			env = append(env, "GIT_"+user.key+"_NAME="+name)
+		}
+		if id != "" {
+			env = append(env, "GIT_"+user.key+"_EMAIL="+id)
+		}
+		if tstamp := rev.TStamp(user.utype); tstamp != nil {
+			env = append(env, fmt.Sprintf("GIT_%s_DATE=%d %s", user.key, tstamp.Unix(), tstamp.Format("-0700")))
+		}
+	}
+	args = []string{runOpt, runDir, "commit", "-q", "-m", rev.Comment()}
+	if paths != nil {
+		args = append(append(args, "--"), paths...)
+	}
+	result, err = runWithEnv(c.Context(), env, gitTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	revs, logResults, err := GitRevLog(c, AllData, "", "", 1)
+	for _, logResult := range logResults.All() {
+		results.add(logResult)
+	}
+	if err != nil || len(revs) == 0 {
+		return nil, results, err
+	}
+	return revs[0], results, nil
+}
+
+// GitDiff returns the parsed diff between two revisions, or a revision and
+// the working tree (tracked files only, staged or not), see the Differ
+// interface for details.  Params:
+//	r (Describer): describes the local repo to diff
+//	from (Rev): the old revision, "" for the current revision (HEAD)
+//	to (Rev): the new revision, "" for the working tree
+//	paths (...string): optional; only diff these paths (relative to the repo)
+// Returns the file diffs, results (vcs cmds run, output) and any error
+func GitDiff(r Describer, from, to Rev, paths ...string) ([]*FileDiff, Resulter, error) {
+	results := newResults()
+	if from == "" {
+		from = "HEAD"
+	}
+	args := []string{"-C", r.LocalRepoPath(), "-c", "core.quotePath=false", "diff", "--no-color", "--no-ext-diff",
+		"--no-textconv", "--src-prefix=a/", "--dst-prefix=b/", "-M", string(from), string(to), "--"}
+	args = append(args, paths...)
+	result, err := run(r.Context(), gitTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	files, err := parseDiff(result.Stdout)
+	return files, results, err
+}
+
+// GitStatus reads the status of the workspace: the files with local changes
+// (staged or not), untracked files and merge conflicts, along with how many
+// commits the current branch is ahead of and behind its tracking branch on
+// the remote (RemoteRepoName).  The tracking branch is the configured
+// upstream if it is on that remote, otherwise the same named branch there.
+// If there is no tracking branch (eg: detached HEAD) Ahead is the number of
+// commits not on any branch of the remote and Behind is 0.  No network
+// access is done so the counts are as of the last fetch.  Params:
+//	s (Describer): describes the local repo to get the status of
+// Returns the status, results (vcs cmds run, output) and any error
+func GitStatus(s Describer) (*Status, Resulter, error) {
+	results := newResults()
+	runOpt := "-C"
+	runDir := s.LocalRepoPath()
+	result, err := run(s.Context(), gitTool, runOpt, runDir, "status", "--porcelain=v2", "--branch", "-z", "--untracked-files=all")
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	status, branch, err := gitParseStatus(result.Stdout)
+	if err != nil || branch.oid == "(initial)" { // no commits, nothing to count
+		return status, results, err
+	}
+	remoteName := s.RemoteRepoName()
+	if branch.upstream != "" && strings.HasPrefix(branch.upstream, remoteName+"/") {
+		status.Ahead, status.Behind = branch.ahead, branch.behind
+		return status, results, nil
+	}
+	tracking := ""
+	if branch.head != "(detached)" {
+		ref := "refs/remotes/" + remoteName + "/" + branch.head
+		result, err = run(s.Context(), gitTool, runOpt, runDir, "rev-parse", "-q", "--verify", ref)
+		results.add(result)
+		if err == nil {
+			tracking = ref
+		}
+	}
+	if tracking == "" {
+		result, err = run(s.Context(), gitTool, runOpt, runDir, "rev-list", "--count", "HEAD", "--not", "--remotes="+remoteName)
+	} else {
+		result, err = run(s.Context(), gitTool, runOpt, runDir, "rev-list", "--left-right", "--count", "HEAD..."+tracking)
+	}
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	counts := strings.Fields(result.Stdout)
+	if len(counts) == 2 {
+		status.Behind, err = strconv.Atoi(counts[1])
+	}
+	if err == nil && len(counts) != 0 {
+		status.Ahead, err = strconv.Atoi(counts[0])
+	}
+	if err != nil || len(counts) == 0 {
+		return nil, results, out.NewErrf(4537, "Unable to parse git commit counts from: \"%s\"", result.Stdout)
+	}
+	return status, results, nil
+}
+
+// gitBranchStatus has the branch details from 'git status --branch' output
+type gitBranchStatus struct {
+	oid, head, upstream string
+	ahead, behind       int
+}
+
+// gitParseStatus parses 'git status --porcelain=v2 --branch -z' output into
+// the workspace file status list and the branch details
+func gitParseStatus(output string) (*Status, *gitBranchStatus, error) {
+	status := &Status{}
+	branch := &gitBranchStatus{}
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+		var fields []string
+		switch entry[0] {
+		case '#':
+			fields = strings.Fields(entry)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				branch.oid = fields[2]
+			case "branch.head":
+				branch.head = fields[2]
+			case "branch.upstream":
+				branch.upstream = fields[2]
+			case "branch.ab":
+				if len(fields) == 4 {
+					branch.ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+					branch.behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+				}
+			}
+			continue
+		case '1': // changed: 1 XY sub mH mI mW hH hI path
+			if fields = strings.SplitN(entry, " ", 9); len(fields) == 9 {
+				status.Files = append(status.Files, FileStatus{Path: fields[8], State: gitFileState(fields[1])})
+				continue
+			}
+		case '2': // renamed or copied: 2 XY sub mH mI mW hH hI score path, then orig path
+			if fields = strings.SplitN(entry, " ", 10); len(fields) == 10 && i+1 < len(entries) {
+				i++
+				status.Files = append(status.Files, FileStatus{Path: fields[9], OrigPath: entries[i], State: gitFileState(fields[1])})
+				continue
+			}
+		case 'u': // unmerged: u XY sub m1 m2 m3 mW h1 h2 h3 path
+			if fields = strings.SplitN(entry, " ", 11); len(fields) == 11 {
+				status.Files = append(status.Files, FileStatus{Path: fields[10], State: FileConflicted})
+				continue
+			}
+		case '?':
+			if len(entry) > 2 {
+				status.Files = append(status.Files, FileStatus{Path: entry[2:], State: FileUntracked})
+				continue
+			}
+		case '!': // ignored file
+			continue
+		}
+		return nil, nil, out.NewErrf(4536, "Unable to parse git status output, unexpected entry: %q", entry)
+	}
+	return status, branch, nil
+}
+
+// gitFileState maps the git status XY code (index and worktree states) of a
+// changed file to its FileState, the index state wins if there is one
+func gitFileState(xy string) FileState {
+	code := byte('.')
+	if len(xy) == 2 {
+		code = xy[0]
+		if code == '.' {
+			code = xy[1]
+		}
+	}
+	switch code {
+	case 'A', 'C':
+		return FileAdded
+	case 'D':
+		return FileDeleted
+	case 'R':
+		return FileRenamed
+	}
+	return FileModified
+}
+
+// GitPush publishes local refs to the remote (the options RemoteName, or
+// the RemoteRepoName, eg: "origin"), see PushOptions for the refspecs, tags,
+// force, force-with-lease and atomic settings.  For a lease each remote ref
+// is expected at the given rev, an empty rev expects the ref not to exist.
+// The ref statuses come from the push porcelain output, if any ref is
+// rejected they are returned along with the push error.  Params:
+//	p (Describer): describes the local repo to push from
+//	opts (*PushOptions): what to push and how, nil for the git defaults
+// Returns the ref statuses, results (vcs cmds run, output) and any error
+func GitPush(p Describer, opts *PushOptions) ([]*RefPushStatus, Resulter, error) {
+	results := newResults()
+	if opts == nil {
+		opts = &PushOptions{}
+	}
+	args := []string{"-C", p.LocalRepoPath(), "push", "--porcelain"}
+	if opts.Force {
+		args = append(args, "--force")
+	}
+	if opts.ForceWithLease {
+		if len(opts.Lease) == 0 {
+			args = append(args, "--force-with-lease")
+		}
+		refs := make([]string, 0, len(opts.Lease))
+		for ref := range opts.Lease {
+			refs = append(refs, ref)
+		}
+		sort.Strings(refs)
+		for _, ref := range refs {
+			args = append(args, fmt.Sprintf("--force-with-lease=%s:%s", ref, opts.Lease[ref]))
+		}
+	}
+	if opts.Atomic {
+		args = append(args, "--atomic")
+	}
+	if opts.Tags {
+		args = append(args, "--tags")
+	}
+	args = append(append(args, pushRemoteName(p, opts)), opts.Refs...)
+	result, err := run(p.Context(), gitTool, args...)
+	results.add(result)
+	statuses, parseErr := gitParsePush(result.Stdout)
+	if err == nil {
+		err = parseErr
+	}
+	return statuses, results, err
+}
+
+// gitParsePush parses 'git push --porcelain' output into the ref statuses,
+// each ref line is "<flag>\t<from>:<to>\t<summary>[ (<reason>)]"
+func gitParsePush(output string) ([]*RefPushStatus, error) {
+	var statuses []*RefPushStatus
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" || line == "Done" || strings.HasPrefix(line, "To ") {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		var refs []string
+		if len(fields) == 3 && len(fields[0]) == 1 {
+			refs = strings.SplitN(fields[1], ":", 2)
+		}
+		if len(refs) != 2 {
+			return statuses, out.NewErrf(4543, "Unable to parse git push output, unexpected line: %q", line)
+		}
+		status := &RefPushStatus{Local: refs[0], Remote: refs[1]}
+		summary := fields[2]
+		if i := strings.Index(summary, " ("); i >= 0 && strings.HasSuffix(summary, ")") {
+			status.Reason = summary[i+2 : len(summary)-1]
+			summary = summary[:i]
+		}
+		switch fields[0] {
+		case " ":
+			status.State = PushUpdated
+		case "+":
+			status.State = PushForced
+		case "-":
+			status.State = PushDeleted
+		case "*":
+			status.State = PushNew
+		case "=":
+			status.State = PushUpToDate
+		case "!":
+			status.State = PushRejected
+		default:
+			return statuses, out.NewErrf(4543, "Unable to parse git push output, unexpected flag: %q", line)
+		}
+		if revs := strings.SplitN(summary, "..", 2); len(revs) == 2 { // "old..new" or "old...new" (forced)
+			status.OldRev, status.NewRev = Rev(revs[0]), Rev(strings.TrimPrefix(revs[1], "."))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// GitRefs lists the branches and tags of the local repo (refs/heads and
+// refs/tags, plus the remote branches of the remote name, refs/remotes/<remote
+// name>, as RefRemoteBranch refs) or of the remote (via ls-remote), annotated
+// tags are peeled to the commit they tag.  Params:
+//	r (Describer): describes the local repo and remote
+//	l (Location): LocalPath for the local repo refs, Remote for the remote refs
+// Returns the refs, results (vcs cmds run, output) and any error
+func GitRefs(r Describer, l Location) ([]*Ref, Resulter, error) {
+	results := newResults()
+	var result *Result
+	var err error
+	if l == LocalPath {
+		result, err = run(r.Context(), gitTool, "-C", r.LocalRepoPath(), "for-each-ref",
+			"--format=%(objectname)%09%(refname)%09%(*objectname)", "refs/heads", "refs/tags",
+			"refs/remotes/"+r.RemoteRepoName())
+	} else {
+		result, err = run(r.Context(), gitTool, "ls-remote", "--heads", "--tags", r.Remote())
+	}
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	return sortRefs(gitParseRefs(result.Stdout, r.RemoteRepoName())), results, nil
+}
+
+// gitParseRefs parses 'git for-each-ref' ("<sha>\t<ref>\t<peeled sha>") or
+// 'git ls-remote' ("<sha>\t<ref>", with "<sha>\t<tag>^{}" peeled tag lines)
+// output into the branch and tag refs, and the remote branches of the given
+// remote name (not its symbolic HEAD)
+func gitParseRefs(output, remoteName string) []*Ref {
+	remotePrefix := "refs/remotes/" + remoteName + "/"
+	var refs []*Ref
+	tags := make(map[string]*Ref)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		refName := fields[1]
+		if strings.HasSuffix(refName, "^{}") {
+			if ref, ok := tags[strings.TrimSuffix(refName, "^{}")]; ok {
+				ref.Rev = Rev(fields[0])
+			}
+			continue
+		}
+		ref := &Ref{Rev: Rev(fields[0])}
+		switch {
+		case strings.HasPrefix(refName, "refs/heads/"):
+			ref.Name, ref.Type = strings.TrimPrefix(refName, "refs/heads/"), RefBranch
+		case strings.HasPrefix(refName, "refs/tags/"):
+			ref.Name, ref.Type = strings.TrimPrefix(refName, "refs/tags/"), RefTag
+			tags[refName] = ref
+		case strings.HasPrefix(refName, remotePrefix) && refName != remotePrefix+"HEAD":
+			ref.Name, ref.Type = strings.TrimPrefix(refName, remotePrefix), RefRemoteBranch
+		default:
+			continue
+		}
+		if len(fields) > 2 && fields[2] != "" {
+			ref.Rev = Rev(fields[2])
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// GitSparse returns the sparse checkout paths of a git clone (in cone mode
+// these are directories), none if it isn't a sparse checkout.  Params:
+//	s (Describer): describes the local clone to read
+// Returns the sparse paths, results (vcs cmds run, output) and any error
+func GitSparse(s Describer) ([]string, Resulter, error) {
+	results := newResults()
+	runOpt := "-C"
+	runDir := s.LocalRepoPath()
+	result, err := run(s.Context(), gitTool, runOpt, runDir, "config", "--bool", "core.sparseCheckout")
+	results.add(result)
+	if err != nil && result.ExitCode == 1 { // not set, not a sparse checkout
+		return nil, results, nil
+	}
+	if err != nil || strings.TrimSpace(result.Stdout) != "true" {
+		return nil, results, err
+	}
+	result, err = run(s.Context(), gitTool, runOpt, runDir, "sparse-checkout", "list")
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var paths []string
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths, results, nil
+}
+
+// GitSetSparse replaces the sparse checkout paths of a git clone (cone mode)
+// and updates the working tree to match, later checkouts and pulls honor
+// them.  With no paths sparse checkout is turned off.  Params:
+//	s (Describer): describes the local clone to change
+//	paths (...string): the directories to check out (top level files always are)
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func GitSetSparse(s Describer, paths ...string) (Resulter, error) {
+	results := newResults()
+	runOpt := "-C"
+	runDir := s.LocalRepoPath()
+	var result *Result
+	var err error
+	if len(paths) == 0 {
+		result, err = run(s.Context(), gitTool, runOpt, runDir, "sparse-checkout", "disable")
+	} else {
+		args := append([]string{runOpt, runDir, "sparse-checkout", "set", "--cone"}, paths...)
+		result, err = run(s.Context(), gitTool, args...)
+	}
+	results.add(result)
+	return results, err
+}
+
+// GitWorktreeAdd adds a worktree checkout of the given rev (detached) to the
+// clone, typically a bare/mirror clone, so many checkouts share one object
+// store.  Params:
+//	w (Describer): describes the clone to add the worktree to
+//	path (string): where to put the new worktree checkout (a relative path is
+//	               relative to the current working dir, not to the clone)
+//	rev (Rev): revision to check out ("" for the HEAD of the clone)
+// Returns the new worktree, results (vcs cmds run, output) and any error
+func GitWorktreeAdd(w Describer, path string, rev Rev) (*GitWorktree, Resulter, error) {
+	results := newResults()
+	path, err := gitWorktreePath(path)
+	if err != nil {
+		return nil, results, err
+	}
+	runOpt := "-C"
+	runDir := w.LocalRepoPath()
+	result, err := run(w.Context(), gitTool, runOpt, runDir, "worktree", "add", "--detach", path, string(rev))
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	result, err = run(w.Context(), gitTool, runOpt, path, "rev-parse", "HEAD")
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	worktree := &GitWorktree{Path: path, Rev: Rev(strings.TrimSpace(result.Stdout))}
+	return worktree, results, nil
+}
+
+// GitWorktreeList lists the worktrees of a clone, the first is always the
+// main clone itself (Bare is set if it's a bare/mirror clone).  Params:
+//	w (Describer): describes the clone to list the worktrees of
+// Returns the worktrees, results (vcs cmds run, output) and any error
+func GitWorktreeList(w Describer) ([]*GitWorktree, Resulter, error) {
+	results := newResults()
+	result, err := run(w.Context(), gitTool, "-C", w.LocalRepoPath(), "worktree", "list", "--porcelain")
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	return gitParseWorktrees(result.Stdout), results, nil
+}
+
+// gitParseWorktrees parses 'git worktree list --porcelain' output, a
+// stanza of "<attr> [value]" lines per worktree, stanzas blank line split
+func gitParseWorktrees(output string) []*GitWorktree {
+	var worktrees []*GitWorktree
+	var worktree *GitWorktree
+	for _, line := range strings.Split(output, "\n") {
+		attr, value := line, ""
+		if i := strings.Index(line, " "); i != -1 {
+			attr, value = line[:i], line[i+1:]
+		}
+		if attr == "worktree" {
+			worktree = &GitWorktree{Path: value}
+			worktrees = append(worktrees, worktree)
+			continue
+		}
+		if worktree == nil {
+			continue
+		}
+		switch attr {
+		case "HEAD":
+			worktree.Rev = Rev(value)
+		case "branch":
+			worktree.Branch = strings.TrimPrefix(value, "refs/heads/")
+		case "bare":
+			worktree.Bare = true
+		case "locked":
+			worktree.Locked = true
+		case "prunable":
+			worktree.Prunable = true
+		}
+	}
+	return worktrees
+}
+
+// GitWorktreePrune cleans up the records of worktrees whose checkouts have
+// been deleted (ie: 'rm -rf' was used instead of GitWorktreeRemove).  Params:
+//	w (Describer): describes the clone to prune the worktrees of
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func GitWorktreePrune(w Describer) (Resulter, error) {
+	results := newResults()
+	result, err := run(w.Context(), gitTool, "-C", w.LocalRepoPath(), "worktree", "prune")
+	results.add(result)
+	return results, err
+}
+
+// GitWorktreeRemove removes a worktree checkout and its record in the clone,
+// git refuses if the worktree has local changes unless forced.  Params:
+//	w (Describer): describes the clone the worktree belongs to
+//	path (string): path of the worktree checkout to remove (a relative path is
+//	               relative to the current working dir, not to the clone)
+//	force (bool): remove the worktree even if it has local changes
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func GitWorktreeRemove(w Describer, path string, force bool) (Resulter, error) {
+	results := newResults()
+	path, err := gitWorktreePath(path)
+	if err != nil {
+		return results, err
+	}
+	forceOpt := ""
+	if force {
+		forceOpt = "--force"
+	}
+	result, err := run(w.Context(), gitTool, "-C", w.LocalRepoPath(), "worktree", "remove", forceOpt, path)
+	results.add(result)
+	return results, err
+}
+
+// gitWorktreePath returns the absolute path of the given worktree path, git
+// is run in the clone (-C) so a relative path would be taken relative to it
+func gitWorktreePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", out.WrapErrf(err, 4570, "Unable to find the absolute path of git worktree: %s", path)
+	}
+	return absPath, nil
+}
+
+// GitNested returns the submodules of a git clone with the commit each is
+// pinned to and its remote from .gitmodules (none if there's no such file).
+// For a clone with a work tree these come from the index and the checked
+// out .gitmodules, for a bare clone from the HEAD commit.  Params:
+//	r (Describer): describes the local clone to read
+// Returns the submodules, results (vcs cmds run, output) and any error
+func GitNested(r Describer) ([]*NestedRepo, Resulter, error) {
+	results := newResults()
+	runOpt := "-C"
+	runDir := r.LocalRepoPath()
+	bare := isBareRepo(runDir)
+	args := []string{runOpt, runDir, "ls-files", "-s", "-z"}
+	modulesOpts := []string{"-f", filepath.Join(runDir, ".gitmodules")}
+	if bare {
+		args = []string{runOpt, runDir, "ls-tree", "-z", "-r", "HEAD"}
+		modulesOpts = []string{"--blob", "HEAD:.gitmodules"}
+	}
+	result, err := run(r.Context(), gitTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	entries := result.Stdout
+	if !strings.Contains(entries, "160000 ") { // no submodules
+		return nil, results, nil
+	}
+	haveModules := strings.Contains("\x00"+entries, "\t.gitmodules\x00")
+	if !bare {
+		_, err = os.Stat(modulesOpts[1])
+		haveModules = err == nil
+	}
+	modules := ""
+	if haveModules {
+		args = append([]string{runOpt, runDir, "config"}, modulesOpts...)
+		result, err = run(r.Context(), gitTool, append(args, "--get-regexp", `^submodule\..*\.(path|url)$`)...)
+		results.add(result)
+		if err != nil && result.ExitCode != 1 { // 1: no path or url settings
+			return nil, results, err
+		}
+		modules = result.Stdout
+	}
+	return sortNested(gitParseNested(r, entries, modules)), results, nil
+}
+
+// gitParseNested parses 'git ls-files -s -z' or 'git ls-tree -z -r' output
+// (NUL terminated entries, paths not quoted) for submodules (gitlinks, mode
+// 160000) and the submodule path/url settings from the .gitmodules file
+// (via 'git config --get-regexp') to get the submodules of a clone
+func gitParseNested(r Describer, entries, modules string) []*NestedRepo {
+	paths := make(map[string]string) // submodule name -> path
+	urls := make(map[string]string)  // submodule name -> url
+	for _, line := range strings.Split(modules, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		key := strings.TrimPrefix(fields[0], "submodule.")
+		i := strings.LastIndex(key, ".")
+		if i == -1 {
+			continue
+		}
+		switch key[i+1:] {
+		case "path":
+			paths[key[:i]] = fields[1]
+		case "url":
+			urls[key[:i]] = fields[1]
+		}
+	}
+	remotes := make(map[string]string) // submodule path -> url
+	for name, path := range paths {
+		remotes[path] = urls[name]
+	}
+	var nested []*NestedRepo
+	for _, line := range strings.Split(entries, "\x00") {
+		tab := strings.Index(line, "\t")
+		if tab == -1 {
+			continue
+		}
+		// "<mode> <sha> <stage>" (ls-files) or "<mode> commit <sha>" (ls-tree)
+		fields := strings.Fields(line[:tab])
+		if len(fields) != 3 || fields[0] != "160000" {
+			continue
+		}
+		rev := Rev(fields[2])
+		if fields[1] != "commit" {
+			if fields[2] != "0" && fields[2] != "2" { // unmerged, use ours
+				continue
+			}
+			rev = Rev(fields[1])
+		}
+		path := line[tab+1:]
+		nested = append(nested, newNestedRepo(r, path, remotes[path], Git, rev))
+	}
+	return nested
+}
+
+// gitUpdateSubmodules inits and updates the submodules of a git clone to
+// the commits pinned by the checked out commit, recursively, the submodule
+// remotes are synced first (in case .gitmodules changed).  Params:
+//	d (Describer): describes the local clone
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func gitUpdateSubmodules(d Describer) (Resulter, error) {
+	results := newResults()
+	runOpt := "-C"
+	runDir := d.LocalRepoPath()
+	result, err := run(d.Context(), gitTool, runOpt, runDir, "submodule", "sync", "--recursive")
+	results.add(result)
+	if err != nil {
+		return results, err
+	}
+	result, err = run(d.Context(), gitTool, runOpt, runDir, "submodule", "update", "--init", "--recursive")
+	results.add(result)
+	return results, err
+}
+
+// GitExport writes an archive of the given revision ("" for HEAD) of the
+// local clone (or mirror) to the writer, see Exporter.  Params:
+//	r (RevReader): describes the local clone to export from
+//	w (io.Writer): where the archive is written
+//	rev (Rev): the revision to export, "" for HEAD
+//	opts (*ExportOptions): archive format, subdir and prefix (nil for a tar)
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func GitExport(r RevReader, w io.Writer, rev Rev, opts *ExportOptions) (Resulter, error) {
+	return exportArchive(r, w, rev, opts, gitExportTree)
+}
+
+// gitExportTree exports the files of a revision into the dir via 'git
+// archive' (so export-ignore attributes are honored), the tree of the
+// subdir is archived if one is given
+func gitExportTree(d Describer, dir string, rev Rev, subdir string) (string, Resulter, error) {
+	results := newResults()
+	treeish := string(rev)
+	if subdir != "" {
+		treeish += ":" + subdir
+	}
+	tarFile := dir + ".tar"
+	result, err := run(d.Context(), gitTool, "-C", d.LocalRepoPath(), "archive", "--format=tar", "-o", tarFile, treeish)
+	results.add(result)
+	if err != nil {
+		return "", results, err
+	}
+	if err = untar(tarFile, dir); err != nil {
+		return "", results, out.WrapErrf(err, 4564, "Unable to extract git archive %s", tarFile)
+	}
+	return dir, results, nil
+}
+
 // GitExists verifies the local repo or remote location is a Git repo,
 // returns where it was found (or "" if not found), the results
 // of any git cmds run (cmds and related output) and any error.
@@ -418,7 +1633,7 @@ func GitExists(e Existence, l Location) (string, Resulter, error) {
 		scheme := url.GetScheme(remote)
 		if scheme != "" { // if we have a scheme then see if the repo exists...
 			var result *Result
-			result, err = run(gitTool, "ls-remote", remote)
+			result, err = run(e.Context(), gitTool, "ls-remote", remote)
 			results.add(result)
 			if err == nil {
 				path = remote
@@ -427,7 +1642,7 @@ func GitExists(e Existence, l Location) (string, Resulter, error) {
 			vcsSchemes := e.Schemes()
 			for _, scheme = range vcsSchemes {
 				var result *Result
-				result, err = run(gitTool, "ls-remote", scheme+"://"+remote)
+				result, err = run(e.Context(), gitTool, "ls-remote", scheme+"://"+remote)
 				results.add(result)
 				if err == nil {
 					path = scheme + "://" + remote
@@ -474,19 +1689,19 @@ func GitCheckRemote(e Existence, remote string, mode ...RemoteMode) (string, Res
 		runDir := loc
 		remoteName := e.RemoteRepoName()
 		gitString := fmt.Sprintf("remote.%s.url", remoteName)
-		result, err := run(gitTool, runOpt, runDir, "config", "--get", gitString)
+		result, err := run(e.Context(), gitTool, runOpt, runDir, "config", "--get", gitString)
 		results.add(result)
 		if err != nil {
 			return remote, results, err
 		}
-		outStr = result.Output
+		outStr = result.Stdout
 		localRemote := strings.TrimSpace(outStr)
 		if remote != "" && localRemote != remote {
 			// If remote is given and it doesn't match what the remoteName
 			// (eg: "origin") points to, the error if just checking and if
 			// told to update instead update the remoteName's URL to 'remote'
 			if currMode == UpdateRemote {
-				remResult, err := run(gitTool, runOpt, runDir, "remote", "set-url", remoteName, remote)
+				remResult, err := run(e.Context(), gitTool, runOpt, runDir, "remote", "set-url", remoteName, remote)
 				results.add(remResult)
 				if err == nil {
 					return remote, results, nil
@@ -522,8 +1737,9 @@ func SetDefaultGitSchemes(schemes []string) {
 }
 
 // findGitDirs expects to be pointed at a git workspace, either
-// bare or standard.  It'll find the gitdir and worktree dirs
-// and return them, if it fails it'll return non-nil err.  Params:
+// bare or standard (or a worktree where .git is a file pointing at
+// the git dir).  It'll find the gitdir and worktree dirs and
+// return them, if it fails it'll return non-nil err.  Params:
 //	path (string): path to the git workspace
 // Returns:
 //	gitDir (string): path to git metadata location
@@ -536,6 +1752,13 @@ func findGitDirs(path string) (string, string, error) {
 	if exists, err = dir.Exists(gitDir); exists && err == nil {
 		return gitDir, path, nil
 	}
+	if exists, err = file.Exists(gitDir); exists && err == nil {
+		// a worktree (or submodule) checkout, .git says where the git dir is
+		if gitDir, err = gitDirFromFile(gitDir); err != nil {
+			return "", "", err
+		}
+		return gitDir, path, nil
+	}
 	gitRefsDir := filepath.Join(path, "refs")
 	if exists, err = dir.Exists(gitRefsDir); exists && err == nil {
 		gitConfigFile := filepath.Join(path, "config")
@@ -549,3 +1772,37 @@ func findGitDirs(path string) (string, string, error) {
 	}
 	return "", "", out.WrapErrf(ErrNoExist, 4500, "Unable to find valid git clone under path: %s\n  existence err: %s", path, err)
 }
+
+// gitDirFromFile reads the git dir from a .git file (as used by worktrees and
+// submodules, eg: "gitdir: ../repo.git/worktrees/build1"), a relative git
+// dir is relative to the dir the .git file is in
+func gitDirFromFile(dotGit string) (string, error) {
+	content, err := ioutil.ReadFile(dotGit)
+	if err != nil {
+		return "", out.WrapErrf(ErrNoExist, 4552, "Unable to read git dir from file: %s\n  read err: %s", dotGit, err)
+	}
+	gitDir := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(gitDir, "gitdir: ") {
+		return "", out.WrapErrf(ErrNoExist, 4552, "Unable to find valid git dir in file: %s", dotGit)
+	}
+	gitDir = strings.TrimPrefix(gitDir, "gitdir: ")
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(dotGit), gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// gitCommonDir returns the git dir shared by all worktrees of a clone (where
+// the objects, refs, hooks, etc are) given a git dir from findGitDirs(), for
+// a worktree that's the git dir of the main clone, else the git dir itself
+func gitCommonDir(gitDir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	commonDir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
@@ -0,0 +1,52 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+// GitCommitter implements the VCS ChangeCommitter interface for the Git
+// source control, start out by adding a base VCS description structure
+// (implements Describer)
+type GitCommitter struct {
+	Description
+}
+
+// NewGitCommitter creates a new instance of GitCommitter. The remote and
+// localPath URL/dir need to be passed in.
+func NewGitCommitter(remote, localPath string) (*GitCommitter, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Git. Need to report an error.
+	if err == nil && ltype != Git {
+		return nil, ErrWrongVCS
+	}
+	c := &GitCommitter{}
+	c.setDescription(remote, "origin", localPath, defaultGitSchemes, Git)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		c.setRemoteCheck(func() (string, Resulter, error) {
+			return GitCheckRemote(c, remote)
+		})
+		if _, err = c.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil // note: above 'err' not used on purpose here..
+}
+
+// Commit support for git committer
+func (c *GitCommitter) Commit(rev *Revision, paths ...string) (Revisioner, Resulter, error) {
+	return GitCommit(c, rev, paths...)
+}
+
+// Exists support for git committer
+func (c *GitCommitter) Exists(l Location) (string, Resulter, error) {
+	return GitExists(c, l)
+}
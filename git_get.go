@@ -13,16 +13,34 @@
 
 package vcs
 
+import "time"
+
+// GitCloneOptions limit how much of the remote a git getter clones, handy
+// for large repos where the full history isn't needed (eg: CI builds), the
+// zero value is a full clone.  Note that a later GitUpdate() deepens the
+// history of a shallow clone as needed if asked to update to a missing rev
+type GitCloneOptions struct {
+	Depth        int       // if > 0 the history is truncated to this many commits
+	ShallowSince time.Time // if set the history is truncated to commits after this time
+	SingleBranch bool      // only clone the history of one branch (Branch or remote HEAD)
+	Branch       string    // branch (or tag) to clone and check out, "" for remote HEAD
+	Filter       string    // partial clone filter, eg: "blob:none" or "tree:0"
+}
+
 // GitGetter implements the VCS Getter interface for the Git source control,
 // start out by adding a base VCS description structure (implements Describer)
 type GitGetter struct {
 	Description
 	mirror bool
+	clone  *GitCloneOptions
+	sparse []string
+	cache  string
 }
 
 // NewGitGetter creates a new instance of GitGetter. The remote and localPath URL/dir
-// need to be passed in.
-func NewGitGetter(remote, remoteName, localPath string, mirror bool) (Getter, error) {
+// need to be passed in.  If sparse paths (directories) are given the clone
+// is a cone mode sparse checkout of just those (see SparseMgr).
+func NewGitGetter(remote, remoteName, localPath string, mirror bool, sparse ...string) (Getter, error) {
 	ltype, err := DetectVcsFromFS(localPath)
 	// Found a VCS other than Git. Need to report an error.
 	if err == nil && ltype != Git {
@@ -33,17 +51,33 @@ func NewGitGetter(remote, remoteName, localPath string, mirror bool) (Getter, er
 		remoteName = "origin"
 	}
 	g.mirror = mirror
+	g.sparse = sparse
 	g.setDescription(remote, remoteName, localPath, defaultGitSchemes, Git)
 	if err == nil { // Have a localPath FS repo, try to validate/upd remote
-		remote, _, err = GitCheckRemote(g, remote)
-		if err != nil {
+		g.setRemoteCheck(func() (string, Resulter, error) {
+			return GitCheckRemote(g, remote)
+		})
+		if _, err = g.Check(); err != nil {
 			return nil, err
 		}
-		g.setRemote(remote)
 	}
 	return g, nil // note: above 'err' not used on purpose here..
 }
 
+// SetCloneOptions sets the shallow, single branch and partial clone options
+// used when Get() clones the repo (nil for a full clone)
+func (g *GitGetter) SetCloneOptions(opts *GitCloneOptions) {
+	g.clone = opts
+}
+
+// SetCacheDir sets a cache dir to keep a mirror clone of each remote in,
+// Get() then refreshes the mirror of the getter remote and clones borrowing
+// its objects (the clone is dissociated from the mirror after), so only
+// new history comes over the network ("" turns the cache off)
+func (g *GitGetter) SetCacheDir(cacheDir string) {
+	g.cache = cacheDir
+}
+
 // Get support for git getter
 func (g *GitGetter) Get(rev ...Rev) (Resulter, error) {
 	return GitGet(g, rev...)
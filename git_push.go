@@ -0,0 +1,55 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+// GitPusher implements the VCS Pusher interface for the Git source control,
+// start out by adding a base VCS description structure (implements
+// Describer)
+type GitPusher struct {
+	Description
+}
+
+// NewGitPusher creates a new instance of GitPusher. The remote and localPath
+// URL/dir need to be passed in, remoteName defaults to "origin".
+func NewGitPusher(remote, remoteName, localPath string) (*GitPusher, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Git. Need to report an error.
+	if err == nil && ltype != Git {
+		return nil, ErrWrongVCS
+	}
+	p := &GitPusher{}
+	if remoteName == "" {
+		remoteName = "origin"
+	}
+	p.setDescription(remote, remoteName, localPath, defaultGitSchemes, Git)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		p.setRemoteCheck(func() (string, Resulter, error) {
+			return GitCheckRemote(p, remote)
+		})
+		if _, err = p.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil // note: above 'err' not used on purpose here..
+}
+
+// Push support for git pusher
+func (p *GitPusher) Push(opts *PushOptions) ([]*RefPushStatus, Resulter, error) {
+	return GitPush(p, opts)
+}
+
+// Exists support for git pusher
+func (p *GitPusher) Exists(l Location) (string, Resulter, error) {
+	return GitExists(p, l)
+}
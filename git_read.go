@@ -13,6 +13,8 @@
 
 package vcs
 
+import "io"
+
 // GitReader implements the VCS Reader interface for the Git source control,
 // start out by adding a base VCS description structure (implements Describer)
 type GitReader struct {
@@ -31,11 +33,12 @@ func NewGitReader(remote, localPath string) (*GitReader, error) {
 	r := &GitReader{}
 	r.setDescription(remote, "origin", localPath, defaultGitSchemes, Git)
 	if err == nil {
-		newRemote, _, err := GitCheckRemote(r, remote)
-		if err != nil {
+		r.setRemoteCheck(func() (string, Resulter, error) {
+			return GitCheckRemote(r, remote)
+		})
+		if _, err = r.Check(); err != nil {
 			return nil, err
 		}
-		r.setRemote(newRemote)
 	}
 	return r, nil // note: above 'err' not used on purpose here..
 }
@@ -50,6 +53,31 @@ func (r *GitReader) RevRead(scope ReadScope, vcsRev ...Rev) ([]Revisioner, Resul
 	return GitRevRead(r, scope, vcsRev...)
 }
 
+// RevLog support for git reader
+func (r *GitReader) RevLog(scope ReadScope, from, to Rev, max int, paths ...string) ([]Revisioner, Resulter, error) {
+	return GitRevLog(r, scope, from, to, max, paths...)
+}
+
+// Diff support for git reader
+func (r *GitReader) Diff(from, to Rev, paths ...string) ([]*FileDiff, Resulter, error) {
+	return GitDiff(r, from, to, paths...)
+}
+
+// Nested support for git reader
+func (r *GitReader) Nested() ([]*NestedRepo, Resulter, error) {
+	return GitNested(r)
+}
+
+// Export support for git reader
+func (r *GitReader) Export(w io.Writer, rev Rev, opts *ExportOptions) (Resulter, error) {
+	return GitExport(r, w, rev, opts)
+}
+
+// Refs support for git reader
+func (r *GitReader) Refs(l Location) ([]*Ref, Resulter, error) {
+	return GitRefs(r, l)
+}
+
 // Exists support for git reader
 func (r *GitReader) Exists(l Location) (string, Resulter, error) {
 	return GitExists(r, l)
@@ -0,0 +1,51 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+// GitSparseMgr implements the VCS SparseMgr interface for the Git source
+// control, start out by adding a base VCS description structure (implements
+// Describer)
+type GitSparseMgr struct {
+	Description
+}
+
+// NewGitSparseMgr creates a new instance of GitSparseMgr. The localPath dir
+// for the clone should be passed in (the clone must exist).
+func NewGitSparseMgr(localPath string) (*GitSparseMgr, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Git. Need to report an error.
+	if err == nil && ltype != Git {
+		return nil, ErrWrongVCS
+	} else if err != nil {
+		return nil, err
+	}
+	s := &GitSparseMgr{}
+	s.setDescription("", "origin", localPath, defaultGitSchemes, Git)
+	return s, nil
+}
+
+// Sparse support for git sparse manager
+func (s *GitSparseMgr) Sparse() ([]string, Resulter, error) {
+	return GitSparse(s)
+}
+
+// SetSparse support for git sparse manager
+func (s *GitSparseMgr) SetSparse(paths ...string) (Resulter, error) {
+	return GitSetSparse(s, paths...)
+}
+
+// Exists support for git sparse manager
+func (s *GitSparseMgr) Exists(l Location) (string, Resulter, error) {
+	return GitExists(s, l)
+}
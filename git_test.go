@@ -1,13 +1,17 @@
 package vcs
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/dvln/out"
 	"github.com/dvln/util/file"
@@ -16,8 +20,8 @@ import (
 // Canary test to ensure GitReader implements the Reader interface.
 var _ Reader = &GitReader{}
 
-// To verify git is working we perform intergration testing
-// with a known git service.
+// To verify git is working we perform integration testing
+// against a local fixture repo (see fixture_test.go).
 
 // This tests non-bare git repo's with the various bits of git functionality within the
 // VCS package... at least those items applicable to non-bare git repo's which is pretty
@@ -34,9 +38,12 @@ func TestGit(t *testing.T) {
 		}
 	}()
 	testClone := filepath.Join(tempDir, "VCSTestRepo")
+	fixture := newFixture(t, Git, tempDir)
+	secondRev := fixture.revs["second"]
+	thirdRev := fixture.revs["third"]
 
 	mirror := true
-	gitGetter, err := NewGitGetter("https://github.com/dvln/git-test-repo", "", testClone, !mirror)
+	gitGetter, err := NewGitGetter(fixture.remote, "", testClone, !mirror)
 	if err != nil {
 		t.Fatalf("Unable to instantiate new Git VCS reader, Err: %s", err)
 	}
@@ -46,7 +53,7 @@ func TestGit(t *testing.T) {
 	}
 
 	// Check the basic getters.
-	if gitGetter.Remote() != "https://github.com/dvln/git-test-repo" {
+	if gitGetter.Remote() != fixture.remote {
 		t.Error("Remote not set properly")
 	}
 	if gitGetter.LocalRepoPath() != testClone {
@@ -79,7 +86,7 @@ func TestGit(t *testing.T) {
 
 	// Test NewReader on existing checkout. This should simply provide a working
 	// instance without error based on looking at the local directory.
-	gitReader, err := NewReader("https://github.com/dvln/git-test-repo", testClone)
+	gitReader, err := NewReader(fixture.remote, testClone)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -97,7 +104,7 @@ func TestGit(t *testing.T) {
 	}
 
 	// Perform an update operation
-	gitUpdater, err := NewUpdater("https://github.com/dvln/git-test-repo", "origin", testClone, !mirror, RebaseFalse, nil)
+	gitUpdater, err := NewUpdater(fixture.remote, "origin", testClone, !mirror, RebaseFalse, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -107,14 +114,14 @@ func TestGit(t *testing.T) {
 	}
 
 	// Set the version (checkout) using a short sha1 that should exist
-	results, err = gitReader.RevSet("3f690c9")
+	results, err = gitReader.RevSet(secondRev[:7])
 	if err != nil {
 		t.Fatalf("Unable to update Git repo version. Err was: %s, results:\n%s", err, results)
 	}
 
 	// Use RevRead to verify we are on the right version.
 	v, _, err := gitReader.RevRead(CoreRev)
-	if string(v[0].Core()) != "3f690c91af378fbd09628f9833abb1c3d6828c5e" {
+	if v[0].Core() != secondRev {
 		t.Errorf("Error checking checked out Git version, found: \"%s\"\n", string(v[0].Core()))
 	}
 	if err != nil {
@@ -126,18 +133,71 @@ func TestGit(t *testing.T) {
 	if err != nil {
 		t.Errorf("Unable to update Git repo version. Err was %s", err)
 	}
-	_, err = gitReader.RevSet("28d488c8deda544076f56b279824657fa691ef01")
+	_, err = gitReader.RevSet(thirdRev)
 	if err != nil {
 		t.Errorf("Unable to update Git repo version. Err was %s", err)
 	}
 	v, _, err = gitReader.RevRead(CoreRev)
-	if string(v[0].Core()) != "28d488c8deda544076f56b279824657fa691ef01" {
+	if v[0].Core() != thirdRev {
 		t.Errorf("Error checking checked out Git version, found: \"%s\"\n", string(v[0].Core()))
 	}
 	if err != nil {
 		t.Error(err)
 	}
 
+	// Use an AllData RevRead to verify the full revision data is populated
+	v, results, err = gitReader.RevRead(AllData)
+	if err != nil {
+		t.Fatalf("Unable to read full Git revision data, err: %s, results:\n%s", err, results)
+	}
+	if v[0].Core() != thirdRev {
+		t.Errorf("Error checking checked out Git version (all data), found: \"%s\"\n", string(v[0].Core()))
+	}
+	if name, id := v[0].UserInfo(Author); name == "" || id == "" {
+		t.Errorf("Full Git revision read did not populate the author, found: \"%s\" <%s>", name, id)
+	}
+	if v[0].TStamp(Committer) == nil || v[0].Comment() == "" {
+		t.Errorf("Full Git revision read did not populate the commit timestamp or comment")
+	}
+
+	// Walk the history, limited to a single revision, then an empty range
+	v, results, err = gitReader.RevLog(CoreRev, "", thirdRev, 1)
+	if err != nil {
+		t.Fatalf("Unable to read Git revision history, err: %s, results:\n%s", err, results)
+	}
+	if len(v) != 1 || v[0].Core() != thirdRev {
+		t.Errorf("Error reading Git revision history, expected 1 revision, found: %d", len(v))
+	}
+	v, _, err = gitReader.RevRead(AllData, thirdRev, "..", thirdRev)
+	if err != nil || len(v) != 0 {
+		t.Errorf("Error reading empty Git revision range, found %d revisions, err: %v", len(v), err)
+	}
+
+	// Write a tag, semver and branch onto a revision in the local clone
+	gitWriter, err := NewRevWriter(fixture.remote, "origin", testClone)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new Git revision writer, err: %s", err)
+	}
+	newRev := NewRevision()
+	newRev.SetCore(thirdRev)
+	newRev.SetTags([]Rev{"vcs-test-tag"})
+	newRev.SetSemVers([]Rev{"v99.0.0"})
+	newRev.SetBranches([]Rev{"vcs-test-branch"})
+	results, err = gitWriter.RevCommit(newRev)
+	if err != nil {
+		t.Fatalf("Unable to commit Git revision data, err: %s, results:\n%s", err, results)
+	}
+	v, _, err = gitReader.RevRead(AllData, thirdRev)
+	if err != nil {
+		t.Fatalf("Unable to read back Git revision data, err: %s", err)
+	}
+	if !strings.Contains(fmt.Sprint(v[0].Tags()), "vcs-test-tag") || !strings.Contains(fmt.Sprint(v[0].SemVers()), "v99.0.0") {
+		t.Errorf("Git revision commit did not create tags, found: %v / %v", v[0].Tags(), v[0].SemVers())
+	}
+	if !strings.Contains(fmt.Sprint(v[0].Branches()), "vcs-test-branch") {
+		t.Errorf("Git revision commit did not create the branch, found: %v", v[0].Branches())
+	}
+
 	// Install a git hook, verify existence, then remove it, verify gone
 	gitHookMgr, err := NewHookMgr(testClone)
 	if err != nil {
@@ -176,7 +236,7 @@ func TestGit(t *testing.T) {
 		t.Fatalf("Failed to remove hook symlink, err: %s", err)
 	}
 	if there, err := file.Exists(hookLinkPath); err != nil || there {
-		t.Fatalf("Removal of hook symlink seems to have failed (err: %s, there: %s)\n", err, there)
+		t.Fatalf("Removal of hook symlink seems to have failed (err: %v, there: %t)\n", err, there)
 	}
 
 	// Now lets try a copy type hook install and removal
@@ -200,7 +260,7 @@ func TestGit(t *testing.T) {
 		t.Fatalf("Failed to remove hook file, err: %s", err)
 	}
 	if there, err := file.Exists(hookCopyPath); err != nil || there {
-		t.Fatalf("Removal of hook file seems to have failed (err: %s, there: %s)\n", err, there)
+		t.Fatalf("Removal of hook file seems to have failed (err: %v, there: %t)\n", err, there)
 	}
 }
 
@@ -219,9 +279,10 @@ func TestBareGit(t *testing.T) {
 			t.Error(err)
 		}
 	}()
+	fixture := newFixture(t, Git, tempDir)
 
 	mirror := true
-	gitGetter, err := NewGitGetter("https://github.com/dvln/git-test-repo", "", tempDir+sep+"VCSTestRepo", mirror)
+	gitGetter, err := NewGitGetter(fixture.remote, "", tempDir+sep+"VCSTestRepo", mirror)
 	if err != nil {
 		t.Fatalf("Unable to instantiate new Git VCS reader, Err: %s", err)
 	}
@@ -231,7 +292,7 @@ func TestBareGit(t *testing.T) {
 	}
 
 	// Check the basic getters.
-	if gitGetter.Remote() != "https://github.com/dvln/git-test-repo" {
+	if gitGetter.Remote() != fixture.remote {
 		t.Error("Remote not set properly")
 	}
 	if gitGetter.LocalRepoPath() != tempDir+sep+"VCSTestRepo" {
@@ -264,7 +325,7 @@ func TestBareGit(t *testing.T) {
 
 	// Test NewReader on existing checkout. This should simply provide a working
 	// instance without error based on looking at the local directory.
-	gitReader, err := NewReader("https://github.com/dvln/git-test-repo", tempDir+sep+"VCSTestRepo")
+	gitReader, err := NewReader(fixture.remote, tempDir+sep+"VCSTestRepo")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -278,8 +339,8 @@ func TestBareGit(t *testing.T) {
 	}
 
 	// Use RevRead to read a version, see if that works
-	v, _, err := gitReader.RevRead(CoreRev, "28d488c8deda544076f56b279824657fa691ef01")
-	if string(v[0].Core()) != "28d488c8deda544076f56b279824657fa691ef01" {
+	v, _, err := gitReader.RevRead(CoreRev, fixture.revs["third"])
+	if v[0].Core() != fixture.revs["third"] {
 		t.Errorf("Error checking checked out Git version, found: \"%s\"\n", string(v[0].Core()))
 	}
 	if err != nil {
@@ -287,7 +348,7 @@ func TestBareGit(t *testing.T) {
 	}
 
 	// Perform a remote update class operation (ie: mirror update w/prune of deleted refs)
-	gitUpdater, err := NewUpdater("https://github.com/dvln/git-test-repo", "origin", tempDir+sep+"VCSTestRepo", mirror, RebaseFalse, nil)
+	gitUpdater, err := NewUpdater(fixture.remote, "origin", tempDir+sep+"VCSTestRepo", mirror, RebaseFalse, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -299,7 +360,7 @@ func TestBareGit(t *testing.T) {
 	runOpt := "-C"
 
 	// See if the branch exists (should exist, this is a mirror)
-	result, err := run(gitTool, runOpt, runDir, "rev-parse", "--verify", "testbr1")
+	result, err := run(gitUpdater.Context(), gitTool, runOpt, runDir, "rev-parse", "--verify", "testbr1")
 	if err != nil {
 		t.Fatalf("Failed to detect local testbr1, should be there: %s\n%s", err, result.Output)
 	}
@@ -308,7 +369,7 @@ func TestBareGit(t *testing.T) {
 	refs := make(map[string]RefOp)
 	refs["refs/heads/master"] = RefFetch
 	refs["refs/heads/testbr1"] = RefDelete
-	gitUpdater2, err := NewUpdater("https://github.com/dvln/git-test-repo", "origin", tempDir+sep+"VCSTestRepo", mirror, RebaseFalse, refs)
+	gitUpdater2, err := NewUpdater(fixture.remote, "origin", tempDir+sep+"VCSTestRepo", mirror, RebaseFalse, refs)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -318,7 +379,7 @@ func TestBareGit(t *testing.T) {
 	}
 
 	// See if the branch no longer exists (should gone at this point)
-	_, err = run(gitTool, runOpt, runDir, "rev-parse", "--verify", "testbr1")
+	_, err = run(gitUpdater.Context(), gitTool, runOpt, runDir, "rev-parse", "--verify", "testbr1")
 	if err == nil {
 		t.Fatalf("The testbr1 branch should have been deleted, it's still still there...")
 	}
@@ -339,8 +400,9 @@ func TestGitFlipCloneType(t *testing.T) {
 			t.Error(err)
 		}
 	}()
+	fixture := newFixture(t, Git, tempDir)
 	mirror := true
-	gitGetter, err := NewGitGetter("https://github.com/dvln/git-test-repo", "", tempDir+sep+"VCSTestRepo", mirror)
+	gitGetter, err := NewGitGetter(fixture.remote, "", tempDir+sep+"VCSTestRepo", mirror)
 	if err != nil {
 		t.Errorf("Unable to instantiate new Git VCS reader, Err: %s", err)
 	}
@@ -350,7 +412,7 @@ func TestGitFlipCloneType(t *testing.T) {
 	}
 
 	// Check the basic getters.
-	if gitGetter.Remote() != "https://github.com/dvln/git-test-repo" {
+	if gitGetter.Remote() != fixture.remote {
 		t.Error("Remote not set properly")
 	}
 	if gitGetter.LocalRepoPath() != tempDir+sep+"VCSTestRepo" {
@@ -372,7 +434,7 @@ func TestGitFlipCloneType(t *testing.T) {
 	// In this case we're going with a regular clone, not a mirror clone,
 	// but we already have a mirror clone there, should detect, remove and
 	// bring in a fresh "regular" clone for us.  What could go wrong?
-	gitGetter2, err := NewGitGetter("https://github.com/dvln/git-test-repo", "", tempDir+sep+"VCSTestRepo", !mirror)
+	gitGetter2, err := NewGitGetter(fixture.remote, "", tempDir+sep+"VCSTestRepo", !mirror)
 	if err != nil {
 		t.Errorf("Unable to instantiate second Git VCS reader, Err: %s", err)
 	}
@@ -396,6 +458,363 @@ func TestGitFlipCloneType(t *testing.T) {
 	}
 }
 
+// TestGitShallowGet verifies shallow, single branch and partial clones and
+// that updating (or getting) a rev missing from a shallow clone deepens it
+func TestGitShallowGet(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	fixture.run(fixture.path, nil, gitTool, "config", "uploadpack.allowFilter", "true")
+	hasRev := func(localPath string, rev Rev) bool {
+		_, err := runInDir(context.Background(), localPath, fixtureEnv, gitTool, "cat-file", "-e", string(rev)+"^{commit}")
+		return err == nil
+	}
+	tests := []struct {
+		name     string
+		opts     *GitCloneOptions
+		have     []string
+		missing  []string
+		branches string
+	}{
+		{"depth", &GitCloneOptions{Depth: 1}, []string{"merge", "branch"}, []string{"third", "first"}, "origin/HEAD origin/master origin/testbr1"},
+		{"since", &GitCloneOptions{ShallowSince: fixtureTime.Add(4 * time.Minute), SingleBranch: true}, []string{"merge"}, []string{"second", "first"}, "origin/HEAD origin/master"},
+		{"single", &GitCloneOptions{Depth: 1, SingleBranch: true, Branch: "testbr1"}, []string{"branch"}, []string{"merge", "second"}, "origin/testbr1"},
+		{"partial", &GitCloneOptions{Filter: "blob:none"}, []string{"merge", "first"}, nil, "origin/HEAD origin/master origin/testbr1"},
+	}
+	for _, test := range tests {
+		localPath := filepath.Join(tempDir, test.name)
+		getter, err := NewGitGetter(fixture.remote, "", localPath, false)
+		if err != nil {
+			t.Fatalf("Unable to instantiate new git getter, err: %s", err)
+		}
+		getter.(*GitGetter).SetCloneOptions(test.opts)
+		results, err := getter.Get()
+		if err != nil {
+			t.Fatalf("Unable to %s clone git repo, err: %s, results:\n%s", test.name, err, results)
+		}
+		for _, name := range test.have {
+			if !hasRev(localPath, fixture.revs[name]) {
+				t.Errorf("Expected %s clone to have the %s rev", test.name, name)
+			}
+		}
+		for _, name := range test.missing {
+			if hasRev(localPath, fixture.revs[name]) {
+				t.Errorf("Expected %s clone to be missing the %s rev", test.name, name)
+			}
+		}
+		branches := fixture.run(localPath, nil, gitTool, "for-each-ref", "--format=%(refname:short)", "refs/remotes")
+		if branches = strings.Join(strings.Fields(branches), " "); branches != test.branches {
+			t.Errorf("Incorrect %s clone branches, expected: %q, found: %q", test.name, test.branches, branches)
+		}
+		if test.opts.Filter != "" && fixture.run(localPath, nil, gitTool, "config", "remote.origin.promisor") != "true" {
+			t.Errorf("Expected %s clone to be a partial clone", test.name)
+		}
+	}
+
+	// updating the depth clone to a missing rev deepens it
+	updater, err := NewGitUpdater(fixture.remote, "", filepath.Join(tempDir, "depth"), false, RebaseFalse, nil)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git updater, err: %s", err)
+	}
+	results, err := updater.Update(fixture.revs["first"])
+	if err != nil {
+		t.Fatalf("Unable to update shallow git clone to a missing rev, err: %s, results:\n%s", err, results)
+	}
+	if !hasRev(filepath.Join(tempDir, "depth"), fixture.revs["first"]) || !strings.Contains(fmt.Sprintf("%s", results), "--deepen") {
+		t.Errorf("Expected shallow git clone to be deepened to the first rev, results:\n%s", results)
+	}
+
+	// getting a missing rev in a new shallow clone deepens and checks it out
+	localPath := filepath.Join(tempDir, "rev")
+	getter, err := NewGitGetter(fixture.remote, "", localPath, false)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git getter, err: %s", err)
+	}
+	getter.(*GitGetter).SetCloneOptions(&GitCloneOptions{Depth: 1})
+	if results, err = getter.Get(fixture.revs["second"]); err != nil {
+		t.Fatalf("Unable to shallow clone git repo at a missing rev, err: %s, results:\n%s", err, results)
+	}
+	if head := fixture.run(localPath, nil, gitTool, "rev-parse", "HEAD"); Rev(head) != fixture.revs["second"] {
+		t.Errorf("Incorrect shallow git clone rev, expected: %s, found: %s", fixture.revs["second"], head)
+	}
+}
+
+// TestGitCacheGet verifies clones with a cache dir borrow objects from a
+// mirror of the remote kept in the cache, refreshed on each clone
+func TestGitCacheGet(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	cacheDir := filepath.Join(tempDir, "cache")
+	for i, expected := range []string{"clone --mirror", "remote update"} {
+		localPath := filepath.Join(tempDir, fmt.Sprintf("VCSTestRepo%d", i))
+		getter, err := NewGitGetter(fixture.remote, "", localPath, false)
+		if err != nil {
+			t.Fatalf("Unable to instantiate new git getter, err: %s", err)
+		}
+		getter.(*GitGetter).SetCacheDir(cacheDir)
+		results, err := getter.Get()
+		if err != nil {
+			t.Fatalf("Unable to clone git repo via cache, err: %s, results:\n%s", err, results)
+		}
+		resultsStr := fmt.Sprintf("%s", results)
+		if !strings.Contains(resultsStr, expected) || !strings.Contains(resultsStr, "--reference") {
+			t.Errorf("Expected git cache mirror %q and a reference clone, results:\n%s", expected, resultsStr)
+		}
+		if head := fixture.run(localPath, nil, gitTool, "rev-parse", "HEAD"); Rev(head) != fixture.revs["merge"] {
+			t.Errorf("Incorrect git clone rev via cache, expected: %s, found: %s", fixture.revs["merge"], head)
+		}
+		if exists, _ := file.Exists(filepath.Join(localPath, ".git", "objects", "info", "alternates")); exists {
+			t.Error("Expected git clone via cache to be dissociated from the cache mirror")
+		}
+	}
+	mirrors, err := filepath.Glob(filepath.Join(cacheDir, "git-fixture-*.git"))
+	if err != nil || len(mirrors) != 1 || !isBareRepo(mirrors[0]) {
+		t.Errorf("Expected one git cache mirror clone, found: %v, err: %v", mirrors, err)
+	}
+}
+
+// TestGitCacheRecover verifies a broken git cache mirror (eg: from a clone
+// that was killed) is cloned again and that the cache mirror lock is shared
+// with other processes (ie: taken on a file)
+func TestGitCacheRecover(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	cacheDir := filepath.Join(tempDir, "cache")
+	cacheGet := func(i int, ctx context.Context) (Resulter, error) {
+		getter, err := NewGitGetter(fixture.remote, "", filepath.Join(tempDir, fmt.Sprintf("VCSTestRepo%d", i)), false)
+		if err != nil {
+			t.Fatalf("Unable to instantiate new git getter, err: %s", err)
+		}
+		getter.(*GitGetter).SetCacheDir(cacheDir)
+		getter.SetContext(ctx)
+		return getter.Get()
+	}
+	if results, err := cacheGet(0, nil); err != nil {
+		t.Fatalf("Unable to clone git repo via cache, err: %s, results:\n%s", err, results)
+	}
+	mirrors, err := filepath.Glob(filepath.Join(cacheDir, "git-fixture-*.git"))
+	if err != nil || len(mirrors) != 1 {
+		t.Fatalf("Expected one git cache mirror clone, found: %v, err: %v", mirrors, err)
+	}
+	mirror := mirrors[0]
+
+	// a half cloned mirror and a temp clone left behind are cleaned up
+	if err = os.RemoveAll(mirror); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{mirror, mirror + ".tmp"} {
+		if err = os.MkdirAll(filepath.Join(dir, "objects"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := cacheGet(1, nil)
+	if err != nil || !strings.Contains(fmt.Sprintf("%s", results), "clone --mirror") {
+		t.Fatalf("Expected the broken git cache mirror to be cloned again, err: %v, results:\n%s", err, results)
+	}
+	if exists, _ := file.Exists(mirror + ".tmp"); exists || !isBareRepo(mirror) {
+		t.Errorf("Expected a recovered git cache mirror and no temp clone, mirror bare: %t", isBareRepo(mirror))
+	}
+
+	// while another process holds the lock the mirror isn't touched
+	lock, err := os.OpenFile(mirror+".lock", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("Expected a git cache mirror lock file, err: %s", err)
+	}
+	if err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*gitCacheLockPoll)
+	defer cancel()
+	results, err = cacheGet(2, ctx)
+	if !errors.Is(err, ErrTimeout) || strings.Contains(fmt.Sprintf("%s", results), "remote update") {
+		t.Errorf("Expected a timeout waiting for the git cache mirror lock, err: %v, results:\n%s", err, results)
+	}
+	// nor while another process clones with its objects (a shared lock)
+	if err = syscall.Flock(int(lock.Fd()), syscall.LOCK_SH); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 3*gitCacheLockPoll)
+	defer cancel()
+	results, err = cacheGet(3, ctx)
+	if !errors.Is(err, ErrTimeout) || strings.Contains(fmt.Sprintf("%s", results), "remote update") {
+		t.Errorf("Expected a timeout waiting for the shared git cache mirror lock, err: %v, results:\n%s", err, results)
+	}
+	lock.Close()
+	if results, err = cacheGet(4, nil); err != nil || !strings.Contains(fmt.Sprintf("%s", results), "remote update") {
+		t.Errorf("Expected the git cache mirror to be updated once unlocked, err: %v, results:\n%s", err, results)
+	}
+}
+
+// TestGitCacheConcurrent verifies concurrent clones via the same git cache
+// mirror all work, each cloning with the mirror objects while no other get
+// updates the mirror, and that the mirror lock is released afterwards
+func TestGitCacheConcurrent(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	cacheDir := filepath.Join(tempDir, "cache")
+	const clones = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, clones)
+	for i := 0; i < clones; i++ {
+		localPath := filepath.Join(tempDir, fmt.Sprintf("VCSTestRepo%d", i))
+		getter, err := NewGitGetter(fixture.remote, "", localPath, false)
+		if err != nil {
+			t.Fatalf("Unable to instantiate new git getter, err: %s", err)
+		}
+		getter.(*GitGetter).SetCacheDir(cacheDir)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := getter.Get()
+			if err == nil && !strings.Contains(fmt.Sprintf("%s", results), "--dissociate") {
+				err = fmt.Errorf("clone of %s didn't use the git cache mirror, results:\n%s", localPath, results)
+			} else if err != nil {
+				err = fmt.Errorf("Unable to clone %s via cache, err: %s, results:\n%s", localPath, err, results)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	for i := 0; i < clones; i++ {
+		localPath := filepath.Join(tempDir, fmt.Sprintf("VCSTestRepo%d", i))
+		if head := fixture.run(localPath, nil, gitTool, "rev-parse", "HEAD"); Rev(head) != fixture.revs["merge"] {
+			t.Errorf("Incorrect HEAD for clone %s, found: %s", localPath, head)
+		}
+		if exists, _ := file.Exists(filepath.Join(localPath, ".git", "objects", "info", "alternates")); exists {
+			t.Errorf("Clone %s still borrows objects from the git cache mirror", localPath)
+		}
+	}
+	locks, err := filepath.Glob(filepath.Join(cacheDir, "*.lock"))
+	if err != nil || len(locks) != 1 {
+		t.Fatalf("Expected one git cache mirror lock file, found: %v, err: %v", locks, err)
+	}
+	lock, err := os.OpenFile(locks[0], os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Close()
+	if err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		t.Errorf("Expected the git cache mirror lock to be released, err: %s", err)
+	}
+}
+
+// TestGitWorktree verifies worktree checkouts sharing a mirror clone can be
+// added, listed, removed and pruned and that they are seen as git clones
+func TestGitWorktree(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	mirrorPath := filepath.Join(tempDir, "mirror.git")
+	getter, err := NewGitGetter(fixture.remote, "", mirrorPath, true)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git getter, err: %s", err)
+	}
+	if results, err := getter.Get(); err != nil {
+		t.Fatalf("Unable to mirror clone git repo, err: %s, results:\n%s", err, results)
+	}
+	mgr, err := NewGitWorktreeMgr(mirrorPath)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git worktree manager, err: %s", err)
+	}
+	build1 := filepath.Join(tempDir, "build1")
+	build2 := filepath.Join(tempDir, "build2")
+	for path, name := range map[string]string{build1: "second", build2: "merge"} {
+		worktree, results, err := mgr.Add(path, fixture.revs[name])
+		if err != nil {
+			t.Fatalf("Unable to add git worktree, err: %s, results:\n%s", err, results)
+		}
+		if worktree.Path != path || worktree.Rev != fixture.revs[name] {
+			t.Errorf("Incorrect git worktree added, expected rev: %s, found: %+v", fixture.revs[name], *worktree)
+		}
+	}
+
+	// a relative worktree path is relative to the current dir, not the clone
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	worktree, results, err := mgr.Add("build3", "")
+	os.Chdir(cwd)
+	if err != nil {
+		t.Fatalf("Unable to add relative git worktree, err: %s, results:\n%s", err, results)
+	}
+	if exists, _ := file.Exists(filepath.Join(tempDir, "build3", ".git")); worktree.Path != filepath.Join(tempDir, "build3") || !exists {
+		t.Errorf("Incorrect relative git worktree added, found: %+v", *worktree)
+	}
+	if err = os.Chdir(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	results, err = mgr.Remove("build3", false)
+	os.Chdir(cwd)
+	if err != nil {
+		t.Fatalf("Unable to remove relative git worktree, err: %s, results:\n%s", err, results)
+	}
+
+	// a worktree is a git clone like any other
+	if vcsType, err := DetectVcsFromFS(build1); err != nil || vcsType != Git {
+		t.Errorf("Expected git worktree to be detected as git, found: %s, err: %v", vcsType, err)
+	}
+	gitDir, workTree, err := findGitDirs(build1)
+	if err != nil || workTree != build1 || gitCommonDir(gitDir) != mirrorPath || isBareRepo(build1) {
+		t.Errorf("Incorrect git worktree dirs, git dir: %s, work tree: %s, err: %v", gitDir, workTree, err)
+	}
+	reader, err := NewReader("", build1, Git)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git reader on worktree, err: %s", err)
+	}
+	if revs, results, err := reader.RevRead(CoreRev); err != nil || revs[0].Core() != fixture.revs["second"] {
+		t.Errorf("Incorrect git worktree rev read, err: %v, results:\n%s", err, results)
+	}
+	if hook := gitHookPath(build1, "pre-commit"); hook != filepath.Join(mirrorPath, "hooks", "pre-commit") {
+		t.Errorf("Incorrect git worktree hook path, found: %s", hook)
+	}
+
+	if results, err := mgr.Remove(build2, false); err != nil {
+		t.Fatalf("Unable to remove git worktree, err: %s, results:\n%s", err, results)
+	}
+	os.RemoveAll(build1)
+	worktrees, results, err := mgr.List()
+	if err != nil {
+		t.Fatalf("Unable to list git worktrees, err: %s, results:\n%s", err, results)
+	}
+	if len(worktrees) != 2 || !worktrees[0].Bare || worktrees[1].Rev != fixture.revs["second"] || !worktrees[1].Prunable {
+		t.Errorf("Incorrect git worktrees listed, results:\n%s", results)
+	}
+	if results, err = mgr.Prune(); err != nil {
+		t.Fatalf("Unable to prune git worktrees, err: %s, results:\n%s", err, results)
+	}
+	if worktrees, results, err = mgr.List(); err != nil || len(worktrees) != 1 {
+		t.Errorf("Expected only the mirror clone after a git worktree prune, err: %v, results:\n%s", err, results)
+	}
+}
+
 // TestGitExists focuses on existence checks
 func TestGitExists(t *testing.T) {
 	sep := string(os.PathSeparator)
@@ -435,10 +854,31 @@ func TestGitExists(t *testing.T) {
 		t.Fatal(nrerr)
 	}
 
-	// Try remote Git existence checks via a Getter
-	url1 := "github.com/dvln/vcs"
+	// Try remote Git existence checks via a Getter on a local fixture repo
+	fixture := newFixture(t, Git, tempDir)
 	mirror := true
-	gitGetter, err := NewGitGetter(url1, "", tempDir, !mirror)
+	gitGetter, err := NewGitGetter(fixture.url, "", tempDir+sep+"VCSTestRepo", !mirror)
+	if err != nil {
+		t.Fatalf("Failed to initialize new Git getter, error: %s", err)
+	}
+	path, _, err = gitGetter.Exists(Remote)
+	if err != nil || path != fixture.url {
+		t.Fatalf("Failed to find remote repo that should exist (URL: %s), found: %s, error: %s", fixture.url, path, err)
+	}
+	badurl := fixture.url + "-notexist"
+	gitGetter, err = NewGitGetter(badurl, "", tempDir+sep+"VCSTestRepo", !mirror)
+	if err != nil {
+		t.Fatalf("Failed to initialize \"bad\" Git getter, init should work, error: %s", err)
+	}
+	path, _, err = gitGetter.Exists(Remote)
+	if err == nil || path != "" {
+		t.Fatalf("Failed to detect an error scanning for bad VCS location (loc: %s), found path: %s", badurl, path)
+	}
+
+	// Remote scheme detection needs a real remote host
+	skipUnlessNetwork(t)
+	url1 := "github.com/dvln/vcs"
+	gitGetter, err = NewGitGetter(url1, "", tempDir, !mirror)
 	if err != nil {
 		t.Fatalf("Failed to initialize new Git getter, error: %s", err)
 	}
@@ -470,71 +910,122 @@ func TestGitExists(t *testing.T) {
 }
 
 func TestParallelGitGetUpd(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
 	var wg sync.WaitGroup
+	errs := make(chan error, 6)
 	wg.Add(6)
 	for i := 1; i <= 6; i++ {
-		go runGetUpd(t, &wg)
+		go func() {
+			defer wg.Done()
+			errs <- runGetUpd(fixture.remote)
+		}()
 	}
 	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
 }
 
-// runGetUpd is for multiple goroutine testing, look for race issues
-func runGetUpd(t *testing.T, wg *sync.WaitGroup) {
-	defer wg.Done()
+// runGetUpd is for multiple goroutine testing, look for race issues, any
+// error is returned for the test goroutine to report (t.Fatal must not be
+// called from other goroutines)
+func runGetUpd(remote string) (err error) {
 	sep := string(os.PathSeparator)
 	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
 	if err != nil {
-		t.Fatal(err)
+		return err
 	}
 	defer func() {
-		err = os.RemoveAll(tempDir)
-		if err != nil {
-			t.Error("Failed to remove temp workspace that should have existed, err:", err)
+		if rmErr := os.RemoveAll(tempDir); rmErr != nil && err == nil {
+			err = fmt.Errorf("Failed to remove temp workspace that should have existed, err: %s", rmErr)
 		}
 	}()
 
 	mirror := true
-	gitGetter, err := NewGetter("https://github.com/dvln/git-test-repo", "", tempDir+sep+"VCSTestRepo", mirror)
+	gitGetter, err := NewGetter(remote, "", tempDir+sep+"VCSTestRepo", mirror, Git)
 	if err != nil {
-		t.Fatalf("Unable to instantiate new Git VCS reader, Err: %s", err)
+		return fmt.Errorf("Unable to instantiate new Git VCS reader, Err: %s", err)
 	}
 
 	// Do a clone
 	_, err = gitGetter.Get()
 	if err != nil {
-		t.Fatalf("Unable to clone Git repo using VCS reader Get(). Err was %s", err)
+		return fmt.Errorf("Unable to clone Git repo using VCS reader Get(). Err was %s", err)
 	}
 
 	// Do a clone again, it should end up detecting the clone and doing a remote update
 	results, err := gitGetter.Get()
 	if err != nil {
-		t.Fatalf("Unable to clone over existing Git repo using VCS reader Get(). Err was %s", err)
+		return fmt.Errorf("Unable to clone over existing Git repo using VCS reader Get(). Err was %s", err)
 	}
 	resultsStr := fmt.Sprintf("%s", results)
 	if !strings.Contains(resultsStr, "remote update") {
-		t.Fatalf("Appears 2nd clone didn't switch to using a remote update, results:\n%s", resultsStr)
+		return fmt.Errorf("Appears 2nd clone didn't switch to using a remote update, results:\n%s", resultsStr)
 	}
 
 	// Perform an update operation
-	gitUpdater, err := NewUpdater("https://github.com/dvln/git-test-repo", "origin", tempDir+sep+"VCSTestRepo", mirror, RebaseFalse, nil)
+	gitUpdater, err := NewUpdater(remote, "origin", tempDir+sep+"VCSTestRepo", mirror, RebaseFalse, nil)
 	if err != nil {
-		t.Fatal(err)
+		return err
 	}
 	_, err = gitUpdater.Update()
 	if err != nil {
-		t.Error(err)
+		return err
 	}
 
 	// Perform specific fetch operations on one ref, delete another ref
 	refs := make(map[string]RefOp)
 	refs["refs/heads/master"] = RefFetch
 	refs["refs/heads/testbr1"] = RefDelete
-	gitUpdater2, err := NewUpdater("https://github.com/dvln/git-test-repo", "origin", tempDir+sep+"VCSTestRepo", mirror, RebaseFalse, refs)
+	gitUpdater2, err := NewUpdater(remote, "origin", tempDir+sep+"VCSTestRepo", mirror, RebaseFalse, refs)
 	if err != nil {
-		t.Fatal(err)
+		return err
 	}
 	_, err = gitUpdater2.Update()
+	return err
+}
+
+// TestGitParseRevs verifies full revision data parsing of git log output
+func TestGitParseRevs(t *testing.T) {
+	output := "a862506d017d643091368d53128447d032a03f54\x1fJane Doe\x1fjane@example.com\x1f1410482700\x1f" +
+		"John Doe\x1fjohn@example.com\x1f1410482753\x1fHEAD -> refs/heads/topic, tag: refs/tags/v1.2.3, " +
+		"tag: refs/tags/main/7353, refs/remotes/origin/main, refs/remotes/origin/HEAD\x1f" +
+		"Fix the thing\n\nLonger description\n\x1e\n"
+	revs, err := gitParseRevs(output, "")
 	if err != nil {
-		t.Error(err)
+		t.Fatalf("Failed to parse git revision data, err: %s", err)
+	}
+	if len(revs) != 1 {
+		t.Fatalf("Expected 1 parsed git revision, found: %d", len(revs))
+	}
+	rev := revs[0]
+	if rev.Core() != "a862506d017d643091368d53128447d032a03f54" {
+		t.Errorf("Parsed git core revision incorrect, found: %s", rev.Core())
+	}
+	if name, id := rev.UserInfo(Author); name != "Jane Doe" || id != "jane@example.com" {
+		t.Errorf("Parsed git author incorrect, found: %s <%s>", name, id)
+	}
+	if name, id := rev.UserInfo(Committer); name != "John Doe" || id != "john@example.com" {
+		t.Errorf("Parsed git committer incorrect, found: %s <%s>", name, id)
+	}
+	if tstamp := rev.TStamp(Committer); tstamp == nil || tstamp.Unix() != 1410482753 {
+		t.Errorf("Parsed git committer timestamp incorrect, found: %v", tstamp)
+	}
+	if rev.Comment() != "Fix the thing\n\nLonger description" {
+		t.Errorf("Parsed git comment incorrect, found: %q", rev.Comment())
+	}
+	if fmt.Sprint(rev.SemVers()) != "[v1.2.3]" || fmt.Sprint(rev.Tags()) != "[main/7353]" {
+		t.Errorf("Parsed git semvers/tags incorrect, found: %v / %v", rev.SemVers(), rev.Tags())
+	}
+	if fmt.Sprint(rev.Branches()) != "[topic origin/main]" {
+		t.Errorf("Parsed git branches incorrect, found: %v", rev.Branches())
 	}
 }
@@ -63,11 +63,12 @@ func NewGitUpdater(
 	}
 	u.setDescription(remote, remoteName, localPath, defaultGitSchemes, Git)
 	if err == nil { // Have a localPath FS repo, try to validate/upd remote
-		remote, _, err = GitCheckRemote(u, remote, u.remoteMode)
-		if err != nil {
+		u.setRemoteCheck(func() (string, Resulter, error) {
+			return GitCheckRemote(u, remote, u.remoteMode)
+		})
+		if _, err = u.Check(); err != nil {
 			return nil, err
 		}
-		u.setRemote(remote)
 	}
 	return u, nil // note: above 'err' not used on purpose here..
 }
@@ -0,0 +1,78 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+// GitWorktree is a git worktree checkout, each worktree of a clone shares
+// its object store (and refs) but has its own working tree and HEAD
+type GitWorktree struct {
+	Path     string // path to the worktree checkout
+	Rev      Rev    // revision checked out (the worktree HEAD)
+	Branch   string // branch checked out, "" if detached (or bare)
+	Bare     bool   // the main clone is a bare/mirror clone (no checkout)
+	Locked   bool   // locked worktrees aren't pruned
+	Prunable bool   // checkout was deleted, a prune will drop the record
+}
+
+// GitWorktreeMgr creates, lists, prunes and removes worktree checkouts of a
+// git clone, usually a bare/mirror clone (see NewGitGetter) kept in sync
+// with GitUpdate(), with each worktree pinned to a rev.  That way many
+// workspaces (eg: one per build) share one object store.  Each worktree is
+// a regular git checkout for the rest of the vcs pkg (readers, updaters,
+// etc work on it).  Start out by adding a base VCS description structure
+// (implements Describer)
+type GitWorktreeMgr struct {
+	Description
+}
+
+// NewGitWorktreeMgr creates a new instance of GitWorktreeMgr. The localPath
+// dir of the clone the worktrees share should be passed in (the clone must
+// exist).
+func NewGitWorktreeMgr(localPath string) (*GitWorktreeMgr, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Git. Need to report an error.
+	if err == nil && ltype != Git {
+		return nil, ErrWrongVCS
+	} else if err != nil {
+		return nil, err
+	}
+	w := &GitWorktreeMgr{}
+	w.setDescription("", "origin", localPath, defaultGitSchemes, Git)
+	return w, nil
+}
+
+// Add creates a new worktree checkout at the given path with the given rev
+// checked out (detached), see GitWorktreeAdd()
+func (w *GitWorktreeMgr) Add(path string, rev Rev) (*GitWorktree, Resulter, error) {
+	return GitWorktreeAdd(w, path, rev)
+}
+
+// List returns the worktrees of the clone, the main clone first
+func (w *GitWorktreeMgr) List() ([]*GitWorktree, Resulter, error) {
+	return GitWorktreeList(w)
+}
+
+// Prune drops the records of worktrees whose checkouts have been deleted
+func (w *GitWorktreeMgr) Prune() (Resulter, error) {
+	return GitWorktreePrune(w)
+}
+
+// Remove deletes a worktree checkout (force if it has local changes)
+func (w *GitWorktreeMgr) Remove(path string, force bool) (Resulter, error) {
+	return GitWorktreeRemove(w, path, force)
+}
+
+// Exists support for git worktree manager
+func (w *GitWorktreeMgr) Exists(l Location) (string, Resulter, error) {
+	return GitExists(w, l)
+}
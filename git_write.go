@@ -0,0 +1,72 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+// GitRevWriter implements the VCS RevWriter interface for the Git source
+// control, start out by adding a base VCS description structure (implements
+// Describer), it also tracks the refs RevCommit() created or moved so that
+// RevPush() knows what to publish
+type GitRevWriter struct {
+	Description
+	pushRefs []string
+}
+
+// NewGitRevWriter creates a new instance of GitRevWriter. The remote and
+// localPath URL/dir need to be passed in, remoteName defaults to "origin".
+func NewGitRevWriter(remote, remoteName, localPath string) (*GitRevWriter, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Git. Need to report an error.
+	if err == nil && ltype != Git {
+		return nil, ErrWrongVCS
+	}
+	w := &GitRevWriter{}
+	if remoteName == "" {
+		remoteName = "origin"
+	}
+	w.setDescription(remote, remoteName, localPath, defaultGitSchemes, Git)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		w.setRemoteCheck(func() (string, Resulter, error) {
+			return GitCheckRemote(w, remote)
+		})
+		if _, err = w.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil // note: above 'err' not used on purpose here..
+}
+
+// RevCommit support for git revision writer
+func (w *GitRevWriter) RevCommit(rev *Revision) (Resulter, error) {
+	return GitRevCommit(w, rev)
+}
+
+// RevPush support for git revision writer
+func (w *GitRevWriter) RevPush() (Resulter, error) {
+	return GitRevPush(w)
+}
+
+// Exists support for git revision writer
+func (w *GitRevWriter) Exists(l Location) (string, Resulter, error) {
+	return GitExists(w, l)
+}
+
+// addPushRef remembers a refspec to publish on the next push (once only)
+func (w *GitRevWriter) addPushRef(refSpec string) {
+	for _, pushRef := range w.pushRefs {
+		if pushRef == refSpec {
+			return
+		}
+	}
+	w.pushRefs = append(w.pushRefs, refSpec)
+}
@@ -0,0 +1,3 @@
+module github.com/dvln/vcs
+
+go 1.20
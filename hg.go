@@ -1,9 +1,16 @@
 package vcs
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dvln/out"
 	"github.com/dvln/util/dir"
@@ -14,23 +21,74 @@ var hgDetectURL = regexp.MustCompile("default = (?P<foo>.+)\n")
 
 var defaultHgSchemes []string
 
+// hgPlainEnv turns on hg's plain mode so aliases, localization and user
+// output settings in any hgrc don't change the output we need to parse
+var hgPlainEnv = []string{"HGPLAIN=1"}
+
+// hgSparseHgrc is added to the .hg/hgrc of a sparse clone so that every hg
+// cmd run in it (update, etc) has the sparse extension on, hg refuses to
+// work in a sparse clone otherwise
+const hgSparseHgrc = "\n[extensions]\nsparse =\n"
+
+var hgSparseExtRegex = regexp.MustCompile(`(?m)^\s*sparse\s*=`)
+
+// hgRevTemplate is the 'hg log' template used for full revision data reads,
+// fields are split by unit separators (0x1f) and each revision record ends
+// with a record separator (0x1e), list entries are newline separated
+const hgRevTemplate = "{node}\x1f{branch}\x1f{join(bookmarks, \"\\n\")}\x1f{join(tags, \"\\n\")}\x1f" +
+	"{author|person}\x1f{author|email}\x1f{date|hgdate}\x1f{desc}\x1e"
+
+// hgErrPatterns classifies failed hg cmds by their output (see CmdError)
+var hgErrPatterns = []errPattern{
+	{ErrAuth, regexp.MustCompile(`(?i)authorization failed|http error 40[13]|permission denied \(publickey|authentication failed`)},
+	{ErrLocked, regexp.MustCompile(`(?i)waiting for lock|timeout while waiting for lock|lock held by`)},
+	{ErrCorrupt, regexp.MustCompile(`(?i)integrity check failed|corrupt|run hg verify|empty or missing revlog`)},
+	{ErrDirtyTree, regexp.MustCompile(`(?i)uncommitted changes|untracked file in working directory differs`)},
+	{ErrMergeConflict, regexp.MustCompile(`(?i)unresolved conflicts|use 'hg resolve' to retry|conflicts while merging`)},
+	{ErrUnknownRev, regexp.MustCompile(`(?i)unknown revision|unknown branch|filtered revision|ambiguous identifier|no match found|bookmark '[^']*' does not exist`)},
+	{ErrUnreachable, regexp.MustCompile(`(?i)name or service not known|nodename nor servname|could not resolve hostname|connection refused|timed out|network is unreachable|no route to host|no suitable response from remote hg`)},
+}
+
 // set up default hg remote URL schemes and a search order (for any remote
 // that doesn't have a full URL), eg: https, http, ssh
 func init() {
 	SetDefaultHgSchemes(nil)
 }
 
-// HgGet is used to perform an initial clone of a repository.
+// HgGet is used to perform an initial clone of a repository, checking out
+// the given rev (or the default branch head), a mirror getter clones with
+// no working copy (-U).  If the getter has sparse paths the clone is made a
+// sparse one (see HgSetSparse), that needs a working copy so a mirror getter
+// with sparse paths is refused, and if it has a cache dir the clone shares a
+// store pooled there (see hg share).
 func HgGet(g *HgGetter, rev ...Rev) (Resulter, error) {
 	results := newResults()
-	var result *Result
-	var err error
-	if rev == nil || (rev != nil && rev[0] == "") {
-		result, err = run(hgTool, "clone", "-U", g.Remote(), g.LocalRepoPath())
-	} else {
-		result, err = run(hgTool, "clone", "-u", string(rev[0]), "-U", g.Remote(), g.LocalRepoPath())
+	if g.mirror && len(g.sparse) != 0 {
+		return results, out.NewErrf(4550, "Get: a sparse checkout needs a non-mirror clone, clone: %s", g.LocalRepoPath())
+	}
+	var args []string
+	if g.cache != "" {
+		if err := os.MkdirAll(g.cache, 0755); err != nil {
+			return results, out.WrapErrf(err, 4554, "Unable to create hg cache dir: %s", g.cache)
+		}
+		args = append(args, "--config", "extensions.share=", "--config", "share.pool="+g.cache)
+	}
+	args = append(args, "clone")
+	if g.mirror {
+		args = append(args, "-U")
+	} else if rev != nil && rev[0] != "" {
+		args = append(args, "-u", string(rev[0]))
 	}
+	args = append(args, g.Remote(), g.LocalRepoPath())
+	result, err := run(g.Context(), hgTool, args...)
 	results.add(result)
+	if err == nil && len(g.sparse) != 0 {
+		var sparseResults Resulter
+		sparseResults, err = HgSetSparse(g, g.sparse...)
+		for _, sparseResult := range sparseResults.All() {
+			results.add(sparseResult)
+		}
+	}
 	return results, err
 }
 
@@ -39,7 +97,11 @@ func HgGet(g *HgGetter, rev ...Rev) (Resulter, error) {
 // to update/merge to.  It will return any output of the cmd and an error that occurs.
 // Note that there will be a pull and a merge class of functionality in dvln but
 // pull is likely Mercurial pull (ie: git fetch) and merge is similar to git/hg,
-// whereas update is like a fetch/merge in git or pull/upd(/merge) in hg.
+// whereas update is like a fetch/merge in git or pull/upd(/merge) in hg.  For a
+// mirror updater only the pull is done (eg: for a "-U" clone with no working
+// copy), with RebaseTrue (or RebasePreserve) local commits are rebased on the
+// pulled changes (hg pull --rebase) and if refs are given only those are
+// fetched or deleted (see hgUpdateRefs).
 func HgUpdate(u *HgUpdater, rev ...Rev) (Resulter, error) {
 	//FIXME: should support a "date:<datestr>" class of rev,
 	//       if that is passed in use "-d <date>" for update, so should
@@ -49,22 +111,85 @@ func HgUpdate(u *HgUpdater, rev ...Rev) (Resulter, error) {
 	//       time)... and a silly routine to get that rev (then no need
 	//       to mark up the 'Rev' type (which is a string), but a strong
 	//       need to pass in the right thing of course if that is done. ;)
+	if u.refs != nil {
+		return hgUpdateRefs(u)
+	}
 	results := newResults()
-	result, err := runFromLocalRepoDir(u.LocalRepoPath(), hgTool, "pull")
+	pullArgs := []string{"pull"}
+	rebase := !u.mirror && (u.rebase == RebaseTrue || u.rebase == RebasePreserve)
+	if rebase { // the rebase extension does the update as well
+		pullArgs = []string{"--config", "extensions.rebase=", "pull", "--rebase"}
+	}
+	result, err := runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), hgTool, pullArgs...)
 	results.add(result)
-	if err != nil {
+	if err != nil || u.mirror {
 		return results, err
 	}
 	var updResult *Result
 	if rev == nil || (rev != nil && rev[0] == "") {
-		updResult, err = runFromLocalRepoDir(u.LocalRepoPath(), hgTool, "update")
+		if rebase {
+			return results, nil
+		}
+		updResult, err = runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), hgTool, "update")
 	} else {
-		updResult, err = runFromLocalRepoDir(u.LocalRepoPath(), hgTool, "update", "-r", string(rev[0]))
+		updResult, err = runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), hgTool, "update", "-r", string(rev[0]))
 	}
 	results.add(updResult)
 	return results, err
 }
 
+// hgUpdateRefs is fired if HgUpdate() gets specific refs to operate on,
+// meaning fetch (pull) or delete ops, the working copy isn't updated.  Refs
+// are bookmarks unless given as "branch:<name>" (or "bookmark:<name>"),
+// a bookmark is pulled with 'hg pull -B' and deleted with 'hg bookmark -d'
+// and a branch is pulled with 'hg pull -b' (branches can't be deleted).
+// Refs are done in sorted order.  Params:
+//	u (*HgUpdater): has all the data we need to run the update
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func hgUpdateRefs(u *HgUpdater) (Resulter, error) {
+	results := newResults()
+	var refs []string
+	for ref := range u.refs {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		name, refType := hgRefName(ref)
+		var result *Result
+		var err error
+		switch u.refs[ref] {
+		case RefDelete:
+			if refType == RefBranch {
+				return results, out.WrapErrf(ErrNotImplemented, 4555, "Update refs: hg branches cannot be deleted, ref: %s, clone: %s", ref, u.LocalRepoPath())
+			}
+			result, err = runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), hgTool, "bookmark", "-d", name)
+		case RefFetch:
+			pullOpt := "-B"
+			if refType == RefBranch {
+				pullOpt = "-b"
+			}
+			result, err = runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), hgTool, "pull", pullOpt, name)
+		default:
+			return results, out.NewErrf(4556, "Update refs: invalid ref operation given \"%v\", clone: %s", u.refs[ref], u.LocalRepoPath())
+		}
+		results.add(result)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// hgRefName splits a ref given to hgUpdateRefs into the name and the kind
+// of ref, "branch:<name>" is a branch, "bookmark:<name>" or any other name
+// is a bookmark
+func hgRefName(ref string) (string, RefType) {
+	if strings.HasPrefix(ref, "branch:") {
+		return strings.TrimPrefix(ref, "branch:"), RefBranch
+	}
+	return strings.TrimPrefix(ref, "bookmark:"), RefBookmark
+}
+
 // HgRevSet sets the local repo rev of a pkg currently checked out via Hg.
 // Note that a single specific revision must be given vs a generic
 // Revision structure (since it may have <N> different valid rev's
@@ -73,11 +198,11 @@ func HgUpdate(u *HgUpdater, rev ...Rev) (Resulter, error) {
 func HgRevSet(r RevSetter, rev Rev) (Resulter, error) {
 	results := newResults()
 	if rev == "" {
-		result, err := runFromLocalRepoDir(r.LocalRepoPath(), hgTool, "update")
+		result, err := runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), hgTool, "update")
 		results.add(result)
 		return results, err
 	}
-	result, err := runFromLocalRepoDir(r.LocalRepoPath(), hgTool, "update", "-r", string(rev))
+	result, err := runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), hgTool, "update", "-r", string(rev))
 	results.add(result)
 	return results, err
 }
@@ -86,92 +211,874 @@ func HgRevSet(r RevSetter, rev Rev) (Resulter, error) {
 // pointer is returned (how filled out depends upon if the read is just the
 // basic core/raw VCS revision or full data for the given VCS which will
 // include tags, branches, timestamp info, author/committer, date, comment).
-// Note: this reads one version but that could be expanded to take <N>
-// revisions or a range, eg HgRevRead(reader, <scope>, rev1, "..", rev2),
-// without changing this methods params or return signature (but code
-// changes  would be needed)
+// A range of revisions may also be given, eg: HgRevRead(reader, <scope>,
+// rev1, "..", rev2), which is the same as HgRevLog(reader, <scope>, rev1,
+// rev2, 0) (see that routine for details).
 func HgRevRead(r RevReader, scope ReadScope, vcsRev ...Rev) ([]Revisioner, Resulter, error) {
-	results := newResults()
-	oldDir, err := os.Getwd()
-	if err != nil {
-		return nil, nil, err
+	if from, to, ok := revRange(vcsRev); ok {
+		return HgRevLog(r, scope, from, to, 0)
 	}
+	results := newResults()
 	specificRev := ""
 	if vcsRev != nil && vcsRev[0] != "" {
 		specificRev = string(vcsRev[0])
 	}
-	err = os.Chdir(r.LocalRepoPath())
-	if err != nil {
-		return nil, nil, err
-	}
-	defer os.Chdir(oldDir)
 
 	rev := &Revision{}
 	var revs []Revisioner
+	var err error
 	if scope == CoreRev {
 		// client just wants the core/base VCS revision only..
 		var result *Result
 		if specificRev != "" {
-			result, err = run(hgTool, "identify", "-r", specificRev)
+			result, err = run(r.Context(), hgTool, "-R", r.LocalRepoPath(), "identify", "-r", specificRev)
 		} else {
-			result, err = run(hgTool, "identify")
+			result, err = run(r.Context(), hgTool, "-R", r.LocalRepoPath(), "identify")
 		}
 		results.add(result)
 		if err != nil {
 			return nil, results, err
 		}
-		parts := strings.SplitN(result.Output, " ", 2)
+		parts := strings.SplitN(result.Stdout, " ", 2)
 		sha := strings.TrimSpace(parts[0])
 		rev.SetCore(Rev(sha))
 		revs = append(revs, rev)
 	} else {
-		//FIXME: implement more extensive hg data grab
-		//       if the client has asked for extra data (vs speed)
-		/* Here is how to get the details, if no "branch:" it's default
-		   141 [brady-air]/Users/brady/vcs/mercurial-repo: hg log -l 1
-		   changeset:   26211:ea489d94e1dc
-		   bookmark:    @
-		   tag:         tip
-		   user:        Gregory Szorc <gregory.szorc@gmail.com>
-		   date:        Sat Aug 22 17:08:37 2015 -0700
-		   summary:     hgweb: assign ctype to requestcontext
-
-		   142 [brady-air]/Users/brady/vcs/mercurial-repo: hg log -l 1 -r 3.5.1
-		   changeset:   26120:1a45e49a6bed
-		   branch:      stable
-		   tag:         3.5.1
-		   user:        Matt Mackall <mpm@selenic.com>
-		   date:        Tue Sep 01 16:08:07 2015 -0500
-		   summary:     hgweb: fix trust of templates path (BC)
-
-		   143 [brady-air]/Users/brady/vcs/mercurial-repo: hg identify
-		   ea489d94e1dc tip @
-		   144 [brady-air]/Users/brady/vcs/mercurial-repo: hg identify -r 3.5.1
-		   1a45e49a6bed (stable) 3.5.1
-		   145 [brady-air]/Users/brady/vcs/mercurial-repo: hg identify -i -b -t -r 3.5.1
-		   1a45e49a6bed stable 3.5.1
-		   146 [brady-air]/Users/brady/vcs/mercurial-repo: hg identify -i -b -t
-		   ea489d94e1dc default tip
-		   147 [brady-air]/Users/brady/vcs/mercurial-repo:
-		*/
-		var result *Result
-		if specificRev != "" {
-			result, err = run(hgTool, "identify", "-r", specificRev)
-		} else {
-			result, err = run(hgTool, "identify")
+		// client wants all the data we can get on the revision, use a
+		// template and plain mode so user hgrc settings don't interfere
+		if specificRev == "" {
+			specificRev = "."
 		}
+		var result *Result
+		result, err = runWithEnv(r.Context(), hgPlainEnv, hgTool, "-R", r.LocalRepoPath(), "log", "-r", specificRev, "--template", hgRevTemplate)
 		results.add(result)
 		if err != nil {
 			return nil, results, err
 		}
-		parts := strings.SplitN(result.Output, " ", 2)
-		sha := strings.TrimSpace(parts[0])
-		rev.SetCore(Rev(sha))
-		revs = append(revs, rev)
+		revs, err = hgParseRevs(result.Stdout, r.SemVerPrefix())
+		if err != nil {
+			return nil, results, err
+		}
 	}
 	return revs, results, err
 }
 
+// HgRevLog retrieves the revisions that are ancestors of the 'to' rev but
+// not ancestors of the 'from' rev in topological order, newest first (ie:
+// revset "reverse(::to - ::from)").  Params:
+//	r (Describer): describes the local repo to read the history from
+//	scope (ReadScope): CoreRev for just the short hashes, AllData for full revisions
+//	from (Rev): start of the range (excluded), "" to walk all history
+//	to (Rev): end of the range (included), "" for the working dir parent (.)
+//	max (int): max number of revisions to return, 0 for no limit
+//	paths (...string): optional; only revisions changing these paths (relative
+//	                   to the repo root) are returned
+// Returns the revisions, results (vcs cmds run, output) and any error
+func HgRevLog(r Describer, scope ReadScope, from, to Rev, max int, paths ...string) ([]Revisioner, Resulter, error) {
+	results := newResults()
+	if to == "" {
+		to = "."
+	}
+	revset := fmt.Sprintf("reverse(::%s)", hgQuoteRev(to))
+	if from != "" {
+		revset = fmt.Sprintf("reverse(::%s - ::%s)", hgQuoteRev(to), hgQuoteRev(from))
+	}
+	limitOpt := ""
+	if max > 0 {
+		limitOpt = fmt.Sprintf("--limit=%d", max)
+	}
+	template := "{node|short}\n"
+	if scope != CoreRev {
+		template = hgRevTemplate
+	}
+	args := []string{"-R", r.LocalRepoPath(), "log", "-r", revset, limitOpt, "--template", template}
+	for _, path := range paths {
+		args = append(args, "path:"+path)
+	}
+	result, err := runWithEnv(r.Context(), hgPlainEnv, hgTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var revs []Revisioner
+	if scope == CoreRev {
+		for _, sha := range strings.Fields(result.Stdout) {
+			rev := &Revision{}
+			rev.SetCore(Rev(sha))
+			revs = append(revs, rev)
+		}
+		return revs, results, nil
+	}
+	revs, err = hgParseRevs(result.Stdout, r.SemVerPrefix())
+	if err != nil {
+		return nil, results, err
+	}
+	return revs, results, nil
+}
+
+// hgQuoteRev quotes a revision for safe use within an hg revset, this
+// allows tag or bookmark names with special characters (eg: "1.0-rc")
+func hgQuoteRev(rev Rev) string {
+	return fmt.Sprintf("'%s'", strings.Replace(strings.Replace(string(rev), "\\", "\\\\", -1), "'", "\\'", -1))
+}
+
+// hgParseRevs takes the output from an 'hg log' run using the hgRevTemplate
+// template and turns each revision record found into a fully populated
+// Revision (full node hash, user, timestamp, description, tags, semvers (by
+// the given semver prefix) and the named branch plus any bookmarks as
+// branches).  Revisions are returned in the order hg listed them along with
+// any error seen parsing the output.
+// Note that hg has no separate committer so author and committer match and
+// the "tip" pseudo-tag is skipped since it moves with every commit.
+func hgParseRevs(output, semVerPrefix string) ([]Revisioner, error) {
+	var revs []Revisioner
+	for _, record := range strings.Split(output, "\x1e") {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+		fields := strings.Split(record, "\x1f")
+		if len(fields) != 8 {
+			return nil, out.NewErrf(4517, "Unable to parse hg revision data, unexpected format:\n%s", record)
+		}
+		rev := &Revision{}
+		rev.SetCore(Rev(fields[0]))
+		rev.SetBranches(append([]Rev{Rev(fields[1])}, hgSplitList(fields[2])...))
+		var allTags []Rev
+		for _, tag := range hgSplitList(fields[3]) {
+			if tag != "tip" {
+				allTags = append(allTags, tag)
+			}
+		}
+		semVers, tags := splitSemVers(semVerPrefix, allTags)
+		rev.SetSemVers(semVers)
+		rev.SetTags(tags)
+		rev.SetUserInfo(AuthComm, fields[4], fields[5])
+		tstamp, err := hgParseDate(fields[6])
+		if err != nil {
+			return nil, out.WrapErrf(err, 4518, "Unable to parse hg timestamp for revision %s", fields[0])
+		}
+		rev.SetTStamp(AuthComm, tstamp)
+		rev.SetComment(fields[7])
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// hgSplitList splits a newline separated list from an hg template into
+// revisions, an empty string results in an empty list
+func hgSplitList(list string) []Rev {
+	var revs []Rev
+	for _, item := range strings.Split(list, "\n") {
+		if item != "" {
+			revs = append(revs, Rev(item))
+		}
+	}
+	return revs
+}
+
+// hgParseDate takes an hg date in "hgdate" format, "<unixtime> <offset>"
+// where the offset is the seconds *west* of UTC, and returns the time in
+// the timezone the revision was made in
+func hgParseDate(hgDate string) (*time.Time, error) {
+	parts := strings.Fields(hgDate)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid hg date format: \"%s\"", hgDate)
+	}
+	secs, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(secs, 0).In(time.FixedZone("", -offset))
+	return &t, nil
+}
+
+// HgRevCommit makes sure the tags, semvers and bookmarks stored in the given
+// revision all point at the revision's core rev in the local clone, creating
+// or moving them as needed.  Branches are mapped to bookmarks except for the
+// names of named branches in the repo (named branches can't be moved in hg
+// and a same named bookmark would shadow them).  Note
+// that hg tags live in .hgtags so any tag changes result in a new changeset
+// (made on top of the working dir parent).  Bookmarks and tag changesets
+// are remembered so a following HgRevPush() can publish them.  Params:
+//	w (*HgRevWriter): the hg rev writer (local clone, remote name, etc)
+//	rev (*Revision): revision with the core rev and refs that should exist
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func HgRevCommit(w *HgRevWriter, rev *Revision) (Resulter, error) {
+	results := newResults()
+	if rev == nil || rev.Core() == "" {
+		return results, out.NewErrf(4527, "Hg revision commit requires a core revision, clone: %s", w.LocalRepoPath())
+	}
+	runDir := w.LocalRepoPath()
+	result, err := runWithEnv(w.Context(), hgPlainEnv, hgTool, "-R", runDir, "log", "-r", string(rev.Core()), "--template", hgRevTemplate)
+	results.add(result)
+	if err != nil {
+		return results, err
+	}
+	current, err := hgParseRevs(result.Stdout, w.SemVerPrefix())
+	if err != nil {
+		return results, err
+	}
+	if len(current) != 1 {
+		return results, out.NewErrf(4528, "Hg revision commit unable to find revision %s, clone: %s", rev.Core(), w.LocalRepoPath())
+	}
+	node := string(current[0].Core())
+	existing := make(map[Rev]bool)
+	for _, ref := range current[0].Tags() {
+		existing[ref] = true
+	}
+	for _, ref := range current[0].SemVers() {
+		existing[ref] = true
+	}
+	for _, ref := range current[0].Branches() {
+		existing[ref] = true
+	}
+	if len(rev.Branches()) != 0 {
+		result, err = runWithEnv(w.Context(), hgPlainEnv, hgTool, "-R", runDir, "branches", "--closed", "--template", "{branch}\n")
+		results.add(result)
+		if err != nil {
+			return results, err
+		}
+		for _, branch := range strings.Split(result.Stdout, "\n") {
+			if branch != "" {
+				existing[Rev(branch)] = true // named branch, no bookmark
+			}
+		}
+	}
+
+	for _, bookmark := range rev.Branches() {
+		if existing[bookmark] {
+			continue
+		}
+		result, err = runWithEnv(w.Context(), hgPlainEnv, hgTool, "-R", runDir, "bookmark", "-f", "-r", node, string(bookmark))
+		results.add(result)
+		if err != nil {
+			return results, err
+		}
+		w.addPushBookmark(string(bookmark))
+	}
+	tagArgs := []string{"-R", runDir, "tag", "-f", "-r", node}
+	tagCount := 0
+	for _, tags := range [][]Rev{rev.Tags(), rev.SemVers()} {
+		for _, tag := range tags {
+			if !existing[tag] {
+				tagArgs = append(tagArgs, string(tag))
+				tagCount++
+			}
+		}
+	}
+	if tagCount == 0 {
+		return results, nil
+	}
+	result, err = runWithEnv(w.Context(), hgPlainEnv, hgTool, tagArgs...)
+	results.add(result)
+	if err != nil {
+		return results, err
+	}
+	// the tags were committed to .hgtags, that changeset needs pushing
+	result, err = runWithEnv(w.Context(), hgPlainEnv, hgTool, "-R", runDir, "log", "-r", ".", "--template", "{node}")
+	results.add(result)
+	if err != nil {
+		return results, err
+	}
+	w.pushRevs = append(w.pushRevs, strings.TrimSpace(result.Stdout))
+	return results, nil
+}
+
+// HgRevPush publishes any bookmarks and tag changesets from HgRevCommit() to
+// the remote repo (the remote name hg path, or the hg default push location
+// if no remote name).  If there is nothing to publish no hg cmds are run.
+// Params:
+//	w (*HgRevWriter): the hg rev writer (local clone, remote name, etc)
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func HgRevPush(w *HgRevWriter) (Resulter, error) {
+	results := newResults()
+	if len(w.pushBookmarks) == 0 && len(w.pushRevs) == 0 {
+		return results, nil
+	}
+	args := []string{"-R", w.LocalRepoPath(), "push"}
+	for _, pushRev := range w.pushRevs {
+		args = append(args, "-r", pushRev)
+	}
+	for _, bookmark := range w.pushBookmarks {
+		args = append(args, "-B", bookmark)
+	}
+	args = append(args, w.RemoteRepoName())
+	result, err := runWithEnv(w.Context(), hgPlainEnv, hgTool, args...)
+	results.add(result)
+	if err != nil && result.ExitCode == 1 {
+		err = nil // hg push exits 1 if no changesets pushed, bookmarks may be
+	}
+	if err == nil {
+		w.pushBookmarks = nil
+		w.pushRevs = nil
+	}
+	return results, err
+}
+
+// HgCommit adds and removes files as needed (addremove) for the given paths
+// (or all changes if no paths given) and commits them with the comment and
+// identity and timestamp from the given revision.  Hg has a single user and
+// date per changeset, the author is used if set, otherwise the committer
+// (the hg config and current time are used if neither is set).  Params:
+//	c (Describer): describes the local repo to commit in
+//	rev (*Revision): the comment (required), user info and timestamp to use
+//	paths (...string): optional; only commit these paths (relative to the repo)
+// Returns the new revision, results (vcs cmds run, output) and any error
+func HgCommit(c Describer, rev *Revision, paths ...string) (Revisioner, Resulter, error) {
+	results := newResults()
+	if err := checkCommitRev(c, rev); err != nil {
+		return nil, results, err
+	}
+	runDir := c.LocalRepoPath()
+	result, err := runInDir(c.Context(), runDir, hgPlainEnv, hgTool, append([]string{"addremove", "-q"}, paths...)...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	user := commitUser(rev, Author)
+	if user == "" {
+		user = commitUser(rev, Committer)
+	}
+	tstamp := rev.TStamp(Author)
+	if tstamp == nil {
+		tstamp = rev.TStamp(Committer)
+	}
+	dateOpt := ""
+	if tstamp != nil {
+		_, offset := tstamp.Zone() // hg wants seconds west of UTC
+		dateOpt = fmt.Sprintf("%d %d", tstamp.Unix(), -offset)
+	}
+	args := []string{"commit", "-m", rev.Comment()}
+	if user != "" {
+		args = append(args, "-u", user)
+	}
+	if dateOpt != "" {
+		args = append(args, "-d", dateOpt)
+	}
+	result, err = runInDir(c.Context(), runDir, hgPlainEnv, hgTool, append(args, paths...)...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	revs, logResults, err := HgRevLog(c, AllData, "", "", 1)
+	for _, logResult := range logResults.All() {
+		results.add(logResult)
+	}
+	if err != nil || len(revs) == 0 {
+		return nil, results, err
+	}
+	return revs[0], results, nil
+}
+
+// HgDiff returns the parsed diff between two revisions, or a revision and
+// the working dir, see the Differ interface for details.  Params:
+//	r (Describer): describes the local repo to diff
+//	from (Rev): the old revision, "" for the working dir parent (".")
+//	to (Rev): the new revision, "" for the working dir
+//	paths (...string): optional; only diff these paths (relative to the repo)
+// Returns the file diffs, results (vcs cmds run, output) and any error
+func HgDiff(r Describer, from, to Rev, paths ...string) ([]*FileDiff, Resulter, error) {
+	results := newResults()
+	args := []string{"diff", "--git", "-r", "."}
+	if from != "" {
+		args[3] = hgQuoteRev(from)
+	}
+	if to != "" {
+		args = append(args, "-r", hgQuoteRev(to))
+	}
+	args = append(args, paths...)
+	result, err := runInDir(r.Context(), r.LocalRepoPath(), hgPlainEnv, hgTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	files, err := parseDiff(result.Stdout)
+	return files, results, err
+}
+
+// HgStatus reads the status of the workspace: the files with local changes,
+// untracked files and unresolved merge conflicts, along with the number of
+// changesets in the working dir parent's history not yet in the remote
+// (Ahead) and the number of remote changesets not yet pulled (Behind).  The
+// remote is the RemoteRepoName hg path (eg: "default", or the hg default
+// locations if ""), note it is contacted to get the counts.  Params:
+//	s (Describer): describes the local repo to get the status of
+// Returns the status, results (vcs cmds run, output) and any error
+func HgStatus(s Describer) (*Status, Resulter, error) {
+	results := newResults()
+	runDir := s.LocalRepoPath()
+	result, err := runWithEnv(s.Context(), hgPlainEnv, hgTool, "-R", runDir, "status", "--copies")
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	resolveResult, err := runWithEnv(s.Context(), hgPlainEnv, hgTool, "-R", runDir, "resolve", "--list")
+	results.add(resolveResult)
+	if err != nil {
+		return nil, results, err
+	}
+	status := hgParseStatus(result.Stdout, resolveResult.Stdout)
+
+	// outgoing and incoming exit 1 if there are no changesets to list
+	result, err = runWithEnv(s.Context(), hgPlainEnv, hgTool, "-R", runDir, "outgoing", "--quiet", "-r", ".", "--template", "{node}\n", s.RemoteRepoName())
+	results.add(result)
+	if err != nil && result.ExitCode != 1 {
+		return nil, results, err
+	}
+	status.Ahead = countLines(result.Stdout)
+	result, err = runWithEnv(s.Context(), hgPlainEnv, hgTool, "-R", runDir, "incoming", "--quiet", "--template", "{node}\n", s.RemoteRepoName())
+	results.add(result)
+	if err != nil && result.ExitCode != 1 {
+		return nil, results, err
+	}
+	status.Behind = countLines(result.Stdout)
+	return status, results, nil
+}
+
+// hgParseStatus parses 'hg status --copies' output, with the unresolved
+// files from 'hg resolve --list' output, into the workspace file status.  A
+// file added as a copy of a removed file is reported as renamed.
+func hgParseStatus(output, resolveOutput string) *Status {
+	status := &Status{}
+	removed := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "R ") {
+			removed[line[2:]] = true
+		}
+	}
+	renamed := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		if len(line) < 3 {
+			continue
+		}
+		path := line[2:]
+		switch line[:2] {
+		case "M ":
+			status.Files = append(status.Files, FileStatus{Path: path, State: FileModified})
+		case "A ":
+			status.Files = append(status.Files, FileStatus{Path: path, State: FileAdded})
+		case "  ": // copy source of the file added just above
+			if len(status.Files) == 0 {
+				continue
+			}
+			if last := &status.Files[len(status.Files)-1]; last.State == FileAdded {
+				last.OrigPath = path
+				if removed[path] {
+					last.State = FileRenamed
+					renamed[path] = true
+				}
+			}
+		case "R ", "! ":
+			status.Files = append(status.Files, FileStatus{Path: path, State: FileDeleted})
+		case "? ":
+			status.Files = append(status.Files, FileStatus{Path: path, State: FileUntracked})
+		}
+	}
+	if len(renamed) != 0 { // drop the removes that are part of a rename
+		files := status.Files[:0]
+		for _, file := range status.Files {
+			if file.State != FileDeleted || !renamed[file.Path] {
+				files = append(files, file)
+			}
+		}
+		status.Files = files
+	}
+	for _, line := range strings.Split(resolveOutput, "\n") {
+		if strings.HasPrefix(line, "U ") {
+			status.addFile(line[2:], "", FileConflicted)
+		}
+	}
+	return status
+}
+
+// hgPushBookmarkRegex matches the bookmark changes in 'hg push' output
+var hgPushBookmarkRegex = regexp.MustCompile(`(?m)^(exporting|updating|deleting remote) bookmark (.+?)\r?$`)
+
+// HgPush publishes local changesets and bookmarks to the remote (the options
+// RemoteName or the RemoteRepoName hg path, or the hg default push location
+// if neither is set).  Each ref in the options is pushed as a bookmark if
+// there is a local bookmark of that name (":<name>" deletes the remote
+// bookmark), otherwise as a branch or revision.  Tags are changesets in hg
+// so they go with the revisions, there is no force-with-lease support and
+// an hg push is always atomic.  If no refs are given everything is pushed
+// and a single status (Local ".") is returned along with the status of any
+// bookmarks updated.  Params:
+//	p (Describer): describes the local repo to push from
+//	opts (*PushOptions): what to push and how, nil for the hg defaults
+// Returns the ref statuses, results (vcs cmds run, output) and any error
+func HgPush(p Describer, opts *PushOptions) ([]*RefPushStatus, Resulter, error) {
+	results := newResults()
+	if opts == nil {
+		opts = &PushOptions{}
+	}
+	if opts.ForceWithLease {
+		return nil, results, out.WrapErr(ErrNotImplemented, "Hg push has no force-with-lease support", 4544)
+	}
+	runDir := p.LocalRepoPath()
+	bookmarks := make(map[string]bool)
+	if len(opts.Refs) != 0 {
+		result, err := runWithEnv(p.Context(), hgPlainEnv, hgTool, "-R", runDir, "log", "-r", "bookmark()", "--template", "{join(bookmarks, \"\\n\")}\n")
+		results.add(result)
+		if err != nil {
+			return nil, results, err
+		}
+		for _, bookmark := range strings.Split(result.Stdout, "\n") {
+			if bookmark != "" {
+				bookmarks[bookmark] = true
+			}
+		}
+	}
+	args := []string{"-R", runDir, "push"}
+	if opts.Force {
+		args = append(args, "-f")
+	}
+	for _, ref := range opts.Refs {
+		switch {
+		case strings.HasPrefix(ref, ":"):
+			args = append(args, "-B", ref[1:])
+		case bookmarks[ref]:
+			args = append(args, "-B", ref)
+		default:
+			args = append(args, "-r", ref)
+		}
+	}
+	args = append(args, pushRemoteName(p, opts))
+	result, err := runWithEnv(p.Context(), hgPlainEnv, hgTool, args...)
+	results.add(result)
+	if err != nil && result.ExitCode == 1 && strings.Contains(result.Output, "no changes found") {
+		err = nil // hg push exits 1 if no changesets pushed, bookmarks may be
+	}
+	return hgParsePush(result.Output, opts.Refs, err != nil), results, err
+}
+
+// hgParsePush parses 'hg push' output into the status of each of the refs
+// pushed (or of the whole repo, Local ".", if none), any bookmark updates
+// not in the refs are added, if the push failed all refs are rejected
+func hgParsePush(output string, refs []string, failed bool) []*RefPushStatus {
+	reason := ""
+	if failed {
+		for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+			reason = strings.TrimSpace(line)
+			if strings.HasPrefix(reason, "abort: ") {
+				reason = strings.TrimSuffix(strings.TrimPrefix(reason, "abort: "), "!")
+				break
+			}
+		}
+	}
+	pushed := PushUpToDate
+	if strings.Contains(output, "\nadded ") || strings.HasPrefix(output, "added ") {
+		pushed = PushUpdated
+	}
+	bookmarkStates := make(map[string]RefPushState)
+	var bookmarks []string
+	for _, m := range hgPushBookmarkRegex.FindAllStringSubmatch(output, -1) {
+		state := PushUpdated
+		switch m[1] {
+		case "exporting":
+			state = PushNew
+		case "deleting remote":
+			state = PushDeleted
+		}
+		bookmarkStates[m[2]] = state
+		bookmarks = append(bookmarks, m[2])
+	}
+	var statuses []*RefPushStatus
+	if len(refs) == 0 {
+		statuses = append(statuses, &RefPushStatus{Local: ".", Remote: ".", State: pushed})
+	}
+	for _, ref := range refs {
+		status := &RefPushStatus{Local: ref, Remote: ref, State: pushed}
+		if strings.HasPrefix(ref, ":") {
+			status.Local, status.Remote = "", ref[1:]
+		}
+		if state, ok := bookmarkStates[status.Remote]; ok {
+			status.State = state
+			delete(bookmarkStates, status.Remote)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, bookmark := range bookmarks {
+		if state, ok := bookmarkStates[bookmark]; ok {
+			status := &RefPushStatus{Local: bookmark, Remote: bookmark, State: state}
+			if state == PushDeleted {
+				status.Local = ""
+			}
+			statuses = append(statuses, status)
+			delete(bookmarkStates, bookmark)
+		}
+	}
+	if failed {
+		for _, status := range statuses {
+			status.State = PushRejected
+			status.Reason = reason
+		}
+	}
+	return statuses
+}
+
+// HgRefs lists the named branches (closed ones too), tags (not the "tip"
+// pseudo tag) and bookmarks of the local repo with the changeset each one
+// targets.  Hg can't list the refs of a remote repo, a wrapped
+// ErrNotImplemented error is returned for the Remote location.  Params:
+//	r (Describer): describes the local repo
+//	l (Location): LocalPath to list the local repo refs
+// Returns the refs, results (vcs cmds run, output) and any error
+func HgRefs(r Describer, l Location) ([]*Ref, Resulter, error) {
+	results := newResults()
+	if l != LocalPath {
+		return nil, results, out.WrapErr(ErrNotImplemented, "Hg has no support for listing remote refs", 4545)
+	}
+	var refs []*Ref
+	for _, list := range []struct {
+		cmd     string
+		refType RefType
+		keyword string
+	}{{"branches", RefBranch, "branch"}, {"tags", RefTag, "tag"}, {"bookmarks", RefBookmark, "bookmark"}} {
+		args := []string{"-R", r.LocalRepoPath(), list.cmd, "--template", "{" + list.keyword + "}\x1f{node}\n"}
+		if list.refType == RefBranch {
+			args = append(args, "--closed")
+		}
+		result, err := runWithEnv(r.Context(), hgPlainEnv, hgTool, args...)
+		results.add(result)
+		if err != nil {
+			return nil, results, err
+		}
+		refs = append(refs, hgParseRefs(result.Stdout, list.refType)...)
+	}
+	return refs, results, nil
+}
+
+// hgParseRefs parses the "<name>\x1f<node>" lines of templated 'hg branches',
+// 'hg tags' or 'hg bookmarks' output into refs of the given type
+func hgParseRefs(output string, refType RefType) []*Ref {
+	var refs []*Ref
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\x1f", 2)
+		if len(fields) != 2 || (refType == RefTag && fields[0] == "tip") {
+			continue
+		}
+		refs = append(refs, &Ref{Name: fields[0], Type: refType, Rev: Rev(fields[1])})
+	}
+	return refs
+}
+
+// HgSparse returns the sparse paths of an hg clone (the include rules of
+// the hg sparse extension, "path:" prefixes dropped), none if the clone has
+// a full checkout.  Params:
+//	s (Describer): describes the local clone to read
+// Returns the sparse paths, results (vcs cmds run, output) and any error
+func HgSparse(s Describer) ([]string, Resulter, error) {
+	results := newResults()
+	args := []string{"-R", s.LocalRepoPath(), "--config", "extensions.sparse=", "debugsparse"}
+	result, err := runWithEnv(s.Context(), hgPlainEnv, hgTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	return hgParseSparse(result.Stdout), results, nil
+}
+
+// hgParseSparse parses the sparse config hg debugsparse dumps, returning
+// the include rules
+func hgParseSparse(output string) []string {
+	var paths []string
+	include := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "%"):
+		case strings.HasPrefix(line, "["):
+			include = line == "[include]"
+		case include:
+			paths = append(paths, strings.TrimPrefix(line, "path:"))
+		}
+	}
+	return paths
+}
+
+// HgSetSparse replaces the sparse paths of an hg clone and updates the
+// working copy to match, the sparse extension is turned on in the clone
+// hgrc so later updates honor them.  With no paths sparse is turned off.
+// Params:
+//	s (Describer): describes the local clone to change
+//	paths (...string): the directories (or hg patterns) to check out
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func HgSetSparse(s Describer, paths ...string) (Resulter, error) {
+	current, results, err := HgSparse(s)
+	if err != nil {
+		return results, err
+	}
+	runDir := s.LocalRepoPath()
+	args := []string{"-R", runDir, "--config", "extensions.sparse=", "debugsparse"}
+	if len(paths) == 0 {
+		if len(current) == 0 {
+			return results, nil
+		}
+		result, err := runWithEnv(s.Context(), hgPlainEnv, hgTool, append(args, "--reset")...)
+		results.add(result)
+		return results, err
+	}
+	if err = hgEnableSparse(runDir); err != nil {
+		return results, err
+	}
+	// include new paths before deleting old ones, with no rules at all the
+	// whole tree would be checked out in between
+	have := make(map[string]bool)
+	for _, path := range current {
+		have[path] = true
+	}
+	want := make(map[string]bool)
+	var includes, deletes []string
+	for _, path := range paths {
+		want[path] = true
+		if !have[path] {
+			includes = append(includes, "--include", hgSparsePattern(path))
+		}
+	}
+	for _, path := range current {
+		if !want[path] {
+			deletes = append(deletes, "--delete", hgSparsePattern(path))
+		}
+	}
+	for _, opts := range [][]string{includes, deletes} {
+		if opts == nil {
+			continue
+		}
+		result, err := runWithEnv(s.Context(), hgPlainEnv, hgTool, append(args, opts...)...)
+		results.add(result)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// hgSparsePattern returns the hg sparse rule for the given path, plain paths
+// are taken as directories (path:<dir>) and hg patterns used as given
+func hgSparsePattern(path string) string {
+	if strings.Contains(path, ":") {
+		return path
+	}
+	return "path:" + path
+}
+
+// hgEnableSparse turns on the sparse extension in the hgrc of the clone at
+// the given path (if not already on)
+func hgEnableSparse(path string) error {
+	hgrc := filepath.Join(path, ".hg", "hgrc")
+	content, err := ioutil.ReadFile(hgrc)
+	if err != nil && !os.IsNotExist(err) {
+		return out.WrapErrf(err, 4551, "Unable to read hg config: %s", hgrc)
+	}
+	if hgSparseExtRegex.Match(content) {
+		return nil
+	}
+	f, err := os.OpenFile(hgrc, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = f.WriteString(hgSparseHgrc)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return out.WrapErrf(err, 4551, "Unable to turn on the hg sparse extension in: %s", hgrc)
+	}
+	return nil
+}
+
+// HgNested returns the subrepos of an hg clone as recorded in the .hgsub
+// (paths and sources) and .hgsubstate (pinned revisions) of the working copy
+// parent revision.  Params:
+//	r (Describer): describes the local clone to read
+// Returns the subrepos, results (vcs cmds run, output) and any error
+func HgNested(r Describer) ([]*NestedRepo, Resulter, error) {
+	results := newResults()
+	var files []string
+	for _, name := range []string{".hgsub", ".hgsubstate"} {
+		result, err := runWithEnv(r.Context(), hgPlainEnv, hgTool, "-R", r.LocalRepoPath(), "cat", "-r", ".", name)
+		results.add(result)
+		if err != nil && result.ExitCode == 1 { // no such file, no subrepos
+			return nil, results, nil
+		}
+		if err != nil {
+			return nil, results, err
+		}
+		files = append(files, result.Stdout)
+	}
+	return sortNested(hgParseNested(r, files[0], files[1])), results, nil
+}
+
+// hgParseNested parses the .hgsub ("<path> = [<kind>]<source>" lines) and
+// .hgsubstate ("<rev> <path>" lines) files to get the subrepos of a clone
+func hgParseNested(r Describer, hgsub, hgsubstate string) []*NestedRepo {
+	revs := make(map[string]Rev)
+	for _, line := range strings.Split(hgsubstate, "\n") {
+		if fields := strings.SplitN(strings.TrimSpace(line), " ", 2); len(fields) == 2 {
+			revs[fields[1]] = Rev(fields[0])
+		}
+	}
+	var nested []*NestedRepo
+	section := ""
+	for _, line := range strings.Split(hgsub, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line // eg: [subpaths] remaps sources, not subrepos
+			continue
+		}
+		fields := strings.SplitN(line, "=", 2)
+		if section != "" || len(fields) != 2 {
+			continue
+		}
+		path := strings.TrimSpace(fields[0])
+		source := strings.TrimSpace(fields[1])
+		vcsType := Hg
+		for _, kind := range []Type{Git, Svn, Hg} {
+			if strings.HasPrefix(source, "["+string(kind)+"]") {
+				vcsType = kind
+				source = strings.TrimPrefix(source, "["+string(kind)+"]")
+			}
+		}
+		nested = append(nested, newNestedRepo(r, path, source, vcsType, revs[path]))
+	}
+	return nested
+}
+
+// HgExport writes an archive of the given revision ("" for the working dir
+// parent) of the local clone to the writer, see Exporter.  Params:
+//	r (RevReader): describes the local clone to export from
+//	w (io.Writer): where the archive is written
+//	rev (Rev): the revision to export, "" for the working dir parent
+//	opts (*ExportOptions): archive format, subdir and prefix (nil for a tar)
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func HgExport(r RevReader, w io.Writer, rev Rev, opts *ExportOptions) (Resulter, error) {
+	return exportArchive(r, w, rev, opts, hgExportTree)
+}
+
+// hgExportTree exports the files of a revision into the dir via 'hg
+// archive' (without the .hg_archival.txt metadata file), limited to the
+// subdir if one is given
+func hgExportTree(d Describer, dir string, rev Rev, subdir string) (string, Resulter, error) {
+	results := newResults()
+	args := []string{"-R", d.LocalRepoPath(), "--config", "ui.archivemeta=false", "archive", "-r", string(rev), "-t", "files"}
+	if subdir != "" {
+		args = append(args, "-I", "path:"+subdir)
+	}
+	args = append(args, dir)
+	result, err := runWithEnv(d.Context(), hgPlainEnv, hgTool, args...)
+	results.add(result)
+	if err != nil {
+		return "", results, err
+	}
+	return filepath.Join(dir, filepath.FromSlash(subdir)), results, nil
+}
+
 // HgExists verifies the local repo or remote location is a Hg repo,
 // returns where it was found ("" if not found), a resulter (cmds
 // run and their output to accomplish task) and and any error.  If
@@ -192,7 +1099,7 @@ func HgExists(e Existence, l Location) (string, Resulter, error) {
 		// if we have a scheme then just see if the repo exists...
 		if scheme != "" {
 			var result *Result
-			result, err = run(hgTool, "identify", remote)
+			result, err = run(e.Context(), hgTool, "identify", remote)
 			results.add(result)
 			if err == nil {
 				path = remote
@@ -201,7 +1108,7 @@ func HgExists(e Existence, l Location) (string, Resulter, error) {
 			vcsSchemes := e.Schemes()
 			for _, scheme = range vcsSchemes {
 				var result *Result
-				result, err = run(hgTool, "identify", scheme+"://"+remote)
+				result, err = run(e.Context(), hgTool, "identify", scheme+"://"+remote)
 				results.add(result)
 				if err == nil {
 					path = scheme + "://" + remote
@@ -235,22 +1142,13 @@ func HgCheckRemote(e Existence, remote string) (string, Resulter, error) {
 		}
 		// An Hg repo was found so test that the URL there matches
 		// the repo passed in here.
-		oldDir, err := os.Getwd()
-		if err != nil {
-			return remote, nil, err
-		}
-		err = os.Chdir(e.LocalRepoPath())
-		if err != nil {
-			return remote, nil, err
-		}
-		defer os.Chdir(oldDir)
-		result, err := run(hgTool, "paths")
+		result, err := run(e.Context(), hgTool, "-R", e.LocalRepoPath(), "paths")
 		results.add(result)
 		if err != nil {
 			return remote, results, err
 		}
 
-		outStr = result.Output
+		outStr = result.Stdout
 		m := hgDetectURL.FindStringSubmatch(outStr)
 		//FIXME: added that remote != "", think it's needed, check
 		if remote != "" && m[1] != "" && m[1] != remote {
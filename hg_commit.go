@@ -0,0 +1,38 @@
+package vcs
+
+// HgCommitter implements the ChangeCommitter interface for the
+// Mercurial source control.
+type HgCommitter struct {
+	Description
+}
+
+// NewHgCommitter creates a new instance of HgCommitter. The remote and
+// local directories need to be passed in.
+func NewHgCommitter(remote, localPath string) (*HgCommitter, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Hg. Need to report an error.
+	if err == nil && ltype != Hg {
+		return nil, ErrWrongVCS
+	}
+	c := &HgCommitter{}
+	c.setDescription(remote, "", localPath, defaultHgSchemes, Hg)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		c.setRemoteCheck(func() (string, Resulter, error) {
+			return HgCheckRemote(c, remote)
+		})
+		if _, err = c.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Commit support for hg committer
+func (c *HgCommitter) Commit(rev *Revision, paths ...string) (Revisioner, Resulter, error) {
+	return HgCommit(c, rev, paths...)
+}
+
+// Exists support for hg committer
+func (c *HgCommitter) Exists(l Location) (string, Resulter, error) {
+	return HgExists(c, l)
+}
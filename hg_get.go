@@ -4,11 +4,14 @@ package vcs
 type HgGetter struct {
 	Description
 	mirror bool
+	sparse []string
+	cache  string
 }
 
 // NewHgGetter creates a new instance of HgGetter. The remote and localPath directories
-// need to be passed in.
-func NewHgGetter(remote, remoteName, localPath string, mirror bool) (Getter, error) {
+// need to be passed in.  If sparse paths (directories) are given the clone
+// only checks out those, via the hg sparse extension (see SparseMgr).
+func NewHgGetter(remote, remoteName, localPath string, mirror bool, sparse ...string) (Getter, error) {
 	ltype, err := DetectVcsFromFS(localPath)
 	// Found a VCS other than Hg. Need to report an error.
 	if err == nil && ltype != Hg {
@@ -16,17 +19,26 @@ func NewHgGetter(remote, remoteName, localPath string, mirror bool) (Getter, err
 	}
 	g := &HgGetter{}
 	g.mirror = mirror
+	g.sparse = sparse
 	g.setDescription(remote, "", localPath, defaultHgSchemes, Hg)
 	if err == nil { // Have a localPath FS repo, try to validate/upd remote
-		remote, _, err = HgCheckRemote(g, remote)
-		if err != nil {
+		g.setRemoteCheck(func() (string, Resulter, error) {
+			return HgCheckRemote(g, remote)
+		})
+		if _, err = g.Check(); err != nil {
 			return nil, err
 		}
-		g.setRemote(remote)
 	}
 	return g, nil // note: above 'err' not used on purpose here..
 }
 
+// SetCacheDir sets a cache dir to pool the hg stores of remotes in (the hg
+// share extension share.pool), Get() then clones by sharing the pooled store
+// of the remote after pulling only new history into it ("" turns it off)
+func (g *HgGetter) SetCacheDir(cacheDir string) {
+	g.cache = cacheDir
+}
+
 // Get support for hg getter
 func (g *HgGetter) Get(rev ...Rev) (Resulter, error) {
 	return HgGet(g, rev...)
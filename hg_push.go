@@ -0,0 +1,38 @@
+package vcs
+
+// HgPusher implements the Pusher interface for the Mercurial source control.
+type HgPusher struct {
+	Description
+}
+
+// NewHgPusher creates a new instance of HgPusher. The remote and local
+// directories need to be passed in, the remote name is the hg path name to
+// push to (eg: "default", or "" to use the hg default push location).
+func NewHgPusher(remote, remoteName, localPath string) (*HgPusher, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Hg. Need to report an error.
+	if err == nil && ltype != Hg {
+		return nil, ErrWrongVCS
+	}
+	p := &HgPusher{}
+	p.setDescription(remote, remoteName, localPath, defaultHgSchemes, Hg)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		p.setRemoteCheck(func() (string, Resulter, error) {
+			return HgCheckRemote(p, remote)
+		})
+		if _, err = p.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil // note: above 'err' not used on purpose here..
+}
+
+// Push support for hg pusher
+func (p *HgPusher) Push(opts *PushOptions) ([]*RefPushStatus, Resulter, error) {
+	return HgPush(p, opts)
+}
+
+// Exists support for hg pusher
+func (p *HgPusher) Exists(l Location) (string, Resulter, error) {
+	return HgExists(p, l)
+}
@@ -1,5 +1,7 @@
 package vcs
 
+import "io"
+
 // HgReader implements the Repo interface for the Mercurial source control.
 type HgReader struct {
 	Description
@@ -16,11 +18,12 @@ func NewHgReader(remote, localPath string) (*HgReader, error) {
 	r := &HgReader{}
 	r.setDescription(remote, "", localPath, defaultHgSchemes, Hg)
 	if err == nil { // Have a localPath FS repo, try to validate/upd remote
-		remote, _, err = HgCheckRemote(r, remote)
-		if err != nil {
+		r.setRemoteCheck(func() (string, Resulter, error) {
+			return HgCheckRemote(r, remote)
+		})
+		if _, err = r.Check(); err != nil {
 			return nil, err
 		}
-		r.setRemote(remote)
 	}
 	return r, nil // note: above 'err' not used on purpose here..
 }
@@ -35,6 +38,31 @@ func (r *HgReader) RevRead(scope ReadScope, vcsRev ...Rev) ([]Revisioner, Result
 	return HgRevRead(r, scope, vcsRev...)
 }
 
+// RevLog support for hg reader
+func (r *HgReader) RevLog(scope ReadScope, from, to Rev, max int, paths ...string) ([]Revisioner, Resulter, error) {
+	return HgRevLog(r, scope, from, to, max, paths...)
+}
+
+// Diff support for hg reader
+func (r *HgReader) Diff(from, to Rev, paths ...string) ([]*FileDiff, Resulter, error) {
+	return HgDiff(r, from, to, paths...)
+}
+
+// Nested support for hg reader
+func (r *HgReader) Nested() ([]*NestedRepo, Resulter, error) {
+	return HgNested(r)
+}
+
+// Export support for hg reader
+func (r *HgReader) Export(w io.Writer, rev Rev, opts *ExportOptions) (Resulter, error) {
+	return HgExport(r, w, rev, opts)
+}
+
+// Refs support for hg reader
+func (r *HgReader) Refs(l Location) ([]*Ref, Resulter, error) {
+	return HgRefs(r, l)
+}
+
 // Exists support for hg reader
 func (r *HgReader) Exists(l Location) (string, Resulter, error) {
 	return HgExists(r, l)
@@ -0,0 +1,37 @@
+package vcs
+
+// HgSparseMgr implements the SparseMgr interface for the Mercurial source
+// control (via the hg sparse extension).
+type HgSparseMgr struct {
+	Description
+}
+
+// NewHgSparseMgr creates a new instance of HgSparseMgr. The localPath dir
+// for the clone should be passed in (the clone must exist).
+func NewHgSparseMgr(localPath string) (*HgSparseMgr, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Hg. Need to report an error.
+	if err == nil && ltype != Hg {
+		return nil, ErrWrongVCS
+	} else if err != nil {
+		return nil, err
+	}
+	s := &HgSparseMgr{}
+	s.setDescription("", "", localPath, defaultHgSchemes, Hg)
+	return s, nil
+}
+
+// Sparse support for hg sparse manager
+func (s *HgSparseMgr) Sparse() ([]string, Resulter, error) {
+	return HgSparse(s)
+}
+
+// SetSparse support for hg sparse manager
+func (s *HgSparseMgr) SetSparse(paths ...string) (Resulter, error) {
+	return HgSetSparse(s, paths...)
+}
+
+// Exists support for hg sparse manager
+func (s *HgSparseMgr) Exists(l Location) (string, Resulter, error) {
+	return HgExists(s, l)
+}
@@ -0,0 +1,40 @@
+package vcs
+
+// HgStatusReader implements the StatusReader interface for the Mercurial
+// source control.
+type HgStatusReader struct {
+	Description
+}
+
+// NewHgStatusReader creates a new instance of HgStatusReader. The remote and
+// local directories need to be passed in, the remote name is the hg path
+// name to compare with for the ahead/behind counts (eg: "default", or ""
+// to use the hg default locations).
+func NewHgStatusReader(remote, remoteName, localPath string) (*HgStatusReader, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Hg. Need to report an error.
+	if err == nil && ltype != Hg {
+		return nil, ErrWrongVCS
+	}
+	s := &HgStatusReader{}
+	s.setDescription(remote, remoteName, localPath, defaultHgSchemes, Hg)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		s.setRemoteCheck(func() (string, Resulter, error) {
+			return HgCheckRemote(s, remote)
+		})
+		if _, err = s.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil // note: above 'err' not used on purpose here..
+}
+
+// Status support for hg status reader
+func (s *HgStatusReader) Status() (*Status, Resulter, error) {
+	return HgStatus(s)
+}
+
+// Exists support for hg status reader
+func (s *HgStatusReader) Exists(l Location) (string, Resulter, error) {
+	return HgExists(s, l)
+}
@@ -1,16 +1,22 @@
 package vcs
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/dvln/out"
 )
 
 // Canary test to ensure HgReader implements the Reader interface.
 var _ Reader = &HgReader{}
 
-// To verify hg is working we perform intergration testing
-// with a known hg service.
+// To verify hg is working we perform integration testing
+// against a local fixture repo (see fixture_test.go).
 
 func TestHg(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "go-vcs-hg-tests")
@@ -23,8 +29,11 @@ func TestHg(t *testing.T) {
 			t.Error(err)
 		}
 	}()
+	fixture := newFixture(t, Hg, tempDir)
+	secondRev := fixture.revs["second"][:12]
+	tipRev := fixture.revs["tip"][:12]
 
-	hgGetter, err := NewHgGetter("https://bitbucket.org/dvln/testhgrepo", "", tempDir+"/testhgrepo", false)
+	hgGetter, err := NewHgGetter(fixture.remote, "", tempDir+"/testhgrepo", false)
 	if err != nil {
 		t.Fatalf("Unable to instantiate new Hg VCS reader, Err: %s", err)
 	}
@@ -34,18 +43,21 @@ func TestHg(t *testing.T) {
 	}
 
 	// Check the basic getters.
-	if hgGetter.Remote() != "https://bitbucket.org/dvln/testhgrepo" {
+	if hgGetter.Remote() != fixture.remote {
 		t.Error("Remote not set properly")
 	}
 	if hgGetter.LocalRepoPath() != tempDir+"/testhgrepo" {
 		t.Error("Local disk location not set properly")
 	}
 
-	// Do an initial clone.
+	// Do an initial clone, not a mirror so it has a working copy
 	_, err = hgGetter.Get()
 	if err != nil {
 		t.Fatalf("Unable to clone Hg repo. Err was %s", err)
 	}
+	if _, err = os.Stat(tempDir + "/testhgrepo/README"); err != nil {
+		t.Errorf("Expected the Hg clone to have a working copy, err: %s", err)
+	}
 
 	// Verify Hg repo is a Hg repo
 	path, _, err := hgGetter.Exists(LocalPath)
@@ -67,7 +79,7 @@ func TestHg(t *testing.T) {
 
 	// Test NewReader on existing checkout. This should simply provide a working
 	// instance without error based on looking at the local directory.
-	hgReader, err := NewReader("https://bitbucket.org/dvln/testhgrepo", tempDir+"/testhgrepo")
+	hgReader, err := NewReader(fixture.remote, tempDir+"/testhgrepo")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -81,23 +93,38 @@ func TestHg(t *testing.T) {
 	}
 
 	// Set the version using the short hash.
-	_, err = hgReader.RevSet("a5494ba2177f")
+	_, err = hgReader.RevSet(secondRev)
 	if err != nil {
 		t.Errorf("Unable to update Hg repo version. Err was %s", err)
 	}
 
 	// Use RevRead to verify we are on the right version.
 	v, _, err := hgReader.RevRead(CoreRev)
-	if string(v[0].Core()) != "a5494ba2177f" {
-		t.Error("Error checking checked out Hg version")
+	if v[0].Core() != secondRev {
+		t.Errorf("Error checking checked out Hg version, expected \"%s\", found: %s", secondRev, v[0].Core())
 	}
 	if err != nil {
 		t.Error(err)
 	}
 
+	// Use an AllData RevRead to verify the full revision data is populated
+	v, _, err = hgReader.RevRead(AllData)
+	if err != nil {
+		t.Fatalf("Unable to read full Hg revision data, err: %s", err)
+	}
+	if core := string(v[0].Core()); len(core) != 40 || !strings.HasPrefix(core, string(secondRev)) {
+		t.Errorf("Error checking full Hg node hash, found: %s", core)
+	}
+	if name, _ := v[0].UserInfo(Author); name == "" || v[0].TStamp(Author) == nil {
+		t.Error("Full Hg revision read did not populate the user or date")
+	}
+	if len(v[0].Branches()) == 0 {
+		t.Error("Full Hg revision read did not populate the branch")
+	}
+
 	// Perform an update.
 	mirror := true
-	hgUpdater, err := NewUpdater("https://bitbucket.org/dvln/testhgrepo", "", tempDir+"/testhgrepo", !mirror, RebaseFalse, nil)
+	hgUpdater, err := NewUpdater(fixture.remote, "", tempDir+"/testhgrepo", !mirror, RebaseFalse, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -107,8 +134,8 @@ func TestHg(t *testing.T) {
 	}
 
 	v, _, err = hgReader.RevRead(CoreRev)
-	if string(v[0].Core()) != "c5630b34f648" {
-		t.Errorf("Error checking checked out Hg version, expeced \"c5630b34f648\", found: %s", string(v[0].Core()))
+	if v[0].Core() != tipRev {
+		t.Errorf("Error checking checked out Hg version, expected \"%s\", found: %s", tipRev, v[0].Core())
 	}
 	if err != nil {
 		t.Error(err)
@@ -139,6 +166,29 @@ func TestHgExists(t *testing.T) {
 		t.Error("Hg Exists() does not identify non-Hg location")
 	}
 
+	// Try remote Hg existence checks via a Getter on a local fixture repo
+	fixture := newFixture(t, Hg, tempDir)
+	hgGetter, err := NewHgGetter(fixture.url, "", tempDir+"/testhgrepo", false)
+	if err != nil {
+		t.Fatalf("Failed to initialize new Hg getter, error: %s", err)
+	}
+	path, _, err = hgGetter.Exists(Remote)
+	if err != nil || path != fixture.url {
+		t.Fatalf("Failed to find remote repo that should exist (URL: %s), found: %s, error: %s", fixture.url, path, err)
+	}
+	badurl := fixture.url + "-notexist"
+	hgGetter, err = NewHgGetter(badurl, "", tempDir+"/testhgrepo", false)
+	if err != nil {
+		t.Fatalf("Failed to initialize \"bad\" Hg getter, init should work, error: %s", err)
+	}
+	path, _, err = hgGetter.Exists(Remote)
+	if err == nil || path != "" {
+		t.Fatalf("Failed to detect an error scanning for bad VCS location (loc: %s), found path: %s", badurl, path)
+	}
+
+	// Remote VCS and scheme detection needs a real remote host
+	skipUnlessNetwork(t)
+
 	// Test NewReader when there's no local. This should simply provide a working
 	// instance without error based on looking at the remote localtion.
 	_, nrerr := NewReader("https://bitbucket.org/dvln/testhgrepo", tempDir+"/testhgrepo")
@@ -148,7 +198,7 @@ func TestHgExists(t *testing.T) {
 
 	// Try remote Hg existence checks via a Getter
 	url1 := "bitbucket.org/dvln/testhgrepo"
-	hgGetter, err := NewHgGetter(url1, "", tempDir, false)
+	hgGetter, err = NewHgGetter(url1, "", tempDir, false)
 	if err != nil {
 		t.Fatalf("Failed to initialize new Hg getter, error: %s", err)
 	}
@@ -204,3 +254,96 @@ func TestHgExists(t *testing.T) {
 		t.Fatalf("Unexpectedly found a repo when shouldn't have (URL: %s), found path: %s", badurl2, err)
 	}
 }
+
+// TestHgParseRevs verifies full revision data parsing of hg log output
+func TestHgParseRevs(t *testing.T) {
+	output := "1a45e49a6bed58ac6e84b9f41f7cd9e5e1ad0a97\x1fstable\x1f@\x1f3.5.1\ntip\x1f" +
+		"Matt Mackall\x1fmpm@selenic.com\x1f1441141687 18000\x1fhgweb: fix trust of templates path (BC)\x1e"
+	revs, err := hgParseRevs(output, "")
+	if err != nil {
+		t.Fatalf("Failed to parse hg revision data, err: %s", err)
+	}
+	if len(revs) != 1 {
+		t.Fatalf("Expected 1 parsed hg revision, found: %d", len(revs))
+	}
+	rev := revs[0]
+	if rev.Core() != "1a45e49a6bed58ac6e84b9f41f7cd9e5e1ad0a97" {
+		t.Errorf("Parsed hg core revision incorrect, found: %s", rev.Core())
+	}
+	if name, id := rev.UserInfo(Committer); name != "Matt Mackall" || id != "mpm@selenic.com" {
+		t.Errorf("Parsed hg user incorrect, found: %s <%s>", name, id)
+	}
+	tstamp := rev.TStamp(Author)
+	if tstamp == nil || tstamp.Unix() != 1441141687 {
+		t.Fatalf("Parsed hg timestamp incorrect, found: %v", tstamp)
+	}
+	if _, offset := tstamp.Zone(); offset != -18000 {
+		t.Errorf("Parsed hg timezone offset incorrect, found: %d", offset)
+	}
+	if fmt.Sprint(rev.Branches()) != "[stable @]" {
+		t.Errorf("Parsed hg branches/bookmarks incorrect, found: %v", rev.Branches())
+	}
+	if fmt.Sprint(rev.SemVers()) != "[3.5.1]" || len(rev.Tags()) != 0 {
+		t.Errorf("Parsed hg semvers/tags incorrect, found: %v / %v", rev.SemVers(), rev.Tags())
+	}
+	if rev.Comment() != "hgweb: fix trust of templates path (BC)" {
+		t.Errorf("Parsed hg comment incorrect, found: %q", rev.Comment())
+	}
+}
+
+// TestParallelHgGetUpd runs several Hg get/update/read cycles at the same
+// time to look for race issues (run with: go test -race)
+func TestParallelHgGetUpd(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-hg-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Hg, tempDir)
+	var wg sync.WaitGroup
+	wg.Add(4)
+	for i := 1; i <= 4; i++ {
+		go runGetUpdRead(t, &wg, fixture.remote, Hg)
+	}
+	wg.Wait()
+}
+
+// TestHgUpdateModes verifies the hg cmds run for mirror, rebase and refs
+// updates
+func TestHgUpdateModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		mirror bool
+		rebase RebaseVal
+		refs   map[string]RefOp
+		rev    []Rev
+		cmds   []string
+	}{
+		{"default", false, RebaseFalse, nil, nil, []string{"hg pull", "hg update"}},
+		{"rev", false, RebaseUser, nil, []Rev{"v1.0.0"}, []string{"hg pull", "hg update -r v1.0.0"}},
+		{"mirror", true, RebaseTrue, nil, nil, []string{"hg pull"}},
+		{"rebase", false, RebaseTrue, nil, nil, []string{"hg --config extensions.rebase= pull --rebase"}},
+		{"rebase rev", false, RebasePreserve, nil, []Rev{"tip"}, []string{"hg --config extensions.rebase= pull --rebase", "hg update -r tip"}},
+		{"refs", false, RebaseTrue, map[string]RefOp{"feature": RefFetch, "bookmark:old": RefDelete, "branch:testbr1": RefFetch},
+			nil, []string{"hg bookmark -d old", "hg pull -b testbr1", "hg pull -B feature"}},
+	}
+	for _, test := range tests {
+		runner := &cmdLogRunner{}
+		u := &HgUpdater{mirror: test.mirror, rebase: test.rebase, refs: test.refs}
+		u.setDescription("", "", "/no/such/hgrepo", defaultHgSchemes, Hg)
+		u.SetRunner(runner)
+		if results, err := HgUpdate(u, test.rev...); err != nil {
+			t.Errorf("Unable to run %s hg update, err: %s, results:\n%s", test.name, err, results)
+		}
+		if !reflect.DeepEqual(runner.cmds, test.cmds) {
+			t.Errorf("Incorrect %s hg update cmds, expected: %q, found: %q", test.name, test.cmds, runner.cmds)
+		}
+	}
+
+	u := &HgUpdater{refs: map[string]RefOp{"branch:testbr1": RefDelete}}
+	u.setDescription("", "", "/no/such/hgrepo", defaultHgSchemes, Hg)
+	u.SetRunner(&cmdLogRunner{})
+	if _, err := HgUpdate(u); !out.IsError(err, ErrNotImplemented) {
+		t.Errorf("Expected hg branch delete to not be implemented, err: %v", err)
+	}
+}
@@ -17,7 +17,8 @@ type HgUpdater struct {
 //	mirror (bool): if a full mirroring of all content is desired
 //	rebase (RebaseVal): if rebase wanted or not, what type
 //	refs (map[string]RefOp): list of refs to act on w/given operation (or nil)
-// Currently ignores mirror, rebase and refs.
+//	- refs are bookmarks, or branches if given as "branch:<name>"
+// See HgUpdate() for how mirror, rebase and refs change the update.
 func NewHgUpdater(remote, remoteName, localPath string, mirror bool, rebase RebaseVal, refs map[string]RefOp) (Updater, error) {
 	ltype, err := DetectVcsFromFS(localPath)
 	// Found a VCS other than Hg. Need to report an error.
@@ -33,11 +34,12 @@ func NewHgUpdater(remote, remoteName, localPath string, mirror bool, rebase Reba
 	}
 	u.setDescription(remote, remoteName, localPath, defaultHgSchemes, Hg)
 	if err == nil { // Have a localPath FS repo, try to validate/upd remote
-		remote, _, err = HgCheckRemote(u, remote)
-		if err != nil {
+		u.setRemoteCheck(func() (string, Resulter, error) {
+			return HgCheckRemote(u, remote)
+		})
+		if _, err = u.Check(); err != nil {
 			return nil, err
 		}
-		u.setRemote(remote)
 	}
 	return u, nil // note: above 'err' not used on purpose here..
 }
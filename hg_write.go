@@ -0,0 +1,57 @@
+package vcs
+
+// HgRevWriter implements the RevWriter interface for the Mercurial source
+// control, it tracks the bookmarks and tag changesets RevCommit() created
+// or moved so that RevPush() knows what to publish
+type HgRevWriter struct {
+	Description
+	pushBookmarks []string
+	pushRevs      []string
+}
+
+// NewHgRevWriter creates a new instance of HgRevWriter. The remote and localPath
+// directories need to be passed in (remoteName is the hg path name to push to,
+// eg: "default", or "" to use the hg default push location).
+func NewHgRevWriter(remote, remoteName, localPath string) (*HgRevWriter, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Hg. Need to report an error.
+	if err == nil && ltype != Hg {
+		return nil, ErrWrongVCS
+	}
+	w := &HgRevWriter{}
+	w.setDescription(remote, remoteName, localPath, defaultHgSchemes, Hg)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		w.setRemoteCheck(func() (string, Resulter, error) {
+			return HgCheckRemote(w, remote)
+		})
+		if _, err = w.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil // note: above 'err' not used on purpose here..
+}
+
+// RevCommit support for hg revision writer
+func (w *HgRevWriter) RevCommit(rev *Revision) (Resulter, error) {
+	return HgRevCommit(w, rev)
+}
+
+// RevPush support for hg revision writer
+func (w *HgRevWriter) RevPush() (Resulter, error) {
+	return HgRevPush(w)
+}
+
+// Exists support for hg revision writer
+func (w *HgRevWriter) Exists(l Location) (string, Resulter, error) {
+	return HgExists(w, l)
+}
+
+// addPushBookmark remembers a bookmark to publish on the next push (once only)
+func (w *HgRevWriter) addPushBookmark(bookmark string) {
+	for _, pushBookmark := range w.pushBookmarks {
+		if pushBookmark == bookmark {
+			return
+		}
+	}
+	w.pushBookmarks = append(w.pushBookmarks, bookmark)
+}
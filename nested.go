@@ -0,0 +1,118 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NestedRepo is a repo nested in another one: a git submodule, hg subrepo
+// or svn external.  It is a Describer for the nested repo (its remote,
+// local path and VCS type) along with where it sits in the parent repo and
+// the revision the parent pins it to.
+type NestedRepo struct {
+	Description
+
+	// Path is where the nested repo is in the parent repo (relative)
+	Path string
+
+	// Rev is the revision the parent repo pins the nested repo to, "" if
+	// it isn't pinned (eg: an svn external with no revision given)
+	Rev Rev
+}
+
+// newNestedRepo returns a nested repo at the given path (relative to the
+// parent clone) with the given remote, VCS type and pinned revision, it
+// runs its cmds with the context and Runner of the parent and reads semver
+// tags with the same prefix
+func newNestedRepo(parent Describer, path, remote string, vcsType Type, rev Rev) *NestedRepo {
+	n := &NestedRepo{Path: filepath.ToSlash(path), Rev: rev}
+	localPath := filepath.Join(parent.LocalRepoPath(), filepath.FromSlash(path))
+	remoteName := ""
+	if vcsType == Git {
+		remoteName = "origin"
+	}
+	n.setDescription(nestedRemote(parent.Remote(), remote), remoteName, localPath, parent.Schemes(), vcsType)
+	n.SetContext(parent.Context())
+	n.SetRunner(parent.Runner())
+	n.SetSemVerPrefix(parent.SemVerPrefix())
+	return n
+}
+
+// nestedRemote returns the remote of a nested repo, a relative one (eg:
+// "../lib.git") is relative to the remote of the parent repo, if known
+func nestedRemote(parentRemote, remote string) string {
+	if parentRemote == "" || !(strings.HasPrefix(remote, "./") || strings.HasPrefix(remote, "../")) {
+		return remote
+	}
+	base := strings.TrimSuffix(parentRemote, "/")
+	for _, part := range strings.Split(remote, "/") {
+		switch part {
+		case ".", "":
+		case "..":
+			if i := strings.LastIndexAny(base, "/:"); i != -1 {
+				base = base[:i]
+			}
+		default:
+			base += "/" + part
+		}
+	}
+	return base
+}
+
+// sortNested sorts nested repos by their path in the parent repo
+func sortNested(nested []*NestedRepo) []*NestedRepo {
+	sort.SliceStable(nested, func(i, j int) bool {
+		return nested[i].Path < nested[j].Path
+	})
+	return nested
+}
+
+// NestedLister lists the repos nested in a repo
+type NestedLister interface {
+	// Describer access to VCS system details (Remote, LocalRepoPath, ..)
+	Describer
+
+	// Nested returns the repos nested in the local clone (not recursing
+	// into them, each can be listed in turn via its LocalRepoPath()) in
+	// path order: git submodules, hg subrepos or svn externals
+	Nested() ([]*NestedRepo, Resulter, error)
+}
+
+// NewNestedLister returns a VCS NestedLister based on trying to detect the
+// VCS sys from the remote and local repo locations.  Bzr has no nested repos
+// (ErrNotImplemented is returned by Nested()).  The appropriate
+// implementation will be returned or an ErrCannotDetectVCS if the VCS type
+// cannot be detected.
+func NewNestedLister(remote, localPath string, vcsType ...Type) (NestedLister, error) {
+	vtype, remote, err := detectVCSType(remote, localPath, vcsType...)
+	if err != nil {
+		return nil, err
+	}
+	switch vtype {
+	case Git:
+		return NewGitReader(remote, localPath)
+	case Svn:
+		return NewSvnReader(remote, localPath)
+	case Hg:
+		return NewHgReader(remote, localPath)
+	case Bzr:
+		return NewBzrReader(remote, localPath)
+	}
+
+	// Should never fall through to here but just in case.
+	return nil, ErrCannotDetectVCS
+}
@@ -0,0 +1,249 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// submoduleEnv allows file:// submodule remotes (off by default in newer
+// git versions) for the fixture cmds and the cmds run by getters/updaters
+var submoduleEnv = append(append([]string{}, fixtureEnv...),
+	"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=protocol.file.allow", "GIT_CONFIG_VALUE_0=always")
+
+// TestGitNested verifies recursive git gets and updates bring in the
+// submodules at their pinned commits and that submodules are listed
+func TestGitNested(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-nested-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+
+	// a parent repo with the fixture as a (relative URL) submodule pinned
+	// to the second commit
+	work := filepath.Join(tempDir, "parent-work")
+	parent := filepath.Join(tempDir, "parent.git")
+	fixture.run(tempDir, nil, gitTool, "init", "-q", work)
+	fixture.write(work, "README", "parent")
+	fixture.gitCommit(work, "parent", "commit", "-q", "-m", "parent commit")
+	fixture.run(work, submoduleEnv, gitTool, "submodule", "-q", "add", "../git-fixture.git", "lib")
+	fixture.run(filepath.Join(work, "lib"), nil, gitTool, "checkout", "-q", string(fixture.revs["second"]))
+	fixture.gitCommit(work, "pinned", "commit", "-q", "-m", "add lib submodule")
+	fixture.run(tempDir, nil, gitTool, "clone", "-q", "--bare", work, parent)
+	remote := "file://" + parent
+
+	localPath := filepath.Join(tempDir, "VCSTestRepo")
+	getter, err := NewGitGetter(remote, "", localPath, false)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git getter, err: %s", err)
+	}
+	getter.SetRecursive(true)
+	getter.SetRunner(envRunner{submoduleEnv})
+	if results, err := getter.Get(); err != nil {
+		t.Fatalf("Unable to recursively clone git repo, err: %s, results:\n%s", err, results)
+	}
+	checkNestedHead(t, fixture, filepath.Join(localPath, "lib"), "second")
+
+	lister, err := NewNestedLister(remote, localPath, Git)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git nested lister, err: %s", err)
+	}
+	lister.SetRunner(envRunner{submoduleEnv})
+	lister.SetSemVerPrefix("lib/")
+	nested, results, err := lister.Nested()
+	if err != nil {
+		t.Fatalf("Unable to list git submodules, err: %s, results:\n%s", err, results)
+	}
+	if len(nested) != 1 || nested[0].Path != "lib" || nested[0].Rev != fixture.revs["second"] ||
+		nested[0].Remote() != fixture.url || nested[0].LocalRepoPath() != filepath.Join(localPath, "lib") || nested[0].Vcs() != Git {
+		t.Errorf("Incorrect git submodules listed, found: %+v", nested)
+	} else if nested[0].Runner() == nil || nested[0].SemVerPrefix() != "lib/" {
+		t.Errorf("Expected the git submodule to have the runner and semver prefix of the parent, found: %+v", nested[0])
+	}
+
+	// a bare clone lists the submodules of its HEAD commit (its remote is
+	// the parent work clone)
+	bareLister, err := NewNestedLister("", parent, Git)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git nested lister, err: %s", err)
+	}
+	nested, results, err = bareLister.Nested()
+	if err != nil || len(nested) != 1 || nested[0].Rev != fixture.revs["second"] || nested[0].Remote() != fixture.path {
+		t.Errorf("Incorrect bare git clone submodules listed, err: %v, results:\n%s", err, results)
+	}
+
+	// with no .gitmodules the submodule is still listed, with no remote
+	if err = os.Rename(filepath.Join(localPath, ".gitmodules"), filepath.Join(tempDir, "gitmodules")); err != nil {
+		t.Fatal(err)
+	}
+	nested, results, err = lister.Nested()
+	if err != nil || len(nested) != 1 || nested[0].Rev != fixture.revs["second"] || nested[0].Remote() != "" {
+		t.Errorf("Incorrect git submodules listed with no .gitmodules, err: %v, results:\n%s", err, results)
+	}
+	if err = os.Rename(filepath.Join(tempDir, "gitmodules"), filepath.Join(localPath, ".gitmodules")); err != nil {
+		t.Fatal(err)
+	}
+
+	// move the pin and update recursively
+	fixture.run(filepath.Join(work, "lib"), nil, gitTool, "checkout", "-q", string(fixture.revs["merge"]))
+	fixture.gitCommit(work, "repinned", "commit", "-q", "-m", "move lib submodule")
+	fixture.run(work, nil, gitTool, "push", "-q", parent, "master")
+	updater, err := NewGitUpdater(remote, "", localPath, false, RebaseFalse, nil)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git updater, err: %s", err)
+	}
+	updater.SetRecursive(true)
+	updater.SetRunner(envRunner{submoduleEnv})
+	if results, err := updater.Update(); err != nil {
+		t.Fatalf("Unable to recursively update git repo, err: %s, results:\n%s", err, results)
+	}
+	checkNestedHead(t, fixture, filepath.Join(localPath, "lib"), "merge")
+
+	// not recursive (the default), the submodule is left alone
+	plainPath := filepath.Join(tempDir, "VCSTestRepo2")
+	if getter, err = NewGitGetter(remote, "", plainPath, false); err != nil {
+		t.Fatalf("Unable to instantiate new git getter, err: %s", err)
+	}
+	if results, err := getter.Get(); err != nil {
+		t.Fatalf("Unable to clone git repo, err: %s, results:\n%s", err, results)
+	}
+	if _, err = os.Stat(filepath.Join(plainPath, "lib", "README")); !os.IsNotExist(err) {
+		t.Errorf("Expected git submodule to not be checked out, err: %v", err)
+	}
+}
+
+// TestSvnNested verifies svn externals are listed with full URLs, whether
+// given as full URLs or relative to the dir, repo root or server root
+func TestSvnNested(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-nested-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Svn, tempDir)
+	localPath := filepath.Join(tempDir, "VCSTestRepo")
+	fixture.checkout(localPath)
+	if err = os.Mkdir(filepath.Join(localPath, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fixture.run(localPath, nil, svnTool, "add", "-q", "sub")
+	second := string(fixture.revs["second"])
+	fixture.run(localPath, nil, svnTool, "propset", "-q", "svn:externals",
+		"^/branches/testbr1 br\n-r "+second+" "+fixture.url+"/tags/v1.0.0 tag\n", ".")
+	fixture.run(localPath, nil, svnTool, "propset", "-q", "svn:externals",
+		"../../tags/testtag up\n"+fixture.path+"/branches/testbr1 root\n", "sub")
+
+	lister, err := NewNestedLister(fixture.remote, localPath, Svn)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new svn nested lister, err: %s", err)
+	}
+	nested, results, err := lister.Nested()
+	if err != nil {
+		t.Fatalf("Unable to list svn externals, err: %s, results:\n%s", err, results)
+	}
+	expected := map[string]string{
+		"br":                         fixture.url + "/branches/testbr1",
+		"tag":                        fixture.url + "/tags/v1.0.0",
+		filepath.Join("sub", "up"):   fixture.url + "/tags/testtag",
+		filepath.Join("sub", "root"): fixture.url + "/branches/testbr1",
+	}
+	found := make(map[string]string)
+	for _, repo := range nested {
+		found[repo.Path] = repo.Remote()
+		if (repo.Path == "tag") != (repo.Rev == fixture.revs["second"]) {
+			t.Errorf("Incorrect svn external rev for %s, found: %q", repo.Path, repo.Rev)
+		}
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Incorrect svn externals listed, expected: %v, found: %v", expected, found)
+	}
+}
+
+// checkNestedHead verifies the commit checked out in a git submodule
+func checkNestedHead(t *testing.T, fixture *fixture, path, name string) {
+	if head := fixture.run(path, nil, gitTool, "rev-parse", "HEAD"); Rev(head) != fixture.revs[name] {
+		t.Errorf("Incorrect git submodule commit, expected: %s, found: %s", fixture.revs[name], head)
+	}
+}
+
+// TestParseNested verifies parsing of git submodules, hg subrepos and svn
+// externals and the resolving of relative nested repo remotes
+func TestParseNested(t *testing.T) {
+	parent := &Description{}
+	parent.setDescription("https://hg.example.com/proj", "", "/ws/proj", defaultHgSchemes, Hg)
+	hgsub := "lib = ../lib\n# comment\ntools/svnlib = [svn]http://svn.example.com/lib/trunk\n\n[subpaths]\nhttps://old = https://new\n"
+	hgsubstate := "0123456789abcdef lib\n42 tools/svnlib\n"
+	nested := hgParseNested(parent, hgsub, hgsubstate)
+	if len(nested) != 2 ||
+		nested[0].Path != "lib" || nested[0].Remote() != "https://hg.example.com/lib" || nested[0].Rev != "0123456789abcdef" || nested[0].Vcs() != Hg ||
+		nested[1].Path != "tools/svnlib" || nested[1].Remote() != "http://svn.example.com/lib/trunk" || nested[1].Rev != "42" || nested[1].Vcs() != Svn ||
+		nested[1].LocalRepoPath() != filepath.Join("/ws/proj", "tools", "svnlib") {
+		t.Errorf("Incorrect hg subrepos parsed, found: %+v", nested)
+	}
+
+	for _, test := range []struct {
+		line, path, url string
+		rev             Rev
+	}{
+		{"-r 42 http://svn.example.com/lib@40 lib", "lib", "http://svn.example.com/lib", "42"},
+		{"http://svn.example.com/lib@40 third/lib", "third/lib", "http://svn.example.com/lib", "40"},
+		{"^/lib/trunk lib", "lib", "^/lib/trunk", ""},
+		{"lib -r42 http://svn.example.com/lib", "lib", "http://svn.example.com/lib", "42"},
+		{"svn+ssh://me@svn.example.com/lib lib", "lib", "svn+ssh://me@svn.example.com/lib", ""},
+		{"# lib http://svn.example.com/lib", "", "", ""},
+		{"", "", "", ""},
+	} {
+		if path, url, rev := svnParseExternal(test.line); path != test.path || url != test.url || rev != test.rev {
+			t.Errorf("Incorrect svn external parsed from %q, found: %q, %q, %q", test.line, path, url, rev)
+		}
+	}
+
+	dirURL := "https://svn.example.com/repos/proj/trunk/src"
+	for _, test := range []struct {
+		url, expected string
+	}{
+		{"^/lib/trunk", "https://svn.example.com/repos/proj/lib/trunk"},
+		{"^/../other/trunk", "https://svn.example.com/repos/other/trunk"},
+		{"../../lib/trunk", "https://svn.example.com/repos/proj/lib/trunk"},
+		{"//mirror.example.com/repos/lib", "https://mirror.example.com/repos/lib"},
+		{"/repos/lib/trunk", "https://svn.example.com/repos/lib/trunk"},
+		{"svn://other.example.com/lib", "svn://other.example.com/lib"},
+	} {
+		if url := svnResolveExternal(test.url, dirURL, "https://svn.example.com/repos/proj"); url != test.expected {
+			t.Errorf("Incorrect svn external URL for %q, expected: %q, found: %q", test.url, test.expected, url)
+		}
+	}
+
+	lsTree := "100644 blob 1a2b\t.gitmodules\x00160000 commit 3c4d\tlib\x00160000 commit 5e6f\tthird party/\"odd\"\tlib\x00"
+	lsFiles := "100644 1a2b 0\t.gitmodules\x00160000 3c4d 0\tlib\x00160000 7a8b 1\tthird party/\"odd\"\tlib\x00" +
+		"160000 5e6f 2\tthird party/\"odd\"\tlib\x00160000 9c0d 3\tthird party/\"odd\"\tlib\x00"
+	modules := "submodule.lib.path lib\nsubmodule.lib.url ../lib.git\n" +
+		"submodule.odd.path third party/\"odd\"\tlib\nsubmodule.odd.url https://example.com/odd.git\n"
+	parent.setDescription("https://github.com/org/repo", "origin", "/ws/repo", defaultGitSchemes, Git)
+	for _, entries := range []string{lsTree, lsFiles} {
+		nested = gitParseNested(parent, entries, modules)
+		if len(nested) != 2 ||
+			nested[0].Path != "lib" || nested[0].Rev != "3c4d" || nested[0].Remote() != "https://github.com/org/lib.git" ||
+			nested[1].Path != "third party/\"odd\"\tlib" || nested[1].Rev != "5e6f" || nested[1].Remote() != "https://example.com/odd.git" {
+			t.Errorf("Incorrect git submodules parsed from %q, found: %+v", entries, nested)
+		}
+	}
+
+	for _, test := range []struct {
+		parent, remote, expected string
+	}{
+		{"https://github.com/org/repo", "../lib.git", "https://github.com/org/lib.git"},
+		{"https://github.com/org/repo/", "./lib", "https://github.com/org/repo/lib"},
+		{"git@github.com:org/repo.git", "../lib.git", "git@github.com:org/lib.git"},
+		{"git@github.com:org/repo.git", "https://example.com/lib", "https://example.com/lib"},
+		{"", "../lib.git", "../lib.git"},
+	} {
+		if remote := nestedRemote(test.parent, test.remote); remote != test.expected {
+			t.Errorf("Incorrect nested remote for %q in %q, expected: %q, found: %q", test.remote, test.parent, test.expected, remote)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+// PushOptions controls what a Pusher publishes and how, a nil *PushOptions
+// pushes with the VCS defaults (eg: the current branch for git)
+type PushOptions struct {
+	// RemoteName is the remote to push to, "" for the RemoteRepoName of
+	// the Pusher (git: remote name, hg: path name, bzr: location)
+	RemoteName string
+
+	// Refs are the refs to push: git refspecs (eg: "master",
+	// "refs/heads/dev:refs/heads/release" or ":gone" to delete), hg
+	// bookmarks, branches or revisions, unused for bzr
+	Refs []string
+
+	// Tags pushes all tags as well (git only, hg and bzr push the tags
+	// with the revisions anyway)
+	Tags bool
+
+	// Force pushes even if the remote refs don't fast-forward
+	Force bool
+
+	// ForceWithLease forces the push only if the remote refs are still at
+	// the expected revs (see Lease), git only
+	ForceWithLease bool
+
+	// Lease maps remote ref names to the revs they are expected to be at,
+	// if empty with ForceWithLease the remote-tracking refs are expected
+	Lease map[string]Rev
+
+	// Atomic asks that all refs are updated or none are (git only, if the
+	// remote supports it, an hg or bzr push is always all or nothing)
+	Atomic bool
+}
+
+// RefPushState is the outcome of pushing a single ref
+type RefPushState string
+
+// Ref push outcomes (see RefPushStatus)
+const (
+	// PushNew indicates the ref was created in the remote
+	PushNew RefPushState = "new"
+	// PushUpdated indicates the remote ref was updated (fast-forward)
+	PushUpdated RefPushState = "updated"
+	// PushForced indicates the remote ref was force updated
+	PushForced RefPushState = "forced"
+	// PushDeleted indicates the ref was deleted from the remote
+	PushDeleted RefPushState = "deleted"
+	// PushUpToDate indicates the remote ref already had the changes
+	PushUpToDate RefPushState = "uptodate"
+	// PushRejected indicates the remote ref was not updated (see Reason)
+	PushRejected RefPushState = "rejected"
+)
+
+// RefPushStatus is the result of pushing a single ref
+type RefPushStatus struct {
+	// Local is the local ref pushed, "" when deleting a remote ref
+	Local string
+
+	// Remote is the ref updated in the remote
+	Remote string
+
+	// State is the outcome of the push for this ref
+	State RefPushState
+
+	// OldRev and NewRev are the remote ref revs before and after the
+	// push, if known
+	OldRev, NewRev Rev
+
+	// Reason has the details for a rejected ref (eg: "non-fast-forward")
+	Reason string
+}
+
+// Accepted returns true if the remote accepted (or didn't need) the push
+func (s *RefPushStatus) Accepted() bool {
+	return s.State != PushRejected
+}
+
+// Pusher publishes local commits and refs to a remote repo
+type Pusher interface {
+	// Describer access to VCS system details (Remote, LocalRepoPath, ..)
+	Describer
+
+	// Push publishes to the remote as set in the options (nil for the VCS
+	// defaults) and returns the status of each ref pushed.  If any ref is
+	// rejected an error is returned along with the ref statuses.
+	Push(*PushOptions) ([]*RefPushStatus, Resulter, error)
+}
+
+// NewPusher returns a VCS Pusher based on the given VCS description info
+// about the remote and workspace (dir/path) locations.  Svn commits go
+// straight to the repo so there is nothing to push, ErrNotImplemented is
+// returned for svn.  Params:
+//	remote (string): URL of remote repo (can be "", remoteName will set it)
+//	remoteName (string): "" or remote repo "name" (eg: "origin" is default for git)
+//	localPath (string): Directory for the local repo/clone to push from
+//	vcsType (Type): optional; forcibly tell the pkg what the vcs type is (no auto-determination)
+func NewPusher(remote, remoteName, localPath string, vcsType ...Type) (Pusher, error) {
+	vtype, remote, err := detectVCSType(remote, localPath, vcsType...)
+	if err != nil {
+		return nil, err
+	}
+	switch vtype {
+	case Git:
+		return NewGitPusher(remote, remoteName, localPath)
+	case Hg:
+		return NewHgPusher(remote, remoteName, localPath)
+	case Bzr:
+		return NewBzrPusher(remote, remoteName, localPath)
+	case Svn:
+		return nil, ErrNotImplemented
+	}
+
+	// Should never fall through to here but just in case.
+	return nil, ErrCannotDetectVCS
+}
+
+// pushRemoteName returns the remote to push to for the given options
+func pushRemoteName(p Describer, opts *PushOptions) string {
+	if opts.RemoteName != "" {
+		return opts.RemoteName
+	}
+	return p.RemoteRepoName()
+}
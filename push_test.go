@@ -0,0 +1,199 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestPush verifies local commits can be pushed for each VCS type that
+// supports it (skipping those with no tools installed)
+func TestPush(t *testing.T) {
+	for _, vcsType := range []Type{Git, Hg, Bzr} {
+		vcsType := vcsType
+		t.Run(string(vcsType), func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "go-vcs-push-tests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+			fixture := newFixture(t, vcsType, tempDir)
+			localPath := filepath.Join(tempDir, "VCSTestRepo")
+			fixture.checkout(localPath)
+			committer, err := NewCommitter(fixture.remote, localPath, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS committer, err: %s", vcsType, err)
+			}
+			committer.SetRunner(envRunner{fixtureEnv})
+			fixture.write(localPath, "README", "pushed")
+			rev := NewRevision()
+			rev.SetComment("change to push")
+			newRev, results, err := committer.Commit(rev)
+			if err != nil {
+				t.Fatalf("Unable to commit %s changes, err: %s, results:\n%s", vcsType, err, results)
+			}
+
+			pusher, err := NewPusher(fixture.remote, "", localPath, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS pusher, err: %s", vcsType, err)
+			}
+			statuses, results, err := pusher.Push(nil)
+			if err != nil {
+				t.Fatalf("Unable to push %s changes, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if len(statuses) != 1 || statuses[0].State != PushUpdated || !statuses[0].Accepted() {
+				t.Errorf("Incorrect %s push status, found: %+v, results:\n%s", vcsType, statuses, results)
+			}
+			statuses, results, err = pusher.Push(nil)
+			if err != nil {
+				t.Fatalf("Unable to push %s again, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if len(statuses) != 1 || statuses[0].State != PushUpToDate {
+				t.Errorf("Incorrect %s up to date push status, found: %+v, results:\n%s", vcsType, statuses, results)
+			}
+
+			if vcsType == Git { // the bare remote should now have the new revision
+				if remoteRev := Rev(fixture.run(fixture.path, nil, gitTool, "rev-parse", "master")); remoteRev != newRev.Core() {
+					t.Errorf("Expected the pushed git revision %s in the remote, found: %s", newRev.Core(), remoteRev)
+				}
+			}
+		})
+	}
+	if _, err := NewPusher("", "", "", Svn); err != ErrNotImplemented {
+		t.Errorf("Expected an svn pusher to be unsupported, err: %v", err)
+	}
+}
+
+// TestGitPush verifies git push rejections, force-with-lease, atomic, tag
+// and delete pushes
+func TestGitPush(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	localPath := filepath.Join(tempDir, "VCSTestRepo")
+	otherPath := filepath.Join(tempDir, "OtherRepo")
+	fixture.run(tempDir, nil, gitTool, "clone", "-q", fixture.remote, localPath)
+	fixture.run(tempDir, nil, gitTool, "clone", "-q", fixture.remote, otherPath)
+	pusher, err := NewGitPusher(fixture.remote, "", localPath)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git pusher, err: %s", err)
+	}
+
+	// the other clone pushes first so the local master is behind
+	fixture.write(otherPath, "README", "other")
+	fixture.run(otherPath, nil, gitTool, "commit", "-q", "-a", "-m", "other commit")
+	fixture.run(otherPath, nil, gitTool, "push", "-q", "origin", "master")
+	otherRev := Rev(fixture.run(otherPath, nil, gitTool, "rev-parse", "HEAD"))
+	fixture.write(localPath, "README", "local")
+	fixture.run(localPath, nil, gitTool, "commit", "-q", "-a", "-m", "local commit")
+	statuses, results, err := pusher.Push(&PushOptions{Refs: []string{"master"}})
+	if err == nil || len(statuses) != 1 || statuses[0].Accepted() || statuses[0].Reason == "" {
+		t.Errorf("Expected a rejected git push, err: %v, found: %+v, results:\n%s", err, statuses, results)
+	}
+
+	// atomic: the new branch isn't created as master is rejected
+	fixture.run(localPath, nil, gitTool, "branch", "newbr")
+	statuses, results, err = pusher.Push(&PushOptions{Refs: []string{"master", "newbr"}, Atomic: true})
+	if err == nil || len(statuses) != 2 || statuses[0].Accepted() || statuses[1].Accepted() {
+		t.Errorf("Expected a rejected atomic git push, err: %v, found: %+v, results:\n%s", err, statuses, results)
+	}
+
+	// the lease fails while the remote isn't at the expected rev
+	lease := map[string]Rev{"refs/heads/master": fixture.revs["tip"]}
+	statuses, results, err = pusher.Push(&PushOptions{Refs: []string{"master"}, ForceWithLease: true, Lease: lease})
+	if err == nil || len(statuses) != 1 || statuses[0].State != PushRejected {
+		t.Errorf("Expected a rejected git lease push, err: %v, found: %+v, results:\n%s", err, statuses, results)
+	}
+	lease["refs/heads/master"] = otherRev
+	statuses, results, err = pusher.Push(&PushOptions{Refs: []string{"master", "newbr"}, ForceWithLease: true, Lease: lease, Tags: true})
+	if err != nil {
+		t.Fatalf("Unable to force push with a git lease, err: %s, results:\n%s", err, results)
+	}
+	expected := map[string]RefPushState{"refs/heads/master": PushForced, "refs/heads/newbr": PushNew, "refs/tags/v1.0.0": PushUpToDate}
+	for _, status := range statuses {
+		if state, ok := expected[status.Remote]; ok && state != status.State {
+			t.Errorf("Incorrect git push state for %s, expected: %s, found: %s", status.Remote, state, status.State)
+		}
+		delete(expected, status.Remote)
+	}
+	if len(expected) != 0 {
+		t.Errorf("Missing git push statuses, expected: %v, found: %+v", expected, statuses)
+	}
+
+	// and delete the new branch again
+	statuses, results, err = pusher.Push(&PushOptions{Refs: []string{":newbr"}})
+	if err != nil || len(statuses) != 1 || statuses[0].State != PushDeleted || statuses[0].Local != "" {
+		t.Errorf("Incorrect git delete push, err: %v, found: %+v, results:\n%s", err, statuses, results)
+	}
+}
+
+// TestParsePush verifies the push output of each VCS is parsed right
+func TestParsePush(t *testing.T) {
+	gitOutput := "To /tmp/remote.git\n" +
+		" \trefs/heads/master:refs/heads/master\t1a2b3c4..5d6e7f8\n" +
+		"+\trefs/heads/dev:refs/heads/dev\t1a2b3c4...5d6e7f8 (forced update)\n" +
+		"*\trefs/tags/v1.0.0:refs/tags/v1.0.0\t[new tag]\n" +
+		"-\t:refs/heads/old\t[deleted]\n" +
+		"=\trefs/heads/same:refs/heads/same\t[up to date]\n" +
+		"!\trefs/heads/topic:refs/heads/topic\t[rejected] (non-fast-forward)\n" +
+		"!\trefs/heads/hook:refs/heads/hook\t[remote rejected] (pre-receive hook declined)\n" +
+		"Done\n"
+	statuses, err := gitParsePush(gitOutput)
+	if err != nil {
+		t.Fatalf("Unable to parse git push output, err: %s", err)
+	}
+	checkPush(t, Git, statuses, []*RefPushStatus{
+		{Local: "refs/heads/master", Remote: "refs/heads/master", State: PushUpdated, OldRev: "1a2b3c4", NewRev: "5d6e7f8"},
+		{Local: "refs/heads/dev", Remote: "refs/heads/dev", State: PushForced, OldRev: "1a2b3c4", NewRev: "5d6e7f8", Reason: "forced update"},
+		{Local: "refs/tags/v1.0.0", Remote: "refs/tags/v1.0.0", State: PushNew},
+		{Remote: "refs/heads/old", State: PushDeleted},
+		{Local: "refs/heads/same", Remote: "refs/heads/same", State: PushUpToDate},
+		{Local: "refs/heads/topic", Remote: "refs/heads/topic", State: PushRejected, Reason: "non-fast-forward"},
+		{Local: "refs/heads/hook", Remote: "refs/heads/hook", State: PushRejected, Reason: "pre-receive hook declined"},
+	})
+	if _, err = gitParsePush("?\tbogus\n"); err == nil {
+		t.Errorf("Expected an error parsing bad git push output")
+	}
+
+	hgOutput := "pushing to /tmp/remote\nsearching for changes\nadding changesets\nadding manifests\n" +
+		"adding file changes\nadded 1 changesets with 1 changes to 1 files\nexporting bookmark newbm\n" +
+		"updating bookmark other\ndeleting remote bookmark gone\n"
+	checkPush(t, Hg, hgParsePush(hgOutput, []string{"default", "newbm", ":gone"}, false), []*RefPushStatus{
+		{Local: "default", Remote: "default", State: PushUpdated},
+		{Local: "newbm", Remote: "newbm", State: PushNew},
+		{Remote: "gone", State: PushDeleted},
+		{Local: "other", Remote: "other", State: PushUpdated},
+	})
+	hgOutput = "pushing to /tmp/remote\nsearching for changes\nabort: push creates new remote head 1a2b3c4d5e6f!\n" +
+		"(merge or see 'hg help push' for details about pushing new heads)\n"
+	checkPush(t, Hg, hgParsePush(hgOutput, nil, true), []*RefPushStatus{
+		{Local: ".", Remote: ".", State: PushRejected, Reason: "push creates new remote head 1a2b3c4d5e6f"},
+	})
+
+	bzrOutput := "bzr: ERROR: These branches have diverged.  See \"bzr help diverged-branches\" for more information.\n"
+	checkPush(t, Bzr, []*RefPushStatus{bzrParsePush(bzrOutput, "/tmp/remote", true)}, []*RefPushStatus{
+		{Local: ".", Remote: "/tmp/remote", State: PushRejected,
+			Reason: "These branches have diverged.  See \"bzr help diverged-branches\" for more information."},
+	})
+	checkPush(t, Bzr, []*RefPushStatus{bzrParsePush("All changes applied successfully.\nPushed up to revision 6.\n", "/tmp/remote", false)},
+		[]*RefPushStatus{{Local: ".", Remote: "/tmp/remote", State: PushUpdated, NewRev: "6"}})
+}
+
+// checkPush reports a test error if the ref push statuses aren't as expected
+func checkPush(t *testing.T, vcsType Type, statuses, expected []*RefPushStatus) {
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Incorrect %s push statuses, expected:", vcsType)
+		for _, status := range expected {
+			t.Errorf("  %+v", *status)
+		}
+		t.Errorf("found:")
+		for _, status := range statuses {
+			t.Errorf("  %+v", *status)
+		}
+	}
+}
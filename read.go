@@ -11,6 +11,10 @@ type Reader interface {
 	// (see URL above), this is like a "git log -1 --format=.." type of op
 	RevRead(ReadScope, ...Rev) ([]Revisioner, Resulter, error)
 
+	// RevLog is the key RevLogger intfc func, cannot use intfc (see URL
+	// above), this is like a "git log <rev1>..<rev2> -- <paths>" type of op
+	RevLog(ReadScope, Rev, Rev, int, ...string) ([]Revisioner, Resulter, error)
+
 	// RevSet is the key RevSetter intfc func (eg: git clone), cannot use intfc
 	// (see URL above), this is like a 'git checkout <rev>' type of op
 	RevSet(Rev) (Resulter, error)
@@ -0,0 +1,88 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestRevLog verifies revision ranges are walked newest first, excluding
+// the 'from' rev, for each VCS type (skipping those with no tools installed)
+func TestRevLog(t *testing.T) {
+	// the merge range has the testbr1 commit where the VCS walks all of the
+	// history, svn and bzr just walk the main line (trunk or mainline)
+	mergeRange := map[Type][]string{
+		Git: {"merge", "branch", "third"},
+		Hg:  {"merge", "third", "branch"},
+		Svn: {"merge", "third"},
+		Bzr: {"merge", "third"},
+	}
+	for _, vcsType := range []Type{Git, Hg, Svn, Bzr} {
+		vcsType := vcsType
+		t.Run(string(vcsType), func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "go-vcs-revlog-tests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+			fixture := newFixture(t, vcsType, tempDir)
+			localPath := filepath.Join(tempDir, "VCSTestRepo")
+			fixture.checkout(localPath)
+			reader, err := NewReader(fixture.remote, localPath, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS reader, err: %s", vcsType, err)
+			}
+			names := make(map[Rev]string) // by full and short (hg) rev
+			for name, rev := range fixture.revs {
+				if _, ok := names[rev]; !ok || name != "tip" {
+					names[rev] = name
+					if len(rev) > 12 {
+						names[rev[:12]] = name
+					}
+				}
+			}
+			revLog := func(scope ReadScope, from, to string, max int, paths ...string) ([]string, []Revisioner) {
+				revs, results, err := reader.RevLog(scope, fixture.revs[from], fixture.revs[to], max, paths...)
+				if err != nil {
+					t.Fatalf("Unable to read %s revisions %s..%s, err: %s, results:\n%s", vcsType, from, to, err, results)
+				}
+				var found []string
+				for _, rev := range revs {
+					found = append(found, names[rev.Core()])
+				}
+				return found, revs
+			}
+
+			found, revs := revLog(AllData, "first", "third", 0)
+			if !reflect.DeepEqual(found, []string{"third", "second"}) {
+				t.Errorf("Incorrect %s revisions first..third, found: %v", vcsType, found)
+			} else if revs[0].Comment() != "third commit" || revs[1].Comment() != "second commit" || revs[0].TStamp(Committer) == nil {
+				t.Errorf("Incorrect %s revision data first..third, found: %q, %q", vcsType, revs[0].Comment(), revs[1].Comment())
+			}
+			if found, _ = revLog(CoreRev, "", "third", 2); !reflect.DeepEqual(found, []string{"third", "second"}) {
+				t.Errorf("Incorrect %s revisions up to third (max 2), found: %v", vcsType, found)
+			}
+			if found, _ = revLog(CoreRev, "third", "third", 0); len(found) != 0 {
+				t.Errorf("Expected no %s revisions third..third, found: %v", vcsType, found)
+			}
+			if found, _ = revLog(CoreRev, "second", "merge", 0); !reflect.DeepEqual(found, mergeRange[vcsType]) {
+				t.Errorf("Incorrect %s revisions second..merge, expected: %v, found: %v", vcsType, mergeRange[vcsType], found)
+			}
+
+			// with a path filter only the revisions changing README are
+			// walked (not the branch or merge), up to the checked out rev
+			// when no 'to' rev is given
+			if found, _ = revLog(CoreRev, "first", "", 0, "README"); !reflect.DeepEqual(found, []string{"third", "second"}) {
+				t.Errorf("Incorrect %s revisions first.. for README, found: %v", vcsType, found)
+			}
+			if found, _ = revLog(CoreRev, "", "merge", 0, "README"); !reflect.DeepEqual(found, []string{"third", "second", "first"}) {
+				t.Errorf("Incorrect %s revisions up to merge for README, found: %v", vcsType, found)
+			}
+			if found, _ = revLog(CoreRev, "", "merge", 0, "BRANCH", "README"); len(found) < 4 || found[len(found)-1] != "first" {
+				t.Errorf("Incorrect %s revisions up to merge for BRANCH and README, found: %v", vcsType, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+// RefType is the kind of named ref
+type RefType string
+
+// Named ref types (see Ref)
+const (
+	// RefBranch is a branch (git, hg named branch, svn branches/<name> or
+	// trunk, bzr branch nick)
+	RefBranch RefType = "branch"
+	// RefTag is a tag (git, hg, bzr or svn tags/<name>)
+	RefTag RefType = "tag"
+	// RefBookmark is an hg bookmark
+	RefBookmark RefType = "bookmark"
+	// RefRemoteBranch is a git remote tracking branch of the remote name in
+	// the local clone (refs/remotes/<remote name>/<name>)
+	RefRemoteBranch RefType = "remote branch"
+)
+
+// Ref is a named ref (branch, tag or bookmark) and the core revision it
+// targets, for annotated git tags that is the tagged commit.  The name of
+// a remote branch is the branch name on the remote (no remote name prefix).
+type Ref struct {
+	Name string
+	Type RefType
+	Rev  Rev
+}
+
+// RefLister lists all the branches, tags and bookmarks of a repo
+type RefLister interface {
+	// Describer access to VCS system details (Remote, LocalRepoPath, ..)
+	Describer
+
+	// Refs returns every branch, tag and bookmark with the revision each
+	// targets, read from the local repo (LocalPath) or the remote (Remote).
+	// Branches come first, then remote branches (git local repo only), tags
+	// and then bookmarks.
+	Refs(Location) ([]*Ref, Resulter, error)
+}
+
+// NewRefLister returns a VCS RefLister based on trying to detect the VCS sys
+// from the remote and local repo locations.  Listing remote refs is not
+// supported for hg (ErrNotImplemented is returned by Refs()).  The
+// appropriate implementation will be returned or an ErrCannotDetectVCS if
+// the VCS type cannot be detected.
+func NewRefLister(remote, localPath string, vcsType ...Type) (RefLister, error) {
+	vtype, remote, err := detectVCSType(remote, localPath, vcsType...)
+	if err != nil {
+		return nil, err
+	}
+	switch vtype {
+	case Git:
+		return NewGitReader(remote, localPath)
+	case Svn:
+		return NewSvnReader(remote, localPath)
+	case Hg:
+		return NewHgReader(remote, localPath)
+	case Bzr:
+		return NewBzrReader(remote, localPath)
+	}
+
+	// Should never fall through to here but just in case.
+	return nil, ErrCannotDetectVCS
+}
+
+// sortRefs orders the refs by type: branches, remote branches, tags and then
+// bookmarks (the order within each type is kept)
+func sortRefs(refs []*Ref) []*Ref {
+	sorted := make([]*Ref, 0, len(refs))
+	for _, refType := range []RefType{RefBranch, RefRemoteBranch, RefTag, RefBookmark} {
+		for _, ref := range refs {
+			if ref.Type == refType {
+				sorted = append(sorted, ref)
+			}
+		}
+	}
+	return sorted
+}
@@ -0,0 +1,128 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dvln/out"
+)
+
+// TestRefs verifies the branches and tags of the local and remote repo can
+// be listed for each VCS type (skipping those with no tools installed)
+func TestRefs(t *testing.T) {
+	for _, vcsType := range []Type{Git, Hg, Svn, Bzr} {
+		vcsType := vcsType
+		t.Run(string(vcsType), func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "go-vcs-refs-tests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+			fixture := newFixture(t, vcsType, tempDir)
+			localPath := filepath.Join(tempDir, "VCSTestRepo")
+			getter, err := NewGetter(fixture.remote, "", localPath, false, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS getter, err: %s", vcsType, err)
+			}
+			if results, err := getter.Get(); err != nil {
+				t.Fatalf("Unable to get %s repo, err: %s, results:\n%s", vcsType, err, results)
+			}
+			lister, err := NewRefLister(fixture.remote, localPath, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS ref lister, err: %s", vcsType, err)
+			}
+
+			// an empty expected rev matches any rev (eg: svn tag copies)
+			tags := map[string]Rev{"v1.0.0": fixture.revs["second"], "testtag": fixture.revs["merge"]}
+			var branches, tracking, remoteBranches map[string]Rev
+			switch vcsType {
+			case Git: // the plain clone has the origin remote branches too
+				branches = map[string]Rev{"master": fixture.revs["tip"]}
+				remoteBranches = map[string]Rev{"master": fixture.revs["tip"], "testbr1": fixture.revs["branch"]}
+				tracking = remoteBranches
+			case Hg: // the tag changesets are on the default branch
+				branches = map[string]Rev{"default": fixture.revs["tip"], "testbr1": fixture.revs["branch"]}
+			case Svn: // the last revision changing each dir
+				branches = map[string]Rev{"trunk": fixture.revs["merge"], "testbr1": fixture.revs["branch"]}
+				remoteBranches = branches
+				tags = map[string]Rev{"v1.0.0": "", "testtag": fixture.revs["tip"]}
+			case Bzr:
+				branches = map[string]Rev{"VCSTestRepo": fixture.revs["tip"]}
+				remoteBranches = map[string]Rev{"bzr-fixture": fixture.revs["tip"]}
+			}
+			refs, results, err := lister.Refs(LocalPath)
+			if err != nil {
+				t.Fatalf("Unable to list %s local refs, err: %s, results:\n%s", vcsType, err, results)
+			}
+			checkRefs(t, vcsType, refs, branches, tracking, tags)
+
+			refs, results, err = lister.Refs(Remote)
+			if vcsType == Hg {
+				if !out.IsError(err, ErrNotImplemented) {
+					t.Errorf("Expected hg remote refs to be unsupported, err: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unable to list %s remote refs, err: %s, results:\n%s", vcsType, err, results)
+			}
+			checkRefs(t, vcsType, refs, remoteBranches, nil, tags)
+		})
+	}
+}
+
+// TestParseRefs verifies the ref list output of each VCS is parsed right
+func TestParseRefs(t *testing.T) {
+	gitOutput := "1a2b\trefs/heads/master\t\n3c4d\trefs/tags/annotated\t5e6f\n7a8b\trefs/tags/light\t\n" +
+		"1a2b\trefs/remotes/origin/HEAD\t\n1a2b\trefs/remotes/origin/master\t\n9c0d\trefs/remotes/origin/topic/x\t\n"
+	checkRefs(t, Git, gitParseRefs(gitOutput, "origin"), map[string]Rev{"master": "1a2b"}, map[string]Rev{"master": "1a2b", "topic/x": "9c0d"},
+		map[string]Rev{"annotated": "5e6f", "light": "7a8b"})
+	gitOutput = "1a2b\tHEAD\n1a2b\trefs/heads/master\n3c4d\trefs/tags/annotated\n5e6f\trefs/tags/annotated^{}\n9c0d\trefs/pull/1/head\n"
+	checkRefs(t, Git, gitParseRefs(gitOutput, "origin"), map[string]Rev{"master": "1a2b"}, nil, map[string]Rev{"annotated": "5e6f"})
+
+	hgOutput := "default\x1f1a2b\nold branch\x1f3c4d\n"
+	checkRefs(t, Hg, hgParseRefs(hgOutput, RefBranch), map[string]Rev{"default": "1a2b", "old branch": "3c4d"}, nil, nil)
+	checkRefs(t, Hg, hgParseRefs("tip\x1f1a2b\nv1.0.0\x1f3c4d\n", RefTag), nil, nil, map[string]Rev{"v1.0.0": "3c4d"})
+
+	bzrOutput := "testtag              5\nv1.0.0               2\nunmerged             ?\n"
+	checkRefs(t, Bzr, bzrParseTags(bzrOutput), nil, nil, map[string]Rev{"testtag": "5", "v1.0.0": "2", "unmerged": "?"})
+
+	layouts := map[string]string{
+		"file:///repo/trunk":             "file:///repo",
+		"file:///repo/proj/branches/1.x": "file:///repo/proj",
+		"file:///repo/proj/tags/v1/src":  "file:///repo/proj",
+		"file:///repo/proj":              "file:///repo/proj",
+	}
+	for svnURL, base := range layouts {
+		if found := svnLayoutBase(svnURL, "file:///repo"); found != base {
+			t.Errorf("Incorrect svn layout base for %s, expected: %s, found: %s", svnURL, base, found)
+		}
+	}
+}
+
+// checkRefs reports a test error if the refs don't match the expected
+// branch, remote branch and tag names and revs (an empty expected rev
+// matches any rev)
+func checkRefs(t *testing.T, vcsType Type, refs []*Ref, branches, remoteBranches, tags map[string]Rev) {
+	found := map[RefType]map[string]Rev{RefBranch: {}, RefRemoteBranch: {}, RefTag: {}}
+	for _, ref := range refs {
+		if found[ref.Type] == nil {
+			t.Errorf("Unexpected %s ref type, found: %+v", vcsType, *ref)
+			continue
+		}
+		found[ref.Type][ref.Name] = ref.Rev
+	}
+	for refType, expected := range map[RefType]map[string]Rev{RefBranch: branches, RefRemoteBranch: remoteBranches, RefTag: tags} {
+		if len(found[refType]) != len(expected) {
+			t.Errorf("Incorrect %s %s refs, expected: %v, found: %v", vcsType, refType, expected, found[refType])
+			continue
+		}
+		for name, rev := range expected {
+			if foundRev, ok := found[refType][name]; !ok || (rev != "" && foundRev != rev) {
+				t.Errorf("Incorrect %s %s %s, expected rev: %s, found: %s (exists: %t)", vcsType, refType, name, rev, foundRev, ok)
+			}
+		}
+	}
+}
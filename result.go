@@ -14,7 +14,9 @@
 package vcs
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/dvln/out"
 )
@@ -38,10 +40,45 @@ type Resulter interface {
 }
 
 // Result is a structure that satisfies the VCS Resulter implementation, used
-// by the <VCS>Reader and other <VCS> implementations (eg: GitUpdater)
+// by the <VCS>Reader and other <VCS> implementations (eg: GitUpdater).  It
+// records a single VCS cmd that was run, how it was run and what it output,
+// it can be serialized to JSON (eg: for an audit trail of all SCM cmds run).
 type Result struct {
-	Cmd    string
-	Output string
+	// Cmd is the cmd and args run, space joined (for display only)
+	Cmd string `json:"cmd"`
+
+	// Args is the cmd and its args exactly as run (argv), eg: the cmd
+	// run is Args[0] (eg: "git" or "/path/to/git")
+	Args []string `json:"args"`
+
+	// Dir is the working directory the cmd was run in
+	Dir string `json:"dir"`
+
+	// Env has any environment settings added for the cmd run (eg:
+	// "HGPLAIN=1"), the rest of the environment is inherited as-is
+	Env []string `json:"env,omitempty"`
+
+	// Output is the combined stdout and stderr output, interleaved in the
+	// order it was read (output written to both streams at nearly the same
+	// time may not be in the exact order the cmd wrote it), for display,
+	// cmd output is parsed from Stdout
+	Output string `json:"output"`
+
+	// Stdout is just the standard output of the cmd
+	Stdout string `json:"stdout"`
+
+	// Stderr is just the standard error output of the cmd
+	Stderr string `json:"stderr"`
+
+	// ExitCode is the exit status of the cmd, -1 if the cmd could not
+	// be started or it was killed by a signal (eg: on timeout)
+	ExitCode int `json:"exitCode"`
+
+	// Start is the time the cmd was started
+	Start time.Time `json:"start"`
+
+	// Duration is how long the cmd ran, in JSON this is in nanoseconds
+	Duration time.Duration `json:"duration"`
 }
 
 // Results is a structure that contains all the commands run and their
@@ -83,6 +120,16 @@ func (r *Results) add(result *Result) {
 	r.results = append(r.results, result)
 }
 
+// MarshalJSON implements json.Marshaler for the *Results type so all the
+// results are serialized, in the order run, as a JSON array of results
+func (r *Results) MarshalJSON() ([]byte, error) {
+	results := r.All()
+	if results == nil {
+		results = []*Result{}
+	}
+	return json.Marshal(results)
+}
+
 // String implements a stringer for the *Result type so we can print out string
 // representations for any result
 func (r *Result) String() string {
@@ -28,16 +28,6 @@ import (
 	"time"
 )
 
-/*
-func init() {
-// FIXME: could set default regex for semversion "matching", although
-// it may make more sense to put this within each VCS implemented and let
-// it be overridden/controlled at the codebase and pkg level for 'dvln' as
-// well as via config file (cfg would be global or per scm, codebase would
-// be codebase global or per pkg, perhaps inherited for group/codebase pkgs)
-}
-*/
-
 // ReadScope describes how revision read ops should be focused (*if* a choice for a given VCS)
 type ReadScope string
 
@@ -75,6 +65,7 @@ type Rev string
 // the interface works of course).
 type RevAccesser interface {
 	Core() Rev
+	RefVers() []Rev
 	SemVers() []Rev
 	Tags() []Rev
 	Branches() []Rev
@@ -88,6 +79,7 @@ type RevAccesser interface {
 // the interface could be used)
 type RevStorer interface {
 	SetCore(Rev)
+	SetRefVers([]Rev)
 	SetSemVers([]Rev)
 	SetTags([]Rev)
 	SetBranches([]Rev)
@@ -133,6 +125,16 @@ type RevReader interface {
 	RevRead(ReadScope, ...Rev) ([]Revisioner, Resulter, error)
 }
 
+// RevLogger walks the VCS history, reading the revisions in a range
+type RevLogger interface {
+	// Describer access to VCS system details (Remote, LocalRepoPath, ..)
+	Describer
+
+	// RevLog retrieves the revisions after a start rev up to an end rev,
+	// optionally limited to a max count and to changes to the given paths
+	RevLog(ReadScope, Rev, Rev, int, ...string) ([]Revisioner, Resulter, error)
+}
+
 // RevSetter changes the current workspace revision of a pkg/repo
 // Note: if you change any of these method sig's please check for their
 //       use across all files in this package, eg: get.go has copied the
@@ -151,10 +153,11 @@ type RevWriter interface {
 	Describer
 
 	// RevCommit examines the revision and verifies that all revision
-	// setting are applied (all tags, all branch latest, etc)... if
-	// anything is changed 'true' will be returned, only changes the
-	// workspace state (local clone for DVCS, in-memory structure
-	// only for CVCS)... see RevPush() for updating remote (central) VCS
+	// setting are applied (all tags, all branch latest, etc)... any
+	// changes made show up in the results (only lookups are run if
+	// nothing needed changing), only changes the workspace state (local
+	// clone for DVCS, in-memory structure only for CVCS)... see RevPush()
+	// for updating remote (central) VCS
 	RevCommit(*Revision) (Resulter, error)
 
 	// RevPush will push local revision changes to central clone/repo
@@ -173,7 +176,11 @@ type RevReadSetWriter interface {
 	// RevSet sets the revision of a package/repo (eg: git checkout)
 	RevSet(Rev) (Resulter, error)
 
-	// FIXME: get RevCommit and RevPush added in here
+	// RevCommit verifies all revision settings are applied locally (tags, etc)
+	RevCommit(*Revision) (Resulter, error)
+
+	// RevPush will push local revision changes to central clone/repo
+	RevPush() (Resulter, error)
 }
 
 // NewRevision will contruct a new empty revision structure
@@ -186,6 +193,12 @@ func (r *Revision) Core() Rev {
 	return r.core
 }
 
+// RefVers returns a list of VCS specific alternate references to the
+// revision (if any stored in the revision), eg: the bzr revision id
+func (r *Revision) RefVers() []Rev {
+	return r.refVers
+}
+
 // SemVers returns a list of semvers (if any stored in the revision)
 func (r *Revision) SemVers() []Rev {
 	return r.semVers
@@ -235,6 +248,13 @@ func (r *Revision) SetCore(rev Rev) {
 	r.core = rev
 }
 
+// SetRefVers sets any VCS specific alternate references to the revision
+// (eg: the bzr revision id when the core rev is a revno), these are never
+// written to the VCS as they are VCS defined.
+func (r *Revision) SetRefVers(refVers []Rev) {
+	r.refVers = refVers
+}
+
 // SetSemVers can set one or more semvers compat tags on this VCS revision
 // structure (note that when reading a revision these will be set as well
 // as possible depending upon a fast read or a data-heavy read), these
@@ -291,3 +311,29 @@ func (r *Revision) SetUserInfo(utype UserType, name string, userid string) {
 		r.committerID = userid
 	}
 }
+
+// splitSemVers takes a list of tags found on a revision and splits them
+// into semantic version compatible tags (the given semver prefix followed
+// by a semantic version, see SetSemVerPrefix) and all the remaining tags
+// (in that order), the ordering of the tags within each list is preserved.
+func splitSemVers(prefix string, allTags []Rev) ([]Rev, []Rev) {
+	var semVers, tags []Rev
+	for _, tag := range allTags {
+		if _, ok := semVerTag(tag, prefix); ok {
+			semVers = append(semVers, tag)
+		} else {
+			tags = append(tags, tag)
+		}
+	}
+	return semVers, tags
+}
+
+// revRange examines revisions handed to a RevRead to see if they describe
+// a range of revisions, ie: rev1, "..", rev2, if so the start and end rev
+// (either may be "") are returned along with true, otherwise false
+func revRange(vcsRev []Rev) (Rev, Rev, bool) {
+	if len(vcsRev) == 3 && vcsRev[1] == ".." {
+		return vcsRev[0], vcsRev[2], true
+	}
+	return "", "", false
+}
@@ -0,0 +1,363 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dvln/out"
+)
+
+// ErrNoReplay is returned (wrapped) by a ReplayRunner when there is no
+// recorded result (left) for the cmd being run
+var ErrNoReplay = errors.New("No recorded VCS cmd result to replay")
+
+// Runner runs the VCS cmds for all the VCS ops.  By default cmds are run via
+// os/exec (see ExecRunner) but another Runner can be set globally (see the
+// SetRunner func) or for a single VCS op (see Describer SetRunner), eg: to
+// intercept, mock or audit VCS cmds (see RecordingRunner and ReplayRunner)
+type Runner interface {
+	// Run runs the cmd with the given args (empty args already removed) in
+	// the given dir ("" for the current dir) with any env settings added to
+	// the current environment.  The cmd result is returned along with any
+	// error, if the cmd fails it should be a *CmdError (see CmdError) or, if
+	// the ctx ended the cmd, a wrapped ErrTimeout or ErrCanceled.
+	Run(ctx context.Context, dir string, env []string, cmd string, args ...string) (*Result, error)
+}
+
+var defaultRunner Runner = ExecRunner{} // global Runner, see SetRunner
+
+// runnerKey is the context key for the Runner of a VCS op (see Describer)
+type runnerKey struct{}
+
+// SetRunner sets the Runner used to run VCS cmds for all VCS ops that don't
+// have their own Runner set, nil restores the default (ExecRunner).  This is
+// goroutine safe.
+func SetRunner(runner Runner) {
+	mutex.Lock()
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	defaultRunner = runner
+	mutex.Unlock()
+}
+
+// withRunner returns a copy of the ctx that has the given Runner set, any
+// VCS cmds run with the returned ctx use that Runner
+func withRunner(ctx context.Context, runner Runner) context.Context {
+	return context.WithValue(ctx, runnerKey{}, runner)
+}
+
+// localRepoKey is the context key for the local repo path of a VCS op
+type localRepoKey struct{}
+
+// withLocalRepoPath returns a copy of the ctx that has the local repo path of
+// the VCS op set, see RecordingRunner for how it is used
+func withLocalRepoPath(ctx context.Context, localPath string) context.Context {
+	return context.WithValue(ctx, localRepoKey{}, localPath)
+}
+
+// ctxLocalRepoPath returns the local repo path set for the ctx, "" if none
+func ctxLocalRepoPath(ctx context.Context) string {
+	localPath, _ := ctx.Value(localRepoKey{}).(string)
+	return localPath
+}
+
+// ctxRunner returns the Runner to use for the given ctx, the global Runner
+// if the ctx has none set
+func ctxRunner(ctx context.Context) Runner {
+	if runner, ok := ctx.Value(runnerKey{}).(Runner); ok && runner != nil {
+		return runner
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	return defaultRunner
+}
+
+// ExecRunner is the default Runner, it runs VCS cmds via os/exec
+type ExecRunner struct{}
+
+// Run runs the cmd via os/exec (see Runner), if the ctx is canceled or its
+// deadline passes the cmd and any child processes it started are killed
+func (ExecRunner) Run(ctx context.Context, dir string, env []string, cmd string, args ...string) (*Result, error) {
+	command := exec.CommandContext(ctx, cmd, args...)
+	command.Dir = dir
+	if env != nil {
+		command.Env = append(os.Environ(), env...)
+	}
+	if ctx.Done() != nil {
+		// The cmd can be canceled so run it in its own process group, that
+		// way all its children (eg: ssh, git-remote-https) are killed too.
+		// Only done when needed as a background process group can't read
+		// from the terminal (eg: for password prompts).
+		command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		command.Cancel = func() error {
+			return syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+		}
+		command.WaitDelay = killWaitDelay
+	}
+	result := newResult()
+	result.Cmd = fmt.Sprintf("%s %s", cmd, strings.Join(args, " "))
+	result.Args = append([]string{cmd}, args...)
+	result.Dir = dir
+	if result.Dir == "" {
+		result.Dir, _ = os.Getwd()
+	}
+	result.Env = env
+
+	// Keep stdout and stderr apart but also combine them in the order read,
+	// as most callers parse or show the combined output
+	var combined bytes.Buffer
+	var combinedMutex sync.Mutex
+	stdout := &outputWriter{combined: &combined, mutex: &combinedMutex}
+	stderr := &outputWriter{combined: &combined, mutex: &combinedMutex}
+	command.Stdout = stdout
+	command.Stderr = stderr
+
+	result.Start = time.Now()
+	err := command.Run()
+	result.Duration = time.Since(result.Start)
+	result.Output = combined.String()
+	result.Stdout = stdout.own.String()
+	result.Stderr = stderr.own.String()
+	result.ExitCode = -1
+	if command.ProcessState != nil {
+		result.ExitCode = command.ProcessState.ExitCode()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctxErr(ctx, result.Cmd)
+		} else {
+			err = newCmdError(result, err)
+		}
+	}
+	return result, err
+}
+
+// outputWriter collects the output written to one stream of a cmd (stdout
+// or stderr) and also writes it to a buffer combined with the other stream
+type outputWriter struct {
+	own      bytes.Buffer
+	combined *bytes.Buffer
+	mutex    *sync.Mutex // protects combined, shared with the other stream
+}
+
+// Write implements io.Writer for the outputWriter type
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.combined.Write(p)
+	return w.own.Write(p)
+}
+
+// unrunResult returns the result for a cmd that was never run (eg: no
+// recorded result to replay), it has no output and a -1 exit code
+func unrunResult(dir string, env []string, cmd string, args ...string) *Result {
+	result := newResult()
+	result.Cmd = fmt.Sprintf("%s %s", cmd, strings.Join(args, " "))
+	result.Args = append([]string{cmd}, args...)
+	result.Dir = dir
+	result.Env = env
+	result.ExitCode = -1
+	return result
+}
+
+// localRepoMark replaces the local repo path of the VCS op in recorded cmd
+// results, so a recording can be replayed for a repo at another path
+const localRepoMark = "{{LocalRepoPath}}"
+
+// swapPath returns a copy of the result with every occurrence of the path
+// 'from' (in the cmd, argv, dir and output) replaced with 'to'
+func swapPath(result *Result, from, to string) *Result {
+	swapped := *result
+	if from == "" || to == "" || from == to {
+		return &swapped
+	}
+	swapped.Cmd = strings.ReplaceAll(result.Cmd, from, to)
+	swapped.Args = swapArgs(result.Args, from, to)
+	swapped.Dir = strings.ReplaceAll(result.Dir, from, to)
+	swapped.Output = strings.ReplaceAll(result.Output, from, to)
+	swapped.Stdout = strings.ReplaceAll(result.Stdout, from, to)
+	swapped.Stderr = strings.ReplaceAll(result.Stderr, from, to)
+	return &swapped
+}
+
+// swapArgs returns a copy of the args with every occurrence of the path
+// 'from' replaced with 'to'
+func swapArgs(args []string, from, to string) []string {
+	swapped := make([]string, len(args))
+	for i, arg := range args {
+		if from != "" && to != "" {
+			arg = strings.ReplaceAll(arg, from, to)
+		}
+		swapped[i] = arg
+	}
+	return swapped
+}
+
+// runRecord is a single recorded VCS cmd run, a recording file has one of
+// these per line (as JSON)
+type runRecord struct {
+	Result *Result `json:"result"`
+	Err    string  `json:"err,omitempty"`
+}
+
+// RecordingRunner is a Runner that runs cmds with another Runner and records
+// each cmd run (argv, dir, output, exit code, etc and the error text if the
+// cmd failed) to a file, one JSON record per line.  The local repo path of
+// the VCS op is recorded as a placeholder (wherever it shows up in the argv,
+// dir or output) so the recording isn't tied to where the repo was.  A
+// recording can be served back with a ReplayRunner, eg: to test code built on
+// this package with no VCS tools installed.  It is goroutine safe.
+type RecordingRunner struct {
+	runner  Runner
+	file    *os.File
+	encoder *json.Encoder
+	mutex   sync.Mutex
+}
+
+// NewRecordingRunner creates (or truncates) the recording file at the given
+// path and returns a RecordingRunner that runs cmds with the given runner (if
+// nil ExecRunner is used) and records them to that file, use Close() when done
+func NewRecordingRunner(path string, runner Runner) (*RecordingRunner, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, out.WrapErrf(err, 4531, "Unable to create VCS cmd recording file: %s", path)
+	}
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	return &RecordingRunner{runner: runner, file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Run runs the cmd with the underlying Runner and records it (see Runner),
+// if the cmd works but recording it fails the recording error is returned
+func (r *RecordingRunner) Run(ctx context.Context, dir string, env []string, cmd string, args ...string) (*Result, error) {
+	result, err := r.runner.Run(ctx, dir, env, cmd, args...)
+	localPath := ctxLocalRepoPath(ctx)
+	record := &runRecord{Result: swapPath(result, localPath, localRepoMark)}
+	if err != nil {
+		record.Err = err.Error()
+		if localPath != "" {
+			record.Err = strings.ReplaceAll(record.Err, localPath, localRepoMark)
+		}
+	}
+	r.mutex.Lock()
+	recordErr := r.encoder.Encode(record)
+	r.mutex.Unlock()
+	if recordErr != nil && err == nil {
+		err = out.WrapErrf(recordErr, 4532, "Unable to record VCS cmd to file: %s, cmd: %s", r.file.Name(), result.Cmd)
+	}
+	return result, err
+}
+
+// Close closes the recording file
+func (r *RecordingRunner) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.file.Close()
+}
+
+// ReplayRunner is a Runner that runs no cmds, instead it serves back the
+// results recorded by a RecordingRunner.  Each recorded result is served
+// once, to the first cmd run with the same argv (the dir and env aren't
+// compared), so repeated cmds get their results in the order recorded.  The
+// argv is compared with the local repo path of the VCS op swapped for the
+// recorded placeholder, which is swapped back in the result served.  A
+// recorded failure is returned as a *CmdError with the recorded error text
+// (classified from the recorded output).  It is goroutine safe.
+type ReplayRunner struct {
+	records []*runRecord
+	used    []bool
+	mutex   sync.Mutex
+}
+
+// NewReplayRunner reads the recording file at the given path (see
+// RecordingRunner) and returns a ReplayRunner that serves it back
+func NewReplayRunner(path string) (*ReplayRunner, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, out.WrapErrf(err, 4533, "Unable to open VCS cmd recording file: %s", path)
+	}
+	defer file.Close()
+	r := &ReplayRunner{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		record := &runRecord{}
+		if err = json.Unmarshal(scanner.Bytes(), record); err != nil || record.Result == nil {
+			return nil, out.NewErrf(4534, "Unable to parse VCS cmd recording file: %s, line %d, err: %v", path, line, err)
+		}
+		r.records = append(r.records, record)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, out.WrapErrf(err, 4533, "Unable to read VCS cmd recording file: %s", path)
+	}
+	r.used = make([]bool, len(r.records))
+	return r, nil
+}
+
+// Run serves back the next recorded result for the cmd (see Runner), if
+// there is none left a wrapped ErrNoReplay is returned
+func (r *ReplayRunner) Run(ctx context.Context, dir string, env []string, cmd string, args ...string) (*Result, error) {
+	localPath := ctxLocalRepoPath(ctx)
+	argv := swapArgs(append([]string{cmd}, args...), localPath, localRepoMark)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i, record := range r.records {
+		if r.used[i] || !reflect.DeepEqual(record.Result.Args, argv) {
+			continue
+		}
+		r.used[i] = true
+		result := swapPath(record.Result, localRepoMark, localPath)
+		if record.Err != "" {
+			errText := record.Err
+			if localPath != "" {
+				errText = strings.ReplaceAll(errText, localRepoMark, localPath)
+			}
+			return result, newCmdError(result, errors.New(errText))
+		}
+		return result, nil
+	}
+	result := unrunResult(dir, env, cmd, args...)
+	return result, out.WrapErrf(ErrNoReplay, 4535, "No recorded result to replay for VCS cmd: %s", result.Cmd)
+}
+
+// Remaining returns the number of recorded results not yet served back
+func (r *ReplayRunner) Remaining() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	remaining := 0
+	for _, used := range r.used {
+		if !used {
+			remaining++
+		}
+	}
+	return remaining
+}
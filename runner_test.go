@@ -0,0 +1,189 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dvln/out"
+)
+
+// TestRecordReplay verifies cmds recorded by a RecordingRunner are served
+// back by a ReplayRunner, including failed cmds
+func TestRecordReplay(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-runner-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	recording := filepath.Join(tempDir, "cmds.json")
+	if result, err := run(context.Background(), "git", "init", tempDir); err != nil {
+		t.Fatalf("Unable to create git repo, err: %s, result:\n%s", err, result)
+	}
+
+	recorder, err := NewRecordingRunner(recording, nil)
+	if err != nil {
+		t.Fatalf("Unable to create recording runner, err: %s", err)
+	}
+	d := &Description{}
+	d.SetRunner(recorder)
+	if d.Runner() != recorder {
+		t.Errorf("Describer runner not set, found: %v", d.Runner())
+	}
+	if _, err = run(d.Context(), "sh", "-c", "echo first"); err != nil {
+		t.Errorf("Failed to run and record cmd, err: %s", err)
+	}
+	if _, err = run(d.Context(), "sh", "-c", "echo second"); err != nil {
+		t.Errorf("Failed to run and record cmd, err: %s", err)
+	}
+	_, err = run(d.Context(), "git", "-C", tempDir, "rev-parse", "nosuchrev")
+	if !errors.Is(err, ErrUnknownRev) {
+		t.Errorf("Recorded failing git cmd not classified as unknown rev, err: %v", err)
+	}
+	if err = recorder.Close(); err != nil {
+		t.Fatalf("Unable to close recording, err: %s", err)
+	}
+
+	replayer, err := NewReplayRunner(recording)
+	if err != nil {
+		t.Fatalf("Unable to read recording, err: %s", err)
+	}
+	if replayer.Remaining() != 3 {
+		t.Errorf("Expected 3 recorded cmds, found: %d", replayer.Remaining())
+	}
+	d.SetRunner(replayer)
+
+	// results are served back by argv, not in the order recorded
+	result, err := run(d.Context(), "sh", "-c", "echo second")
+	if err != nil || result.Output != "second\n" || result.ExitCode != 0 {
+		t.Errorf("Incorrect replayed cmd result, err: %v, result:\n%s", err, result)
+	}
+	result, err = run(d.Context(), "git", "-C", tempDir, "rev-parse", "nosuchrev")
+	var cmdErr *CmdError
+	if !errors.As(err, &cmdErr) || !errors.Is(err, ErrUnknownRev) || cmdErr.Result != result || result.ExitCode == 0 {
+		t.Errorf("Replayed failing git cmd did not return a classified *CmdError, err: %v", err)
+	}
+	if result, err = run(d.Context(), "sh", "-c", "echo first"); err != nil || result.Output != "first\n" {
+		t.Errorf("Incorrect replayed cmd result, err: %v, result:\n%s", err, result)
+	}
+
+	// each recorded result is only served once
+	_, err = run(d.Context(), "sh", "-c", "echo first")
+	if !out.IsError(err, ErrNoReplay) {
+		t.Errorf("Expected no replay error for a cmd already served, found: %v", err)
+	}
+	if replayer.Remaining() != 0 {
+		t.Errorf("Expected all recorded cmds to be served, remaining: %d", replayer.Remaining())
+	}
+}
+
+// TestReplayOps verifies a Getter and Reader can be driven purely from a
+// recording, with no VCS tools and with the repo at another path
+func TestReplayOps(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-runner-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	recording := filepath.Join(tempDir, "cmds.json")
+	recorder, err := NewRecordingRunner(recording, nil)
+	if err != nil {
+		t.Fatalf("Unable to create recording runner, err: %s", err)
+	}
+	recorded := getAndRead(t, fixture, filepath.Join(tempDir, "VCSTestRepo"), recorder)
+	if err = recorder.Close(); err != nil {
+		t.Fatalf("Unable to close recording, err: %s", err)
+	}
+
+	replayer, err := NewReplayRunner(recording)
+	if err != nil {
+		t.Fatalf("Unable to read recording, err: %s", err)
+	}
+	t.Setenv("PATH", "") // no VCS tools
+	replayed := getAndRead(t, fixture, filepath.Join(tempDir, "elsewhere", "Replayed"), replayer)
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("Incorrect replayed revisions, expected: %+v, found: %+v", recorded, replayed)
+	}
+	if replayer.Remaining() != 0 {
+		t.Errorf("Expected all recorded cmds to be served, remaining: %d", replayer.Remaining())
+	}
+}
+
+// getAndRead gets the fixture repo at the local path and reads a revision
+// from it, running all VCS cmds (including the remote checks) with the runner
+func getAndRead(t *testing.T, fixture *fixture, localPath string, runner Runner) []Revisioner {
+	getter, err := NewGetter(fixture.remote, "", localPath, false, Git)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new getter, err: %s", err)
+	}
+	getter.SetRunner(runner)
+	if results, err := getter.Get(); err != nil {
+		t.Fatalf("Unable to get repo, err: %s, results:\n%s", err, results)
+	}
+	// a replayed get writes nothing, make the path look like a clone
+	if err = os.MkdirAll(filepath.Join(localPath, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the reader checks the remote against the local repo when created, so
+	// the global Runner is set for that, then rechecked with the op Runner
+	SetRunner(runner)
+	reader, err := NewReader(fixture.remote, localPath, Git)
+	SetRunner(nil)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new reader, err: %s", err)
+	}
+	reader.SetRunner(runner)
+	if results, err := reader.Check(); err != nil {
+		t.Fatalf("Unable to check the remote, err: %s, results:\n%s", err, results)
+	}
+	revs, results, err := reader.RevRead(AllData, fixture.revs["second"])
+	if err != nil || len(revs) != 1 || revs[0].Core() != fixture.revs["second"] {
+		t.Fatalf("Unable to read revision, err: %v, revs: %+v, results:\n%s", err, revs, results)
+	}
+	return revs
+}
+
+// TestSetRunner verifies the global Runner is used unless the Describer
+// has its own Runner set
+func TestSetRunner(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-runner-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	recording := filepath.Join(tempDir, "empty.json")
+	if err = ioutil.WriteFile(recording, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := NewReplayRunner(recording)
+	if err != nil {
+		t.Fatalf("Unable to read recording, err: %s", err)
+	}
+
+	SetRunner(replayer)
+	defer SetRunner(nil)
+	d := &Description{}
+	if _, err = run(d.Context(), "true"); !out.IsError(err, ErrNoReplay) {
+		t.Errorf("Global runner not used to run cmd, err: %v", err)
+	}
+	d.SetRunner(ExecRunner{})
+	if _, err = run(d.Context(), "true"); err != nil {
+		t.Errorf("Describer runner not used to run cmd, err: %v", err)
+	}
+	SetRunner(nil)
+	if _, err = run(context.Background(), "true"); err != nil {
+		t.Errorf("Default runner not restored, err: %v", err)
+	}
+
+	if err = ioutil.WriteFile(recording, []byte("not json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewReplayRunner(recording); err == nil {
+		t.Errorf("Expected an error reading a corrupt recording")
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dvln/out"
+)
+
+// semVerRegex matches a semantic version (see semver.org) with an optional
+// "v" in front, eg: "1.2.3", "v1.2.3-beta.1+build.7".  A tag is a semantic
+// version compatible tag if it is the semver prefix of the Describer (see
+// SetSemVerPrefix) followed by a version matching this.
+var semVerRegex = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(?:-([0-9A-Za-z.\-]+))?(?:\+([0-9A-Za-z.\-]+))?$`)
+
+// SemVer is a parsed semantic version, eg: "1.2.3-beta.1+build.7"
+type SemVer struct {
+	Major, Minor, Patch int
+
+	// Pre has the pre-release identifiers, eg: "beta.1" ("" for a release)
+	Pre string
+
+	// Build has the build metadata, eg: "build.7" (ignored for precedence)
+	Build string
+}
+
+// ParseSemVer parses a semantic version string, a leading "v" is allowed
+func ParseSemVer(version string) (*SemVer, error) {
+	m := semVerRegex.FindStringSubmatch(version)
+	if m == nil {
+		return nil, out.NewErrf(4547, "Invalid semantic version: \"%s\"", version)
+	}
+	v := &SemVer{Pre: m[4], Build: m[5]}
+	var err error
+	for i, num := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if *num, err = strconv.Atoi(m[i+1]); err != nil {
+			return nil, out.WrapErrf(err, 4547, "Invalid semantic version: \"%s\"", version)
+		}
+	}
+	return v, nil
+}
+
+// String returns the semantic version string (without any "v" or prefix)
+func (v *SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if the version has lower, the same or higher
+// precedence than the given version, eg: 1.0.0-alpha < 1.0.0-alpha.1 <
+// 1.0.0-beta < 1.0.0 < 1.0.1 (the build metadata is ignored)
+func (v *SemVer) Compare(o *SemVer) int {
+	for _, diff := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if diff != 0 {
+			return sign(diff)
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "": // a release is higher than its pre-releases
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	ids, oids := strings.Split(v.Pre, "."), strings.Split(o.Pre, ".")
+	for i := 0; i < len(ids) && i < len(oids); i++ {
+		num, err := strconv.Atoi(ids[i])
+		onum, oerr := strconv.Atoi(oids[i])
+		switch {
+		case err == nil && oerr == nil:
+			if num != onum {
+				return sign(num - onum)
+			}
+		case err == nil: // numeric identifiers are lower than alphanumeric
+			return -1
+		case oerr == nil:
+			return 1
+		case ids[i] != oids[i]:
+			return sign(strings.Compare(ids[i], oids[i]))
+		}
+	}
+	return sign(len(ids) - len(oids))
+}
+
+// sign returns -1, 0 or 1 for a negative, zero or positive number
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// semVerTag returns the semantic version of the tag if it is the given
+// prefix followed by a semantic version, eg: "pkg/v1.2.3" for "pkg/"
+func semVerTag(tag Rev, prefix string) (*SemVer, bool) {
+	if !strings.HasPrefix(string(tag), prefix) {
+		return nil, false
+	}
+	v, err := ParseSemVer(strings.TrimPrefix(string(tag), prefix))
+	return v, err == nil
+}
+
+// semVerComparator is a single constraint comparison, eg: ">=1.2.0"
+type semVerComparator struct {
+	op string // "=", ">", ">=", "<" or "<="
+	v  SemVer
+}
+
+// check returns true if the version satisfies the comparison
+func (c semVerComparator) check(v *SemVer) bool {
+	cmp := v.Compare(&c.v)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return cmp == 0
+}
+
+// SemVerConstraint is a parsed semantic version constraint (see
+// ParseSemVerConstraint)
+type SemVerConstraint struct {
+	groups [][]semVerComparator // any group matches if all of its comparisons do
+}
+
+// ParseSemVerConstraint parses a semantic version constraint, the syntax is
+// that of npm and most other semver tools: comparisons separated by spaces
+// must all match, eg: ">=1.2 <2", and "||" separates alternatives, eg:
+// "1.x || >=3.1".  The comparisons are:
+//	"1.2.3" or "=1.2.3": exactly 1.2.3, "1.2" or "1.2.x" is >=1.2.0 <1.3.0
+//	">1.2.3", ">=1.2", "<2", "<=1.2": partial versions are zero filled for
+//	    >= and <, otherwise the whole partial range is included (>1.2 is >=1.3.0)
+//	"^1.4": compatible, same major version (>=1.4.0 <2.0.0), for 0.x the same
+//	    minor (^0.2.3 is >=0.2.3 <0.3.0) and for 0.0.x the same patch
+//	"~2.0.3": same minor version (>=2.0.3 <2.1.0), ~2 is >=2.0.0 <3.0.0
+//	"*" or "": any version
+// Pre-release versions only match a constraint with a pre-release of the
+// same major.minor.patch version, eg: "^1.4.0-beta" matches 1.4.0-rc.1 but
+// not 1.5.0-beta.  A "v" in front of a version is allowed.
+func ParseSemVerConstraint(constraint string) (*SemVerConstraint, error) {
+	c := &SemVerConstraint{}
+	for _, alternative := range strings.Split(constraint, "||") {
+		group := []semVerComparator{}
+		fields := strings.Fields(alternative)
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			op := field[:len(field)-len(strings.TrimLeft(field, "^~<>="))]
+			if op == field && i+1 < len(fields) { // eg: ">= 1.2"
+				i++
+				field += fields[i]
+			}
+			comparators, err := semVerComparators(field[:len(op)], field[len(op):])
+			if err != nil {
+				return nil, out.WrapErrf(err, 4548, "Invalid semantic version constraint: \"%s\"", constraint)
+			}
+			group = append(group, comparators...)
+		}
+		c.groups = append(c.groups, group)
+	}
+	return c, nil
+}
+
+// semVerComparators returns the comparisons for a single constraint op and
+// (possibly partial) version, eg: "^" and "1.4" give ">=1.4.0" and "<2.0.0"
+func semVerComparators(op, version string) ([]semVerComparator, error) {
+	v, parts, err := parsePartialSemVer(version)
+	if err != nil {
+		return nil, err
+	}
+	next := func(part int) SemVer { // lowest version past the partial range
+		switch part {
+		case 1:
+			return SemVer{Major: v.Major + 1}
+		case 2:
+			return SemVer{Major: v.Major, Minor: v.Minor + 1}
+		}
+		return SemVer{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+	none := []semVerComparator{{"<", SemVer{Pre: "0"}}} // nothing matches
+	switch op {
+	case "^":
+		if parts == 0 {
+			return nil, nil
+		}
+		upper := 3
+		if v.Major > 0 || parts == 1 {
+			upper = 1
+		} else if v.Minor > 0 || parts == 2 {
+			upper = 2
+		}
+		return []semVerComparator{{">=", v}, {"<", next(upper)}}, nil
+	case "~":
+		if parts == 0 {
+			return nil, nil
+		}
+		upper := 2
+		if parts == 1 {
+			upper = 1
+		}
+		return []semVerComparator{{">=", v}, {"<", next(upper)}}, nil
+	case "", "=":
+		switch parts {
+		case 0:
+			return nil, nil
+		case 3:
+			return []semVerComparator{{"=", v}}, nil
+		}
+		return []semVerComparator{{">=", v}, {"<", next(parts)}}, nil
+	case ">":
+		switch parts {
+		case 0:
+			return none, nil
+		case 3:
+			return []semVerComparator{{">", v}}, nil
+		}
+		return []semVerComparator{{">=", next(parts)}}, nil
+	case ">=":
+		if parts == 0 {
+			return nil, nil
+		}
+		return []semVerComparator{{">=", v}}, nil
+	case "<":
+		if parts == 0 {
+			return none, nil
+		}
+		return []semVerComparator{{"<", v}}, nil
+	case "<=":
+		switch parts {
+		case 0:
+			return nil, nil
+		case 3:
+			return []semVerComparator{{"<=", v}}, nil
+		}
+		return []semVerComparator{{"<", next(parts)}}, nil
+	}
+	return nil, out.NewErrf(4548, "Invalid semantic version constraint operator: \"%s\"", op)
+}
+
+// parsePartialSemVer parses a possibly partial version, eg: "1", "1.2",
+// "1.x" or "*", returning the zero filled version and the number of
+// version parts given (0-3), a pre-release needs all three parts
+func parsePartialSemVer(version string) (SemVer, int, error) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i] // build metadata doesn't count
+	}
+	if v, err := ParseSemVer(version); err == nil {
+		return *v, 3, nil
+	}
+	v := SemVer{}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	parts := 0
+	if version != "" {
+		for _, part := range strings.Split(version, ".") {
+			if part == "x" || part == "X" || part == "*" {
+				break
+			}
+			num, err := strconv.Atoi(part)
+			if parts == len(nums) || err != nil || num < 0 || (len(part) > 1 && part[0] == '0') {
+				return v, 0, out.NewErrf(4548, "Invalid partial semantic version: \"%s\"", version)
+			}
+			*nums[parts] = num
+			parts++
+		}
+	}
+	return v, parts, nil
+}
+
+// Check returns true if the version satisfies the constraint
+func (c *SemVerConstraint) Check(v *SemVer) bool {
+	for _, group := range c.groups {
+		match := true
+		preAllowed := v.Pre == ""
+		for _, comparator := range group {
+			if !comparator.check(v) {
+				match = false
+				break
+			}
+			if cv := comparator.v; cv.Pre != "" && cv.Major == v.Major && cv.Minor == v.Minor && cv.Patch == v.Patch {
+				preAllowed = true
+			}
+		}
+		if match && preAllowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSemVer finds the highest semantic version tag matching the given
+// constraint (see ParseSemVerConstraint) among the tags of the local repo
+// (LocalPath) or remote (Remote), only tags with the semver prefix of the
+// ref lister are considered (see SetSemVerPrefix).  The matching tag ref is
+// returned, its Rev is the revision to use.  If no tag matches a wrapped
+// ErrUnknownRev error is returned (use out.IsError() to check).  Params:
+//	l (RefLister): lists the repo tags (eg: from NewRefLister)
+//	loc (Location): LocalPath for the local repo tags, Remote for the remote
+//	constraint (string): the semantic version constraint, eg: "^1.4"
+// Returns the tag ref, results (vcs cmds run, output) and any error
+func ResolveSemVer(l RefLister, loc Location, constraint string) (*Ref, Resulter, error) {
+	results := newResults()
+	c, err := ParseSemVerConstraint(constraint)
+	if err != nil {
+		return nil, results, err
+	}
+	refs, refResults, err := l.Refs(loc)
+	if refResults != nil {
+		for _, result := range refResults.All() {
+			results.add(result)
+		}
+	}
+	if err != nil {
+		return nil, results, err
+	}
+	var best *Ref
+	var bestVersion *SemVer
+	for _, ref := range refs {
+		if ref.Type != RefTag {
+			continue
+		}
+		v, ok := semVerTag(Rev(ref.Name), l.SemVerPrefix())
+		if ok && c.Check(v) && (bestVersion == nil || v.Compare(bestVersion) > 0) {
+			best, bestVersion = ref, v
+		}
+	}
+	if best == nil {
+		return nil, results, out.WrapErrf(ErrUnknownRev, 4549, "No %s tag matches semantic version constraint \"%s\"", l.Vcs(), constraint)
+	}
+	return best, results, nil
+}
@@ -0,0 +1,162 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dvln/out"
+)
+
+// TestSemVer verifies semantic versions parse and order right
+func TestSemVer(t *testing.T) {
+	ordered := []string{"0.9.0", "1.0.0-0.3.7", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta",
+		"1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "v1.0.0+build.5", "1.0.1", "1.10.0", "2.0.0"}
+	var versions []*SemVer
+	for _, version := range ordered {
+		v, err := ParseSemVer(version)
+		if err != nil {
+			t.Fatalf("Unable to parse semantic version %s, err: %s", version, err)
+		}
+		versions = append(versions, v)
+	}
+	for i := range versions {
+		for j := range versions {
+			expected := sign(i - j)
+			if cmp := versions[i].Compare(versions[j]); cmp != expected {
+				t.Errorf("Incorrect semver compare of %s and %s, expected: %d, found: %d", versions[i], versions[j], expected, cmp)
+			}
+		}
+	}
+	if v := versions[9]; v.Major != 1 || v.Minor != 0 || v.Patch != 0 || v.Build != "build.5" || v.String() != "1.0.0+build.5" {
+		t.Errorf("Incorrect semver parse of v1.0.0+build.5, found: %+v", *v)
+	}
+	for _, bad := range []string{"1.2", "01.2.3", "1.2.3-", "1.2.3.4", "x1.2.3", "pkg/v1.2.3"} {
+		if _, err := ParseSemVer(bad); !out.IsError(err, nil, 4547) {
+			t.Errorf("Expected an error parsing semantic version %s, err: %v", bad, err)
+		}
+	}
+	semVers, tags := splitSemVers("pkg/", []Rev{"pkg/v1.2.3", "v1.2.3", "pkg/1.0.0", "pkg/latest"})
+	if !reflect.DeepEqual(semVers, []Rev{"pkg/v1.2.3", "pkg/1.0.0"}) || !reflect.DeepEqual(tags, []Rev{"v1.2.3", "pkg/latest"}) {
+		t.Errorf("Incorrect semver split by prefix, semvers: %v, tags: %v", semVers, tags)
+	}
+}
+
+// TestSemVerConstraint verifies semantic version constraint matching
+func TestSemVerConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"^1.4", []string{"1.4.0", "1.4.7", "1.9.0"}, []string{"1.3.9", "2.0.0", "1.5.0-beta"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.2.2", "0.3.0", "1.0.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4", "0.1.0"}},
+		{"^0", []string{"0.0.1", "0.9.9"}, []string{"1.0.0"}},
+		{"~2.0.3", []string{"2.0.3", "2.0.9"}, []string{"2.0.2", "2.1.0"}},
+		{"~2", []string{"2.0.0", "2.9.0"}, []string{"1.9.9", "3.0.0"}},
+		{">=1.2 <2", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0", "2.0.0-rc.1"}},
+		{">= 1.2.3", []string{"1.2.3", "3.0.0"}, []string{"1.2.2"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{"<=1.2", []string{"1.2.9", "0.1.0"}, []string{"1.3.0"}},
+		{"1.2", []string{"1.2.0", "1.2.5"}, []string{"1.3.0", "1.1.0"}},
+		{"=v1.2.3", []string{"1.2.3", "1.2.3+build"}, []string{"1.2.4"}},
+		{"1.x || >=3.1", []string{"1.0.0", "1.9.0", "3.1.0"}, []string{"2.0.0", "3.0.0"}},
+		{"*", []string{"0.0.1", "9.9.9"}, []string{"1.0.0-beta"}},
+		{"^1.4.0-beta", []string{"1.4.0-beta", "1.4.0-rc.1", "1.4.0", "1.5.0"}, []string{"1.4.0-alpha", "1.5.0-beta"}},
+	}
+	for _, test := range tests {
+		c, err := ParseSemVerConstraint(test.constraint)
+		if err != nil {
+			t.Errorf("Unable to parse semver constraint %q, err: %s", test.constraint, err)
+			continue
+		}
+		for _, version := range append(test.match, test.noMatch...) {
+			v, err := ParseSemVer(version)
+			if err != nil {
+				t.Fatalf("Unable to parse semantic version %s, err: %s", version, err)
+			}
+			if expected := contains(test.match, version); c.Check(v) != expected {
+				t.Errorf("Incorrect semver constraint %q check of %s, expected: %t", test.constraint, version, expected)
+			}
+		}
+	}
+	for _, bad := range []string{"^1.2.3.4", "=>1.2", "~1.02", "1.2-beta"} {
+		if _, err := ParseSemVerConstraint(bad); !out.IsError(err, nil, 4548) {
+			t.Errorf("Expected an error parsing semver constraint %q, err: %v", bad, err)
+		}
+	}
+}
+
+// contains returns true if the list has the given string
+func contains(list []string, s string) bool {
+	for _, entry := range list {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TestResolveSemVer verifies semver constraints resolve to the highest
+// matching tag and that the semver prefix applies to revision reads
+func TestResolveSemVer(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	for tag, rev := range map[string]string{"v1.2.0": "first", "v1.4.1": "second", "1.4.2": "third",
+		"v1.5.0-beta": "branch", "v2.0.0": "merge", "pkg/v1.9.0": "merge"} {
+		fixture.run(fixture.path, nil, gitTool, "tag", tag, string(fixture.revs[rev]))
+	}
+	localPath := filepath.Join(tempDir, "VCSTestRepo")
+	fixture.run(tempDir, nil, gitTool, "clone", "-q", fixture.remote, localPath)
+	lister, err := NewRefLister(fixture.remote, localPath, Git)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git ref lister, err: %s", err)
+	}
+	for _, test := range []struct {
+		prefix, constraint, tag, rev string
+	}{
+		{"", "^1.4", "1.4.2", "third"},
+		{"", "~1.4.1", "1.4.2", "third"},
+		{"", ">=1.2 <1.4", "v1.2.0", "first"},
+		{"", "^1.5.0-alpha", "v1.5.0-beta", "branch"},
+		{"", "*", "v2.0.0", "merge"},
+		{"pkg/", "^1", "pkg/v1.9.0", "merge"},
+	} {
+		lister.SetSemVerPrefix(test.prefix)
+		ref, results, err := ResolveSemVer(lister, Remote, test.constraint)
+		if err != nil {
+			t.Errorf("Unable to resolve semver constraint %q, err: %s, results:\n%s", test.constraint, err, results)
+			continue
+		}
+		if ref.Name != test.tag || ref.Rev != fixture.revs[test.rev] {
+			t.Errorf("Incorrect tag for semver constraint %q, expected: %s, found: %+v", test.constraint, test.tag, *ref)
+		}
+	}
+	lister.SetSemVerPrefix("")
+	if _, _, err = ResolveSemVer(lister, LocalPath, "^3"); !out.IsError(err, ErrUnknownRev) {
+		t.Errorf("Expected an unknown rev error resolving an unmatched semver constraint, err: %v", err)
+	}
+
+	// the semver prefix decides which tags are semvers in revision reads
+	reader, err := NewReader(fixture.remote, localPath, Git)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git reader, err: %s", err)
+	}
+	for prefix, expected := range map[string][]Rev{"": {"v2.0.0"}, "pkg/": {"pkg/v1.9.0"}} {
+		reader.SetSemVerPrefix(prefix)
+		revs, results, err := reader.RevRead(AllData, fixture.revs["merge"])
+		if err != nil || len(revs) != 1 {
+			t.Fatalf("Unable to read git revision, err: %v, results:\n%s", err, results)
+		}
+		if !reflect.DeepEqual(revs[0].SemVers(), expected) {
+			t.Errorf("Incorrect git semvers for prefix %q, expected: %v, found: %v (tags: %v)", prefix, expected, revs[0].SemVers(), revs[0].Tags())
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+// SparseMgr reads and changes the sparse checkout set of a local clone, ie:
+// the directories checked out in the working tree (git cone mode sparse
+// checkout, hg sparse extension).  A sparse clone can be made up front by
+// giving the sparse paths to NewGitGetter() or NewHgGetter(), after which
+// updates and rev sets only touch those paths.
+type SparseMgr interface {
+	// Describer access to VCS system details (Remote, LocalRepoPath, ..)
+	Describer
+
+	// Exists will determine if the repo exists (remotely or in local dir)
+	Exists(Location) (string, Resulter, error)
+
+	// Sparse returns the sparse paths checked out, none if the clone has
+	// a full checkout
+	Sparse() ([]string, Resulter, error)
+
+	// SetSparse replaces the sparse paths and updates the working tree to
+	// match, no paths turns sparse checkout off (ie: a full checkout)
+	SetSparse(...string) (Resulter, error)
+}
+
+// NewSparseMgr returns a VCS SparseMgr interface to read or change the
+// sparse checkout set of a clone.  It only works with local VCS's so doesn't
+// accept remotes.  Only git and hg are supported (ErrNotImplemented is
+// returned for others), an ErrCannotDetectVCS is returned if the VCS type
+// cannot be detected or ErrNoExist if the repo isn't there.
+func NewSparseMgr(localPath string, vcsType ...Type) (SparseMgr, error) {
+	vtype := NoVCS
+	if vcsType != nil && len(vcsType) == 1 && vcsType[0] != NoVCS {
+		vtype = vcsType[0]
+	} else {
+		var err error
+		vtype, err = DetectVcsFromFS(localPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch vtype {
+	case Git:
+		return NewGitSparseMgr(localPath)
+	case Hg:
+		return NewHgSparseMgr(localPath)
+	case Svn:
+		return nil, ErrNotImplemented
+	case Bzr:
+		return nil, ErrNotImplemented
+	}
+	// Should never fall through to here but just in case.
+	return nil, ErrCannotDetectVCS
+}
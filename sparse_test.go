@@ -0,0 +1,157 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dvln/out"
+)
+
+// TestSparse verifies sparse clones, that updates honor the sparse set and
+// that the sparse set of a clone can be read and changed (for each VCS type
+// that supports it, skipping those with no tools installed)
+func TestSparse(t *testing.T) {
+	for _, vcsType := range []Type{Git, Hg} {
+		vcsType := vcsType
+		t.Run(string(vcsType), func(t *testing.T) {
+			if !haveFixtureTools(vcsType) {
+				t.Skipf("skipping %s sparse tests, %s tools not found", vcsType, vcsType)
+			}
+			tempDir, err := ioutil.TempDir("", "go-vcs-sparse-tests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+
+			// a repo with a couple of dirs to pick from, the clone only
+			// checks out the "a" dir
+			f := &fixture{vcs: vcsType, revs: make(map[string]Rev), t: t}
+			src := filepath.Join(tempDir, "src")
+			f.run(tempDir, nil, string(vcsType), "init", src)
+			commit := func(files ...string) {
+				for _, file := range files {
+					if err := os.MkdirAll(filepath.Join(src, filepath.Dir(file)), 0755); err != nil {
+						t.Fatal(err)
+					}
+					f.write(src, file, file)
+				}
+				if vcsType == Git {
+					f.run(src, nil, gitTool, "add", "-A")
+					f.run(src, nil, gitTool, "commit", "-q", "-m", "add files")
+				} else {
+					f.run(src, nil, hgTool, "commit", "-A", "-m", "add files")
+				}
+			}
+			commit("README", "a/one", "b/one")
+			localPath := filepath.Join(tempDir, "VCSTestRepo")
+			var getter Getter
+			if vcsType == Git {
+				getter, err = NewGitGetter(src, "", localPath, false, "a")
+			} else {
+				getter, err = NewHgGetter(src, "", localPath, false, "a")
+			}
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS getter, err: %s", vcsType, err)
+			}
+			if results, err := getter.Get(); err != nil {
+				t.Fatalf("Unable to get sparse %s repo, err: %s, results:\n%s", vcsType, err, results)
+			}
+			updater, err := NewUpdater(src, "", localPath, false, RebaseFalse, nil, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS updater, err: %s", vcsType, err)
+			}
+			update := func() {
+				if results, err := updater.Update(); err != nil {
+					t.Fatalf("Unable to update sparse %s repo, err: %s, results:\n%s", vcsType, err, results)
+				}
+			}
+			update()
+			checkSparseFiles(t, vcsType, localPath, []string{"README", "a/one"}, []string{"b/one"})
+
+			// updates only bring in files in the sparse set
+			commit("a/two", "b/two")
+			update()
+			checkSparseFiles(t, vcsType, localPath, []string{"a/two"}, []string{"b/two"})
+
+			// read and change the sparse set
+			sparseMgr, err := NewSparseMgr(localPath)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS sparse manager, err: %s", vcsType, err)
+			}
+			checkSparse(t, sparseMgr, []string{"a"})
+			if results, err := sparseMgr.SetSparse("b"); err != nil {
+				t.Fatalf("Unable to set %s sparse paths, err: %s, results:\n%s", vcsType, err, results)
+			}
+			checkSparse(t, sparseMgr, []string{"b"})
+			checkSparseFiles(t, vcsType, localPath, []string{"b/one", "b/two"}, []string{"a/one", "a/two"})
+			if results, err := sparseMgr.SetSparse(); err != nil {
+				t.Fatalf("Unable to turn off %s sparse checkout, err: %s, results:\n%s", vcsType, err, results)
+			}
+			checkSparse(t, sparseMgr, nil)
+			checkSparseFiles(t, vcsType, localPath, []string{"a/one", "a/two", "b/one", "b/two"}, nil)
+		})
+	}
+}
+
+// checkSparse verifies the sparse paths read back from a clone
+func checkSparse(t *testing.T, s SparseMgr, expected []string) {
+	paths, results, err := s.Sparse()
+	if err != nil {
+		t.Fatalf("Unable to read %s sparse paths, err: %s, results:\n%s", s.Vcs(), err, results)
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Incorrect %s sparse paths, expected: %v, found: %v", s.Vcs(), expected, paths)
+	}
+}
+
+// checkSparseFiles verifies which files a sparse clone has checked out
+func checkSparseFiles(t *testing.T, vcsType Type, localPath string, have, missing []string) {
+	for _, file := range have {
+		if _, err := os.Stat(filepath.Join(localPath, file)); err != nil {
+			t.Errorf("Expected %s sparse clone to have checked out %s, err: %s", vcsType, file, err)
+		}
+	}
+	for _, file := range missing {
+		if _, err := os.Stat(filepath.Join(localPath, file)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s sparse clone to not have checked out %s, err: %v", vcsType, file, err)
+		}
+	}
+}
+
+// TestSparseMirror verifies a git or hg sparse checkout can't be asked of a
+// mirror clone and that svn and bzr have no sparse support
+func TestSparseMirror(t *testing.T) {
+	getter, err := NewGitGetter("https://github.com/dvln/git-test-repo", "", filepath.Join(os.TempDir(), "go-vcs-no-such-repo"), true, "a")
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git VCS getter, err: %s", err)
+	}
+	if _, err = getter.Get(); !out.IsError(err, nil, 4550) {
+		t.Errorf("Expected an error getting a sparse mirror clone, err: %v", err)
+	}
+	getter, err = NewHgGetter("https://bitbucket.org/dvln/hg-test-repo", "", filepath.Join(os.TempDir(), "go-vcs-no-such-repo"), true, "a")
+	if err != nil {
+		t.Fatalf("Unable to instantiate new hg VCS getter, err: %s", err)
+	}
+	if _, err = getter.Get(); !out.IsError(err, nil, 4550) {
+		t.Errorf("Expected an error getting a sparse hg mirror clone, err: %v", err)
+	}
+	for _, vcsType := range []Type{Svn, Bzr} {
+		if _, err = NewSparseMgr(".", vcsType); err != ErrNotImplemented {
+			t.Errorf("Expected %s sparse manager to not be implemented, err: %v", vcsType, err)
+		}
+	}
+}
+
+// TestParseHgSparse verifies parsing of the hg sparse config
+func TestParseHgSparse(t *testing.T) {
+	output := "%include profile.sparse\n[include]\npath:a\nglob:docs/*.txt\n# comment\n[exclude]\npath:a/big\n"
+	if paths := hgParseSparse(output); !reflect.DeepEqual(paths, []string{"a", "glob:docs/*.txt"}) {
+		t.Errorf("Incorrect hg sparse paths parsed, found: %v", paths)
+	}
+	if paths := hgParseSparse(""); paths != nil {
+		t.Errorf("Expected no hg sparse paths for a full checkout, found: %v", paths)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+import "strings"
+
+// FileState describes the state of a file in the workspace compared to the
+// revision checked out (see FileStatus)
+type FileState string
+
+// File states for a workspace status (see StatusReader)
+const (
+	// FileModified indicates the file content (or mode) was changed
+	FileModified FileState = "modified"
+	// FileAdded indicates the file was added (or copied) but not committed
+	FileAdded FileState = "added"
+	// FileDeleted indicates the file was removed or is missing
+	FileDeleted FileState = "deleted"
+	// FileRenamed indicates the file was renamed (see FileStatus OrigPath)
+	FileRenamed FileState = "renamed"
+	// FileUntracked indicates the file is not under version control
+	FileUntracked FileState = "untracked"
+	// FileConflicted indicates the file has unresolved merge conflicts
+	FileConflicted FileState = "conflicted"
+)
+
+// FileStatus is the status of a single changed file in a workspace
+type FileStatus struct {
+	// Path is the file path relative to the local repo path
+	Path string
+
+	// OrigPath is the path the file was renamed or copied from, if known
+	OrigPath string
+
+	// State is what happened to the file (eg: FileModified)
+	State FileState
+}
+
+// Status is the state of a workspace: the files with local changes and how
+// far the checked out revision is ahead of and behind the remote
+type Status struct {
+	// Files has the status of each changed (or untracked) file
+	Files []FileStatus
+
+	// Ahead is the number of local commits not in the remote (always 0
+	// for a centralized VCS like svn)
+	Ahead int
+
+	// Behind is the number of remote commits not yet in the workspace
+	Behind int
+}
+
+// Dirty returns true if any files in the workspace have local changes (new
+// untracked files don't count, see Untracked)
+func (s *Status) Dirty() bool {
+	for _, file := range s.Files {
+		if file.State != FileUntracked {
+			return true
+		}
+	}
+	return false
+}
+
+// Untracked returns true if there are files not under version control
+func (s *Status) Untracked() bool {
+	for _, file := range s.Files {
+		if file.State == FileUntracked {
+			return true
+		}
+	}
+	return false
+}
+
+// Conflicted returns true if any files have unresolved merge conflicts
+func (s *Status) Conflicted() bool {
+	for _, file := range s.Files {
+		if file.State == FileConflicted {
+			return true
+		}
+	}
+	return false
+}
+
+// Unpushed returns true if there are local commits not in the remote
+func (s *Status) Unpushed() bool {
+	return s.Ahead > 0
+}
+
+// addFile adds a file status, replacing any existing status for the path
+// (eg: a modified file that turns out to be conflicted)
+func (s *Status) addFile(path, origPath string, state FileState) {
+	for i := range s.Files {
+		if s.Files[i].Path == path {
+			s.Files[i].State = state
+			if origPath != "" {
+				s.Files[i].OrigPath = origPath
+			}
+			return
+		}
+	}
+	s.Files = append(s.Files, FileStatus{Path: path, OrigPath: origPath, State: state})
+}
+
+// countLines returns the number of non-empty lines in the given output
+func countLines(output string) int {
+	count := 0
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// StatusReader examines a workspace to determine what files have local
+// changes and if there are unpushed (or not yet pulled) commits
+type StatusReader interface {
+	// Describer access to VCS system details (Remote, LocalRepoPath, ..)
+	Describer
+
+	// Status retrieves the workspace file status list along with the
+	// counts of commits ahead of and behind the remote (RemoteRepoName)
+	Status() (*Status, Resulter, error)
+}
+
+// NewStatusReader returns a VCS StatusReader based on trying to detect the
+// VCS sys from the remote and local repo locations.  The appropriate
+// implementation will be returned or an ErrCannotDetectVCS if the VCS type
+// cannot be detected.  Params:
+//	remote (string): URL of remote repo (can be "")
+//	remoteName (string): "" or remote repo "name" to compare with for the
+//	                     ahead/behind counts (eg: "origin" is default for git)
+//	localPath (string): Directory for the local workspace to get the status of
+//	vcsType (Type): optional; forcibly tell the pkg what the vcs type is (no auto-determination)
+// Note: This function can make network calls to try to determine the VCS
+//       (unless grabbing the repo from a local filesystem/mount)
+func NewStatusReader(remote, remoteName, localPath string, vcsType ...Type) (StatusReader, error) {
+	vtype, remote, err := detectVCSType(remote, localPath, vcsType...)
+	if err != nil {
+		return nil, err
+	}
+	switch vtype {
+	case Git:
+		return NewGitStatusReader(remote, remoteName, localPath)
+	case Svn:
+		return NewSvnStatusReader(remote, localPath)
+	case Hg:
+		return NewHgStatusReader(remote, remoteName, localPath)
+	case Bzr:
+		return NewBzrStatusReader(remote, remoteName, localPath)
+	}
+
+	// Should never fall through to here but just in case.
+	return nil, ErrCannotDetectVCS
+}
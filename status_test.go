@@ -0,0 +1,195 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestStatus verifies local changes are found in a workspace of each VCS
+// type (skipping those with no tools installed)
+func TestStatus(t *testing.T) {
+	for _, vcsType := range []Type{Git, Hg, Svn, Bzr} {
+		vcsType := vcsType
+		t.Run(string(vcsType), func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "go-vcs-status-tests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+			fixture := newFixture(t, vcsType, tempDir)
+			localPath := filepath.Join(tempDir, "VCSTestRepo")
+			fixture.checkout(localPath)
+
+			reader, err := NewStatusReader(fixture.remote, "", localPath, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS status reader, err: %s", vcsType, err)
+			}
+			status, results, err := reader.Status()
+			if err != nil {
+				t.Fatalf("Unable to read %s workspace status, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if len(status.Files) != 0 || status.Ahead != 0 || status.Behind != 0 {
+				t.Errorf("Expected a clean %s workspace, found: %+v", vcsType, status)
+			}
+
+			fixture.write(localPath, "README", "local change")
+			fixture.write(localPath, "NEWFILE", "new")
+			status, results, err = reader.Status()
+			if err != nil {
+				t.Fatalf("Unable to read %s workspace status, err: %s, results:\n%s", vcsType, err, results)
+			}
+			expected := []FileStatus{{Path: "README", State: FileModified}, {Path: "NEWFILE", State: FileUntracked}}
+			if !sameFiles(status.Files, expected) || !status.Dirty() || !status.Untracked() || status.Conflicted() {
+				t.Errorf("Incorrect %s workspace status, found: %+v", vcsType, status)
+			}
+		})
+	}
+}
+
+// TestGitStatus verifies the git ahead/behind counts and file states
+func TestGitStatus(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	localPath := filepath.Join(tempDir, "VCSTestRepo")
+	fixture.run(tempDir, nil, gitTool, "clone", "-q", fixture.remote, localPath)
+	reader, err := NewGitStatusReader(fixture.remote, "", localPath)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git status reader, err: %s", err)
+	}
+
+	// move back before the merge and commit locally: 1 ahead, 2 behind
+	fixture.run(localPath, nil, gitTool, "reset", "-q", "--hard", string(fixture.revs["third"]))
+	fixture.run(localPath, nil, gitTool, "mv", "README", "README.md")
+	fixture.run(localPath, nil, gitTool, "commit", "-q", "-m", "local commit")
+	fixture.run(localPath, nil, gitTool, "mv", "README.md", "README")
+	fixture.run(localPath, nil, gitTool, "rm", "-q", "--cached", "README")
+	fixture.write(localPath, "ADDED", "added")
+	fixture.run(localPath, nil, gitTool, "add", "ADDED")
+	status, results, err := reader.Status()
+	if err != nil {
+		t.Fatalf("Unable to read git workspace status, err: %s, results:\n%s", err, results)
+	}
+	if status.Ahead != 1 || status.Behind != 2 || !status.Unpushed() {
+		t.Errorf("Incorrect git ahead/behind counts, found: %d/%d", status.Ahead, status.Behind)
+	}
+	expected := []FileStatus{
+		{Path: "ADDED", State: FileAdded},
+		{Path: "README.md", State: FileDeleted},
+		{Path: "README", State: FileUntracked},
+	}
+	if !sameFiles(status.Files, expected) {
+		t.Errorf("Incorrect git workspace file status, found: %+v", status.Files)
+	}
+
+	// git writing to stderr (trace lines) doesn't change the status
+	reader.SetRunner(envRunner{traceEnv})
+	traced, results, err := reader.Status()
+	if err != nil {
+		t.Fatalf("Unable to read git workspace status with stderr output, err: %s, results:\n%s", err, results)
+	}
+	if results.Last().Stderr == "" || !reflect.DeepEqual(traced, status) {
+		t.Errorf("Incorrect git status with stderr output, expected: %+v, found: %+v", status, traced)
+	}
+	reader.SetRunner(nil)
+
+	// a detached HEAD has no tracking branch, nothing is ahead of the remote
+	fixture.run(localPath, nil, gitTool, "checkout", "-q", "-f", string(fixture.revs["second"]))
+	if status, results, err = reader.Status(); err != nil {
+		t.Fatalf("Unable to read git workspace status, err: %s, results:\n%s", err, results)
+	}
+	if status.Ahead != 0 || status.Behind != 0 {
+		t.Errorf("Incorrect git detached HEAD ahead/behind counts, found: %d/%d", status.Ahead, status.Behind)
+	}
+}
+
+// TestParseStatus verifies the status output of each VCS is parsed right
+func TestParseStatus(t *testing.T) {
+	gitOutput := "# branch.oid 1a2b\x00# branch.head master\x00# branch.upstream origin/master\x00# branch.ab +2 -1\x00" +
+		"1 .M N... 100644 100644 100644 1a2b 1a2b a file.txt\x00" +
+		"2 R. N... 100644 100644 100644 1a2b 1a2b R100 new.txt\x00old.txt\x00" +
+		"u UU N... 100644 100644 100644 100644 1a2b 3c4d 5e6f conflict.txt\x00" +
+		"? untracked.txt\x00"
+	status, branch, err := gitParseStatus(gitOutput)
+	if err != nil {
+		t.Fatalf("Unable to parse git status output, err: %s", err)
+	}
+	if branch.head != "master" || branch.upstream != "origin/master" || branch.ahead != 2 || branch.behind != 1 {
+		t.Errorf("Incorrect git branch status, found: %+v", branch)
+	}
+	checkFiles(t, Git, status, []FileStatus{
+		{Path: "a file.txt", State: FileModified},
+		{Path: "new.txt", OrigPath: "old.txt", State: FileRenamed},
+		{Path: "conflict.txt", State: FileConflicted},
+		{Path: "untracked.txt", State: FileUntracked},
+	})
+	if _, _, err = gitParseStatus("1 bogus\x00"); err == nil {
+		t.Errorf("Expected an error parsing bad git status output")
+	}
+
+	hgOutput := "M a.txt\nA new.txt\n  old.txt\nA copy.txt\n  a.txt\nR old.txt\n! missing.txt\n? untracked.txt\n"
+	checkFiles(t, Hg, hgParseStatus(hgOutput, "U a.txt\nR other.txt\n"), []FileStatus{
+		{Path: "a.txt", State: FileConflicted},
+		{Path: "new.txt", OrigPath: "old.txt", State: FileRenamed},
+		{Path: "copy.txt", OrigPath: "a.txt", State: FileAdded},
+		{Path: "missing.txt", State: FileDeleted},
+		{Path: "untracked.txt", State: FileUntracked},
+	})
+
+	svnOutput := "M       a.txt\nA  +    new.txt\nD       old.txt\n C      props.txt\n" +
+		"      C tree.txt\n      >   local file edit, incoming file delete upon update\n" +
+		"?       untracked.txt\nX       external\nI       ignored.txt\n" +
+		"Summary of conflicts:\n  Tree conflicts: 1\n"
+	checkFiles(t, Svn, svnParseStatus(svnOutput), []FileStatus{
+		{Path: "a.txt", State: FileModified},
+		{Path: "new.txt", State: FileAdded},
+		{Path: "old.txt", State: FileDeleted},
+		{Path: "props.txt", State: FileConflicted},
+		{Path: "tree.txt", State: FileConflicted},
+		{Path: "untracked.txt", State: FileUntracked},
+	})
+
+	bzrOutput := " M  a.txt\n+N  new.txt\n-D  gone.txt\nR   old.txt => moved.txt\n  * script.sh\n" +
+		"?   untracked.txt\nC   Text conflict in b.txt\n M  b.txt\nP   tester@example.com-20160102\n"
+	checkFiles(t, Bzr, bzrParseStatus(bzrOutput, "b.txt\n"), []FileStatus{
+		{Path: "a.txt", State: FileModified},
+		{Path: "new.txt", State: FileAdded},
+		{Path: "gone.txt", State: FileDeleted},
+		{Path: "moved.txt", OrigPath: "old.txt", State: FileRenamed},
+		{Path: "script.sh", State: FileModified},
+		{Path: "untracked.txt", State: FileUntracked},
+		{Path: "b.txt", State: FileConflicted},
+	})
+}
+
+// checkFiles reports a test error if the file status list isn't as expected
+func checkFiles(t *testing.T, vcsType Type, status *Status, expected []FileStatus) {
+	if !reflect.DeepEqual(status.Files, expected) {
+		t.Errorf("Incorrect %s file status, expected: %+v, found: %+v", vcsType, expected, status.Files)
+	}
+}
+
+// sameFiles returns true if the file status lists match, in any order
+func sameFiles(files, expected []FileStatus) bool {
+	if len(files) != len(expected) {
+		return false
+	}
+	for _, want := range expected {
+		found := false
+		for _, file := range files {
+			if file == want {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -1,10 +1,16 @@
 package vcs
 
 import (
+	"encoding/xml"
 	"fmt"
-	"os"
+	"io"
+	neturl "net/url"
+	"path"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dvln/out"
 	"github.com/dvln/util/dir"
@@ -14,6 +20,18 @@ import (
 var svnDetectURL = regexp.MustCompile("URL: (?P<foo>.+)\n")
 var defaultSvnSchemes []string
 
+// svnErrPatterns classifies failed svn cmds by the svn error codes in their
+// output, these don't vary with the wording or locale (see CmdError)
+var svnErrPatterns = []errPattern{
+	{ErrAuth, regexp.MustCompile(`E170001|E215004|E175013`)},
+	{ErrLocked, regexp.MustCompile(`E155004|E155037|E200033`)},
+	{ErrCorrupt, regexp.MustCompile(`E155016|E160004|E200014`)},
+	{ErrDirtyTree, regexp.MustCompile(`(?i)has local modifications`)},
+	{ErrMergeConflict, regexp.MustCompile(`E155015|Summary of conflicts`)},
+	{ErrUnknownRev, regexp.MustCompile(`E160006|E160013|E195012`)},
+	{ErrUnreachable, regexp.MustCompile(`E170013|E175002|E670002|E670008|E730060|E730061|E731001|E000110|E000111`)},
+}
+
 func init() {
 	SetDefaultSvnSchemes(nil)
 }
@@ -26,9 +44,9 @@ func SvnGet(g *SvnGetter, rev ...Rev) (Resulter, error) {
 	var result *Result
 	var err error
 	if rev == nil || (rev != nil && rev[0] == "") {
-		result, err = run(svnTool, "checkout", g.Remote(), g.LocalRepoPath())
+		result, err = run(g.Context(), svnTool, "checkout", g.Remote(), g.LocalRepoPath())
 	} else {
-		result, err = run(svnTool, "checkout", "-r", string(rev[0]), g.Remote(), g.LocalRepoPath())
+		result, err = run(g.Context(), svnTool, "checkout", "-r", string(rev[0]), g.Remote(), g.LocalRepoPath())
 	}
 	results.add(result)
 	return results, err
@@ -40,9 +58,9 @@ func SvnUpdate(u *SvnUpdater, rev ...Rev) (Resulter, error) {
 	var result *Result
 	var err error
 	if rev == nil || (rev != nil && rev[0] == "") {
-		result, err = runFromLocalRepoDir(u.LocalRepoPath(), svnTool, "update")
+		result, err = runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), svnTool, "update")
 	} else {
-		result, err = runFromLocalRepoDir(u.LocalRepoPath(), svnTool, "update", "-r", string(rev[0]))
+		result, err = runFromLocalRepoDir(u.Context(), u.LocalRepoPath(), svnTool, "update", "-r", string(rev[0]))
 	}
 	results.add(result)
 	return results, err
@@ -55,7 +73,7 @@ func SvnUpdate(u *SvnUpdater, rev ...Rev) (Resulter, error) {
 // is returned from the svn update run.
 func SvnRevSet(r RevSetter, rev Rev) (Resulter, error) {
 	results := newResults()
-	result, err := runFromLocalRepoDir(r.LocalRepoPath(), svnTool, "update", "-r", string(rev))
+	result, err := runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), svnTool, "update", "-r", string(rev))
 	results.add(result)
 	return results, err
 }
@@ -64,62 +82,783 @@ func SvnRevSet(r RevSetter, rev Rev) (Resulter, error) {
 // pointer is returned (how filled out depends upon if the read is just the
 // basic core/raw VCS revision or full data for the given VCS which will
 // include tags, branches, timestamp info, author/committer, date, comment).
-// Note: this reads one version but that could be expanded to take <N>
-// revisions or a range, eg SvnRevRead(reader, <scope>, rev1, "..", rev2),
-// without changing this methods params or return signature (but code
-// changes  would be needed)
+// The core revision is the revision the working copy (root) is at, or the
+// given revision (eg: "1234", "HEAD", "{2015-01-01}") resolved to a number,
+// the author, date and comment are those of that commit (even if it didn't
+// change the working copy itself).
+// Branches and tags are derived from the repo URL layout (trunk, branches/<name>,
+// tags/<name>).  For mixed revision or modified working copy details see
+// SvnReadWCVersion().
+// A range of revisions may also be given, eg: SvnRevRead(reader, <scope>,
+// rev1, "..", rev2), which is the same as SvnRevLog(reader, <scope>, rev1,
+// rev2, 0) (see that routine for details).
 func SvnRevRead(r RevReader, scope ReadScope, vcsRev ...Rev) ([]Revisioner, Resulter, error) {
+	if from, to, ok := revRange(vcsRev); ok {
+		return SvnRevLog(r, scope, from, to, 0)
+	}
 	results := newResults()
 	specificRev := ""
 	if vcsRev != nil && vcsRev[0] != "" {
 		specificRev = string(vcsRev[0])
 	}
-	oldDir, err := os.Getwd()
+	revOpt := ""
+	if specificRev != "" {
+		revOpt = "-r" + specificRev
+	}
+	result, err := run(r.Context(), svnTool, "info", "--xml", revOpt, r.LocalRepoPath())
+	results.add(result)
 	if err != nil {
-		return nil, nil, err
+		return nil, results, err
 	}
-	err = os.Chdir(r.LocalRepoPath())
+	info, err := svnParseInfo(result.Stdout)
 	if err != nil {
-		return nil, nil, err
+		return nil, results, err
 	}
-	defer os.Chdir(oldDir)
 
 	rev := &Revision{}
 	var revs []Revisioner
+	rev.SetCore(Rev(info.Entry.Revision))
 	if scope == CoreRev {
 		// client just wants the core/base VCS revision only..
-		//FIXME: based on SVN docs this doesn't seem like it
-		//       can handle the various output formats correctly with
-		//       modifiers like "<rev#>M" or "<rev#>S" or "<rev#>:<rev#>",
-		//       see svnversion -h, perhaps update
-		//       Also: specificRev isn't implemented for SVN, is it possible?
-		if specificRev != "" {
-			return nil, nil, fmt.Errorf("Reading specified revision, %s, not supported by SVN", specificRev)
+		revs = append(revs, rev)
+		return revs, results, nil
+	}
+
+	// client wants all the data we can get on the revision, the commit data
+	// is read from the log of the repo root as the revision may not have
+	// changed the working copy (its last changed rev is then an older commit)
+	result, err = run(r.Context(), svnTool, "log", "--xml", "-r"+info.Entry.Revision, info.Entry.Repository.Root)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var log svnLog
+	if err = xml.Unmarshal([]byte(result.Stdout), &log); err != nil {
+		return nil, results, out.WrapErrf(err, 4521, "Unable to parse svn log output for revision %s", info.Entry.Revision)
+	}
+	if len(log.Entries) != 1 {
+		return nil, results, out.NewErrf(4565, "Unable to read svn revision %s from repo: %s", info.Entry.Revision, info.Entry.Repository.Root)
+	}
+	entry := log.Entries[0]
+	rev.SetUserInfo(AuthComm, entry.Author, entry.Author)
+	if entry.Date != "" {
+		tstamp, err := time.Parse(time.RFC3339Nano, entry.Date)
+		if err != nil {
+			return nil, results, out.WrapErrf(err, 4520, "Unable to parse svn commit date for revision %s", entry.Revision)
 		}
-		var result *Result
-		result, err = run("svnversion", ".")
+		rev.SetTStamp(AuthComm, &tstamp)
+	}
+	rev.SetComment(strings.TrimRight(entry.Msg, "\n"))
+	branches, tags := svnURLRefs(info.Entry.URL, info.Entry.Repository.Root)
+	semVers, tags := splitSemVers(r.SemVerPrefix(), tags)
+	rev.SetBranches(branches)
+	rev.SetTags(tags)
+	rev.SetSemVers(semVers)
+	revs = append(revs, rev)
+	return revs, results, nil
+}
+
+// SvnRevLog retrieves the revisions after the 'from' rev up to and including
+// the 'to' rev that changed the working copy (or given paths within it), in
+// topological order, newest first (ie: svn log -r to:from).  Params:
+//	r (Describer): describes the local working copy to read the history from
+//	scope (ReadScope): CoreRev for just the revisions, AllData for full revisions
+//	from (Rev): start of the range (excluded), "" to walk all history
+//	to (Rev): end of the range (included), "" for the working copy rev (BASE)
+//	max (int): max number of revisions to return, 0 for no limit
+//	paths (...string): optional; only revisions changing these paths (relative
+//	                   to the working copy root) are returned
+// Returns the revisions, results (vcs cmds run, output) and any error
+func SvnRevLog(r Describer, scope ReadScope, from, to Rev, max int, paths ...string) ([]Revisioner, Resulter, error) {
+	results := newResults()
+	if to == "" {
+		to = "BASE"
+	}
+	// the 'from' rev is excluded, resolve it so it can be dropped from the log
+	fromRev := ""
+	start := "1"
+	if from != "" {
+		result, err := run(r.Context(), svnTool, "info", "--xml", "-r"+string(from), r.LocalRepoPath())
 		results.add(result)
 		if err != nil {
 			return nil, results, err
 		}
-		rev.SetCore(Rev(strings.TrimSpace(result.Output)))
+		info, err := svnParseInfo(result.Stdout)
+		if err != nil {
+			return nil, results, err
+		}
+		fromRev = info.Entry.Revision
+		start = fromRev
+	}
+	limitOpt := ""
+	if max > 0 {
+		limit := max
+		if fromRev != "" {
+			limit++ // one extra as the 'from' rev itself is dropped
+		}
+		limitOpt = fmt.Sprintf("--limit=%d", limit)
+	}
+	// resolve 'to' to a number as well, BASE (the default) only works for a
+	// working copy target, not for the URL used when paths are given
+	result, err := run(r.Context(), svnTool, "info", "--xml", "-r"+string(to), r.LocalRepoPath())
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	info, err := svnParseInfo(result.Stdout)
+	if err != nil {
+		return nil, results, err
+	}
+	args := []string{"log", "--xml", fmt.Sprintf("-r%s:%s", info.Entry.Revision, start), limitOpt}
+	if paths == nil {
+		args = append(args, r.LocalRepoPath())
+	} else { // multiple paths only work relative to a URL
+		args = append(args, info.Entry.URL)
+		args = append(args, paths...)
+	}
+	result, err = run(r.Context(), svnTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var log svnLog
+	if err = xml.Unmarshal([]byte(result.Stdout), &log); err != nil {
+		return nil, results, out.WrapErr(err, "Unable to parse svn log output", 4521)
+	}
+	branches, tags := svnURLRefs(info.Entry.URL, info.Entry.Repository.Root)
+	semVers, tags := splitSemVers(r.SemVerPrefix(), tags)
+	var revs []Revisioner
+	for _, entry := range log.Entries {
+		if entry.Revision == fromRev {
+			continue
+		}
+		rev := &Revision{}
+		rev.SetCore(Rev(entry.Revision))
+		if scope != CoreRev {
+			rev.SetUserInfo(AuthComm, entry.Author, entry.Author)
+			if entry.Date != "" {
+				tstamp, err := time.Parse(time.RFC3339Nano, entry.Date)
+				if err != nil {
+					return nil, results, out.WrapErrf(err, 4520, "Unable to parse svn commit date for revision %s", entry.Revision)
+				}
+				rev.SetTStamp(AuthComm, &tstamp)
+			}
+			rev.SetComment(strings.TrimRight(entry.Msg, "\n"))
+			rev.SetBranches(branches)
+			rev.SetTags(tags)
+			rev.SetSemVers(semVers)
+		}
 		revs = append(revs, rev)
-	} else {
-		//FIXME: this needs to add more data if possible for SVN
-		//       Also: specificRev isn't implemented for SVN, is it possible?
-		if specificRev != "" {
-			return nil, nil, fmt.Errorf("Reading specified revision, %s, not supported by SVN", specificRev)
+	}
+	if max > 0 && len(revs) > max {
+		revs = revs[:max]
+	}
+	return revs, results, nil
+}
+
+// svnInfo is used to unmarshal the parts of 'svn info --xml' output we use
+type svnInfo struct {
+	Entry struct {
+		Revision   string `xml:"revision,attr"`
+		URL        string `xml:"url"`
+		Repository struct {
+			Root string `xml:"root"`
+		} `xml:"repository"`
+	} `xml:"entry"`
+}
+
+// svnLog is used to unmarshal the parts of 'svn log --xml' output we use
+type svnLog struct {
+	Entries []struct {
+		Revision string `xml:"revision,attr"`
+		Author   string `xml:"author"`
+		Date     string `xml:"date"`
+		Msg      string `xml:"msg"`
+	} `xml:"logentry"`
+}
+
+// svnParseInfo unmarshals 'svn info --xml' output, returns the info
+// and any error that occurred
+func svnParseInfo(output string) (*svnInfo, error) {
+	info := &svnInfo{}
+	if err := xml.Unmarshal([]byte(output), info); err != nil {
+		return nil, out.WrapErr(err, "Unable to parse svn info output", 4519)
+	}
+	return info, nil
+}
+
+// svnURLRefs uses the standard svn repo layout (trunk, branches/<name> and
+// tags/<name>) to figure out what branch or tag a URL within the given repo
+// root refers to, eg: "<root>/branches/1.x/src" is the "1.x" branch.  The
+// branches and tags found are returned (in that order, at most one entry).
+func svnURLRefs(svnURL, root string) ([]Rev, []Rev) {
+	if root == "" || !strings.HasPrefix(svnURL, root) {
+		return nil, nil
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(svnURL, root), "/"), "/")
+	for i, part := range parts {
+		switch {
+		case part == "trunk":
+			return []Rev{"trunk"}, nil
+		case part == "branches" && i+1 < len(parts):
+			return []Rev{Rev(parts[i+1])}, nil
+		case part == "tags" && i+1 < len(parts):
+			return nil, []Rev{Rev(parts[i+1])}
 		}
-		var result *Result
-		result, err = run("svnversion", ".")
+	}
+	return nil, nil
+}
+
+// svnVersionRegex matches the revision output of 'svnversion', eg: "4168",
+// "4123:4168" (mixed revision) optionally followed by state flags (M|S|P)
+var svnVersionRegex = regexp.MustCompile(`^([0-9]+)(?::([0-9]+))?([MSP]*)$`)
+
+// SvnWCVersion describes the revision state of a whole svn working copy,
+// this is the structured form of 'svnversion' output (eg: "4123:4168MS")
+type SvnWCVersion struct {
+	MinRev   Rev  // lowest revision found in the working copy
+	MaxRev   Rev  // highest revision found (same as MinRev if not mixed)
+	Modified bool // working copy has local modifications ("M")
+	Switched bool // working copy has switched sub-trees ("S")
+	Partial  bool // working copy is sparse/depth limited ("P")
+}
+
+// Mixed returns true if the working copy has more than one revision in it
+func (v *SvnWCVersion) Mixed() bool {
+	return v.MinRev != v.MaxRev
+}
+
+// String implements a stringer for the *SvnWCVersion type, the output
+// matches the 'svnversion' format (eg: "4123:4168MS")
+func (v *SvnWCVersion) String() string {
+	str := string(v.MinRev)
+	if v.Mixed() {
+		str = fmt.Sprintf("%s:%s", v.MinRev, v.MaxRev)
+	}
+	if v.Modified {
+		str += "M"
+	}
+	if v.Switched {
+		str += "S"
+	}
+	if v.Partial {
+		str += "P"
+	}
+	return str
+}
+
+// svnParseVersion turns 'svnversion' output into an *SvnWCVersion, any
+// output that isn't a revision (eg: "Unversioned directory") is an error
+func svnParseVersion(output string) (*SvnWCVersion, error) {
+	output = strings.TrimSpace(output)
+	m := svnVersionRegex.FindStringSubmatch(output)
+	if m == nil {
+		return nil, out.NewErrf(4522, "Unable to determine svn working copy revision from: \"%s\"", output)
+	}
+	v := &SvnWCVersion{MinRev: Rev(m[1]), MaxRev: Rev(m[1])}
+	if m[2] != "" {
+		v.MaxRev = Rev(m[2])
+	}
+	v.Modified = strings.Contains(m[3], "M")
+	v.Switched = strings.Contains(m[3], "S")
+	v.Partial = strings.Contains(m[3], "P")
+	return v, nil
+}
+
+// SvnReadWCVersion examines the whole svn working copy and returns its
+// revision state (mixed revisions, local modifications, switched or
+// partial sub-trees), the svnversion cmd run and output and any error.
+func SvnReadWCVersion(d Describer) (*SvnWCVersion, Resulter, error) {
+	results := newResults()
+	result, err := run(d.Context(), "svnversion", d.LocalRepoPath())
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	version, err := svnParseVersion(result.Stdout)
+	return version, results, err
+}
+
+// svnCommittedRegex finds the new revision in 'svn commit' output
+var svnCommittedRegex = regexp.MustCompile(`Committed revision ([0-9]+)\.`)
+
+// SvnCommit schedules any unversioned files to be added and missing files to
+// be removed for the given paths (or the whole working copy if no paths
+// given) and commits the changes straight to the repo with the comment from
+// the given revision.  The svn user (--username) is the committer id (or
+// name), else the author id (or name), the default svn auth is used if not
+// set.  The repo sets the commit time so any timestamps are ignored.  If
+// there is nothing to commit an error is returned.  Params:
+//	c (Describer): describes the working copy to commit from
+//	rev (*Revision): the comment (required) and user info to use
+//	paths (...string): optional; only commit these paths (relative to the working copy)
+// Returns the new revision, results (vcs cmds run, output) and any error
+func SvnCommit(c Describer, rev *Revision, paths ...string) (Revisioner, Resulter, error) {
+	results := newResults()
+	if err := checkCommitRev(c, rev); err != nil {
+		return nil, results, err
+	}
+	runDir := c.LocalRepoPath()
+	result, err := runFromLocalRepoDir(c.Context(), runDir, svnTool, append([]string{"status"}, paths...)...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var added, removed []string
+	for _, file := range svnParseStatus(result.Stdout).Files {
+		switch file.State {
+		case FileUntracked:
+			added = append(added, file.Path)
+		case FileDeleted:
+			removed = append(removed, file.Path)
+		}
+	}
+	if added != nil {
+		result, err = runFromLocalRepoDir(c.Context(), runDir, svnTool, append([]string{"add", "-q", "--parents", "--force"}, added...)...)
 		results.add(result)
 		if err != nil {
 			return nil, results, err
 		}
-		rev.SetCore(Rev(strings.TrimSpace(result.Output)))
-		revs = append(revs, rev)
 	}
-	return revs, results, err
+	if removed != nil {
+		result, err = runFromLocalRepoDir(c.Context(), runDir, svnTool, append([]string{"rm", "-q", "--force"}, removed...)...)
+		results.add(result)
+		if err != nil {
+			return nil, results, err
+		}
+	}
+	args := []string{"commit", "-m", rev.Comment()}
+	for _, utype := range []UserType{Committer, Author} {
+		if name, id := rev.UserInfo(utype); id != "" || name != "" {
+			if id == "" {
+				id = name
+			}
+			args = append(args, "--username", id)
+			break
+		}
+	}
+	result, err = runFromLocalRepoDir(c.Context(), runDir, svnTool, append(args, paths...)...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	m := svnCommittedRegex.FindStringSubmatch(result.Stdout)
+	if m == nil {
+		return nil, results, out.NewErrf(4541, "Svn commit found no changes to commit, working copy: %s", runDir)
+	}
+	// the working copy root isn't at the new revision, read it via the URL
+	result, err = run(c.Context(), svnTool, "info", "--xml", runDir)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	info, err := svnParseInfo(result.Stdout)
+	if err != nil {
+		return nil, results, err
+	}
+	result, err = run(c.Context(), svnTool, "log", "--xml", "-r"+m[1], info.Entry.URL)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var log svnLog
+	if err = xml.Unmarshal([]byte(result.Stdout), &log); err != nil {
+		return nil, results, out.WrapErr(err, "Unable to parse svn log output", 4521)
+	}
+	if len(log.Entries) != 1 {
+		return nil, results, out.NewErrf(4542, "Svn commit unable to read new revision %s, working copy: %s", m[1], runDir)
+	}
+	entry := log.Entries[0]
+	newRev := &Revision{}
+	newRev.SetCore(Rev(entry.Revision))
+	newRev.SetUserInfo(AuthComm, entry.Author, entry.Author)
+	if entry.Date != "" {
+		tstamp, err := time.Parse(time.RFC3339Nano, entry.Date)
+		if err != nil {
+			return nil, results, out.WrapErrf(err, 4520, "Unable to parse svn commit date for revision %s", entry.Revision)
+		}
+		newRev.SetTStamp(AuthComm, &tstamp)
+	}
+	newRev.SetComment(strings.TrimRight(entry.Msg, "\n"))
+	branches, tags := svnURLRefs(info.Entry.URL, info.Entry.Repository.Root)
+	semVers, tags := splitSemVers(c.SemVerPrefix(), tags)
+	newRev.SetBranches(branches)
+	newRev.SetTags(tags)
+	newRev.SetSemVers(semVers)
+	return newRev, results, nil
+}
+
+// SvnDiff returns the parsed diff between two revisions, or a revision and
+// the working copy, see the Differ interface for details (the working copy
+// revision is BASE, property changes are not included).  Params:
+//	r (Describer): describes the working copy to diff
+//	from (Rev): the old revision, "" for the working copy revision (BASE)
+//	to (Rev): the new revision, "" for the working copy
+//	paths (...string): optional; only diff these paths (relative to the working copy)
+// Returns the file diffs, results (vcs cmds run, output) and any error
+func SvnDiff(r Describer, from, to Rev, paths ...string) ([]*FileDiff, Resulter, error) {
+	results := newResults()
+	revOpt := "BASE"
+	if from != "" {
+		revOpt = string(from)
+	}
+	if to != "" {
+		revOpt += ":" + string(to)
+	}
+	args := []string{"diff", "--internal-diff", "-r", revOpt}
+	args = append(args, paths...)
+	result, err := runFromLocalRepoDir(r.Context(), r.LocalRepoPath(), svnTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	files, err := parseDiff(result.Stdout)
+	return files, results, err
+}
+
+// svnStatusRegex matches a 'svn status' line: the 7 status columns and the
+// path, other lines (tree conflict details, summaries, etc) don't match
+var svnStatusRegex = regexp.MustCompile(`^([ ACDIMRX?!~])([ CM])([ L])([ +])([ SX])([ KOTB])([ C]) (.+)$`)
+
+// SvnStatus reads the status of the working copy: the files with local
+// changes, unversioned files and conflicts, along with the number of
+// revisions committed to the working copy URL since its base revision
+// (Behind), the repo is contacted for that count.  As svn commits go straight
+// to the repo there is never anything unpushed (Ahead is always 0).  Params:
+//	s (Describer): describes the working copy to get the status of
+// Returns the status, results (vcs cmds run, output) and any error
+func SvnStatus(s Describer) (*Status, Resulter, error) {
+	results := newResults()
+	result, err := runFromLocalRepoDir(s.Context(), s.LocalRepoPath(), svnTool, "status")
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	status := svnParseStatus(result.Stdout)
+	result, err = run(s.Context(), svnTool, "info", "--xml", s.LocalRepoPath())
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	info, err := svnParseInfo(result.Stdout)
+	if err != nil {
+		return nil, results, err
+	}
+	base, err := strconv.Atoi(info.Entry.Revision)
+	if err != nil {
+		return nil, results, out.WrapErrf(err, 4538, "Unable to parse svn working copy revision: \"%s\"", info.Entry.Revision)
+	}
+	result, err = run(s.Context(), svnTool, "log", "--xml", "-rBASE:HEAD", s.LocalRepoPath())
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var log svnLog
+	if err = xml.Unmarshal([]byte(result.Stdout), &log); err != nil {
+		return nil, results, out.WrapErr(err, "Unable to parse svn log output", 4521)
+	}
+	for _, entry := range log.Entries {
+		if rev, err := strconv.Atoi(entry.Revision); err == nil && rev > base {
+			status.Behind++
+		}
+	}
+	return status, results, nil
+}
+
+// svnParseStatus parses 'svn status' output into the working copy file
+// status, externals and ignored files are skipped
+func svnParseStatus(output string) *Status {
+	status := &Status{}
+	for _, line := range strings.Split(output, "\n") {
+		m := svnStatusRegex.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		var state FileState
+		switch {
+		case m[1] == "C" || m[2] == "C" || m[7] == "C":
+			state = FileConflicted
+		case m[1] == "?":
+			state = FileUntracked
+		case m[1] == "A":
+			state = FileAdded
+		case m[1] == "D" || m[1] == "!":
+			state = FileDeleted
+		case m[1] == "M" || m[1] == "R" || m[1] == "~" || m[2] == "M":
+			state = FileModified
+		default: // ignored, externals, locked or switched only, etc
+			continue
+		}
+		status.Files = append(status.Files, FileStatus{Path: m[8], State: state})
+	}
+	return status
+}
+
+// svnExternals is used to unmarshal 'svn propget svn:externals --xml' output
+type svnExternals struct {
+	Targets []struct {
+		Path      string `xml:"path,attr"`
+		Externals string `xml:"property"`
+	} `xml:"target"`
+}
+
+// SvnNested returns the svn:externals defined in a working copy (on any dir
+// in it) with the revision each is pinned to, if any.  Relative external
+// URLs ("../", "^/", "//" and "/" forms) are resolved to full URLs.  Params:
+//	r (Describer): describes the working copy to read
+// Returns the externals, results (vcs cmds run, output) and any error
+func SvnNested(r Describer) ([]*NestedRepo, Resulter, error) {
+	results := newResults()
+	result, err := run(r.Context(), svnTool, "propget", "svn:externals", "-R", "--xml", r.LocalRepoPath())
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var externals svnExternals
+	if err = xml.Unmarshal([]byte(result.Stdout), &externals); err != nil {
+		return nil, results, out.WrapErr(err, "Unable to parse svn propget output", 4557)
+	}
+	if len(externals.Targets) == 0 {
+		return nil, results, nil
+	}
+	// the working copy and repo root URLs, to resolve relative externals
+	result, err = run(r.Context(), svnTool, "info", "--xml", r.LocalRepoPath())
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	info, err := svnParseInfo(result.Stdout)
+	if err != nil {
+		return nil, results, err
+	}
+	var nested []*NestedRepo
+	for _, target := range externals.Targets {
+		targetDir, err := filepath.Rel(r.LocalRepoPath(), target.Path)
+		if err != nil {
+			targetDir = target.Path
+		}
+		dirURL := svnJoinURL(info.Entry.URL, filepath.ToSlash(targetDir))
+		for _, line := range strings.Split(target.Externals, "\n") {
+			path, extURL, rev := svnParseExternal(line)
+			if extURL != "" {
+				extURL = svnResolveExternal(extURL, dirURL, info.Entry.Repository.Root)
+				nested = append(nested, newNestedRepo(r, filepath.Join(targetDir, path), extURL, Svn, rev))
+			}
+		}
+	}
+	return sortNested(nested), results, nil
+}
+
+// svnResolveExternal resolves a relative svn:externals URL to a full URL,
+// "../" is relative to the URL of the dir with the svn:externals property,
+// "^/" to the repo root URL, "//" to the scheme and "/" to the server root
+// of the dir URL.  Full URLs are returned as is.
+func svnResolveExternal(extURL, dirURL, rootURL string) string {
+	switch {
+	case strings.HasPrefix(extURL, "^/"):
+		return svnJoinURL(rootURL, extURL[2:])
+	case strings.HasPrefix(extURL, "../"):
+		return svnJoinURL(dirURL, extURL)
+	case strings.HasPrefix(extURL, "//"):
+		if u, err := neturl.Parse(dirURL); err == nil {
+			return u.Scheme + ":" + extURL
+		}
+	case strings.HasPrefix(extURL, "/"):
+		if u, err := neturl.Parse(dirURL); err == nil {
+			return u.Scheme + "://" + u.Host + extURL
+		}
+	}
+	return extURL
+}
+
+// svnJoinURL joins the relative (slash separated) path to the URL, any
+// ".." in the path is resolved
+func svnJoinURL(baseURL, relPath string) string {
+	u, err := neturl.Parse(baseURL)
+	if err != nil {
+		return strings.TrimSuffix(baseURL, "/") + "/" + relPath
+	}
+	u.Path = path.Join(u.Path, relPath)
+	u.RawPath = ""
+	return u.String()
+}
+
+// svnParseExternal parses an svn:externals definition line, either the
+// "[-r <rev>] <url>[@<peg>] <path>" format or the pre svn 1.5 "<path> [-r
+// <rev>] <url>" one, returning the path, url and pinned rev (if any)
+func svnParseExternal(line string) (string, string, Rev) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return "", "", ""
+	}
+	var rev Rev
+	var args []string
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "-r" && i+1 < len(fields):
+			rev = Rev(fields[i+1])
+			i++
+		case strings.HasPrefix(fields[i], "-r"):
+			rev = Rev(strings.TrimPrefix(fields[i], "-r"))
+		default:
+			args = append(args, fields[i])
+		}
+	}
+	if len(args) != 2 {
+		return "", "", ""
+	}
+	path, extURL := args[1], args[0]
+	if strings.Contains(args[1], "://") && !strings.Contains(args[0], "://") {
+		path, extURL = args[0], args[1] // old format, path first
+	}
+	if at := strings.LastIndex(extURL, "@"); at != -1 && at > strings.LastIndex(extURL, "/") { // peg rev
+		if rev == "" {
+			rev = Rev(extURL[at+1:])
+		}
+		extURL = extURL[:at]
+	}
+	return path, extURL, rev
+}
+
+// svnList is used to unmarshal the parts of 'svn ls --xml' output we use
+type svnList struct {
+	Lists []struct {
+		Path    string `xml:"path,attr"`
+		Entries []struct {
+			Kind   string `xml:"kind,attr"`
+			Name   string `xml:"name"`
+			Commit struct {
+				Revision string `xml:"revision,attr"`
+			} `xml:"commit"`
+		} `xml:"entry"`
+	} `xml:"list"`
+}
+
+// SvnExport writes an archive of the given revision ("" for the working copy
+// base revision) of the working copy URL to the writer, see Exporter, the
+// repo is contacted for any other revision.  Params:
+//	r (RevReader): describes the working copy to export from
+//	w (io.Writer): where the archive is written
+//	rev (Rev): the revision to export, "" for the working copy base revision
+//	opts (*ExportOptions): archive format, subdir and prefix (nil for a tar)
+// Returns results (vcs cmds run, output) and any error that may have occurred
+func SvnExport(r RevReader, w io.Writer, rev Rev, opts *ExportOptions) (Resulter, error) {
+	return exportArchive(r, w, rev, opts, svnExportTree)
+}
+
+// svnExportTree exports the files of a revision into the dir via 'svn
+// export' (externals are left out), only the subdir if one is given
+func svnExportTree(d Describer, dir string, rev Rev, subdir string) (string, Resulter, error) {
+	results := newResults()
+	target := filepath.Join(d.LocalRepoPath(), filepath.FromSlash(subdir))
+	result, err := run(d.Context(), svnTool, "export", "-q", "--ignore-externals", "-r", string(rev), target, dir)
+	results.add(result)
+	if err != nil {
+		return "", results, err
+	}
+	return dir, results, nil
+}
+
+// SvnRefs lists the branches (trunk and branches/<name>) and tags
+// (tags/<name>) of the repo using the standard svn repo layout, each with
+// the last revision that changed it.  The layout is found from the working
+// copy URL (LocalPath) or the remote URL (Remote), eg: for a ".../trunk/src"
+// URL the trunk, branches and tags dirs are those next to the trunk.  The
+// repo is contacted either way.  Params:
+//	r (Describer): describes the working copy and remote
+//	l (Location): LocalPath to use the working copy URL, Remote for the remote URL
+// Returns the refs, results (vcs cmds run, output) and any error
+func SvnRefs(r Describer, l Location) ([]*Ref, Resulter, error) {
+	results := newResults()
+	target := r.LocalRepoPath()
+	if l != LocalPath {
+		target = r.Remote()
+	}
+	result, err := run(r.Context(), svnTool, "info", "--xml", target)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	info, err := svnParseInfo(result.Stdout)
+	if err != nil {
+		return nil, results, err
+	}
+	base := svnLayoutBase(info.Entry.URL, info.Entry.Repository.Root)
+	result, err = run(r.Context(), svnTool, "ls", "--xml", base)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	var refs []*Ref
+	var list svnList
+	if err = xml.Unmarshal([]byte(result.Stdout), &list); err != nil {
+		return nil, results, out.WrapErr(err, "Unable to parse svn ls output", 4546)
+	}
+	args := []string{"ls", "--xml"}
+	for _, entry := range svnListDirs(&list) {
+		switch entry.Name {
+		case "trunk":
+			refs = append(refs, &Ref{Name: "trunk", Type: RefBranch, Rev: entry.Rev})
+		case "branches", "tags":
+			args = append(args, base+"/"+entry.Name)
+		}
+	}
+	if len(args) == 2 { // no branches or tags dirs
+		return refs, results, nil
+	}
+	result, err = run(r.Context(), svnTool, args...)
+	results.add(result)
+	if err != nil {
+		return nil, results, err
+	}
+	list = svnList{}
+	if err = xml.Unmarshal([]byte(result.Stdout), &list); err != nil {
+		return nil, results, out.WrapErr(err, "Unable to parse svn ls output", 4546)
+	}
+	for _, entry := range svnListDirs(&list) {
+		if strings.HasSuffix(entry.Path, "/tags") {
+			entry.Type = RefTag
+		}
+		refs = append(refs, entry.Ref)
+	}
+	return sortRefs(refs), results, nil
+}
+
+// svnListDir is a dir entry found in 'svn ls --xml' output, Path is the
+// path of the dir listed and Ref has the entry as a branch ref
+type svnListDir struct {
+	*Ref
+	Path string
+}
+
+// svnListDirs returns the dir entries of the 'svn ls --xml' lists
+func svnListDirs(list *svnList) []svnListDir {
+	var dirs []svnListDir
+	for _, l := range list.Lists {
+		for _, entry := range l.Entries {
+			if entry.Kind == "dir" {
+				ref := &Ref{Name: entry.Name, Type: RefBranch, Rev: Rev(entry.Commit.Revision)}
+				dirs = append(dirs, svnListDir{ref, strings.TrimRight(l.Path, "/")})
+			}
+		}
+	}
+	return dirs
+}
+
+// svnLayoutBase returns the URL of the dir holding the trunk, branches and
+// tags dirs for the given URL within the repo root (see svnURLRefs), the
+// URL itself if it isn't within one of them
+func svnLayoutBase(svnURL, root string) string {
+	if root == "" || !strings.HasPrefix(svnURL, root) {
+		return svnURL
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(svnURL, root), "/"), "/")
+	for i, part := range parts {
+		if part == "trunk" || part == "branches" || part == "tags" {
+			return strings.TrimRight(root+"/"+strings.Join(parts[:i], "/"), "/")
+		}
+	}
+	return svnURL
 }
 
 // SvnExists verifies the local repo or remote location is of the SVN type,
@@ -139,7 +878,7 @@ func SvnExists(e Existence, l Location) (string, Resulter, error) {
 		// if we have a scheme then just see if the repo exists...
 		if scheme != "" {
 			var result *Result
-			result, err = run(svnTool, "info", remote)
+			result, err = run(e.Context(), svnTool, "info", remote)
 			results.add(result)
 			if err == nil {
 				path = remote
@@ -148,7 +887,7 @@ func SvnExists(e Existence, l Location) (string, Resulter, error) {
 			vcsSchemes := e.Schemes()
 			for _, scheme = range vcsSchemes {
 				var result *Result
-				result, err = run(svnTool, "info", scheme+"://"+remote)
+				result, err = run(e.Context(), svnTool, "info", scheme+"://"+remote)
 				results.add(result)
 				if err == nil {
 					path = scheme + "://" + remote
@@ -183,9 +922,9 @@ func SvnCheckRemote(e Existence, remote string) (string, Resulter, error) {
 		// An SVN repo was found so test that the URL there matches
 		// the repo passed in here.
 		var result *Result
-		result, err := run("svn", "info", e.LocalRepoPath())
+		result, err := run(e.Context(), "svn", "info", e.LocalRepoPath())
 		results.add(result)
-		outStr = result.Output
+		outStr = result.Stdout
 		if err != nil {
 			return remote, results, err
 		}
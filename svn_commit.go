@@ -0,0 +1,38 @@
+package vcs
+
+// SvnCommitter implements the ChangeCommitter interface for the
+// Svn source control.
+type SvnCommitter struct {
+	Description
+}
+
+// NewSvnCommitter creates a new instance of SvnCommitter. The remote and
+// local directories need to be passed in.
+func NewSvnCommitter(remote, localPath string) (*SvnCommitter, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Svn. Need to report an error.
+	if err == nil && ltype != Svn {
+		return nil, ErrWrongVCS
+	}
+	c := &SvnCommitter{}
+	c.setDescription(remote, "", localPath, defaultSvnSchemes, Svn)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		c.setRemoteCheck(func() (string, Resulter, error) {
+			return SvnCheckRemote(c, remote)
+		})
+		if _, err = c.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Commit support for svn committer
+func (c *SvnCommitter) Commit(rev *Revision, paths ...string) (Revisioner, Resulter, error) {
+	return SvnCommit(c, rev, paths...)
+}
+
+// Exists support for svn committer
+func (c *SvnCommitter) Exists(l Location) (string, Resulter, error) {
+	return SvnExists(c, l)
+}
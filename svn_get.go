@@ -20,11 +20,12 @@ func NewSvnGetter(remote, remoteName, localPath string, mirror bool) (Getter, er
 	g.mirror = mirror
 	g.setDescription(remote, "", localPath, defaultSvnSchemes, Svn)
 	if err == nil { // Have a localPath FS repo, try to validate/upd remote
-		remote, _, err = SvnCheckRemote(g, remote)
-		if err != nil {
+		g.setRemoteCheck(func() (string, Resulter, error) {
+			return SvnCheckRemote(g, remote)
+		})
+		if _, err = g.Check(); err != nil {
 			return nil, err
 		}
-		g.setRemote(remote)
 	}
 	return g, nil
 }
@@ -1,5 +1,7 @@
 package vcs
 
+import "io"
+
 // SvnReader implements the Repo interface for the Svn source control.
 type SvnReader struct {
 	Description
@@ -18,11 +20,12 @@ func NewSvnReader(remote, localPath string) (*SvnReader, error) {
 	r := &SvnReader{}
 	r.setDescription(remote, "", localPath, defaultSvnSchemes, Svn)
 	if err == nil { // Have a localPath FS repo, try to validate/upd remote
-		remote, _, err = SvnCheckRemote(r, remote)
-		if err != nil {
+		r.setRemoteCheck(func() (string, Resulter, error) {
+			return SvnCheckRemote(r, remote)
+		})
+		if _, err = r.Check(); err != nil {
 			return nil, err
 		}
-		r.setRemote(remote)
 	}
 	return r, nil
 }
@@ -37,7 +40,38 @@ func (r *SvnReader) RevRead(scope ReadScope, vcsRev ...Rev) ([]Revisioner, Resul
 	return SvnRevRead(r, scope, vcsRev...)
 }
 
+// RevLog support for svn reader
+func (r *SvnReader) RevLog(scope ReadScope, from, to Rev, max int, paths ...string) ([]Revisioner, Resulter, error) {
+	return SvnRevLog(r, scope, from, to, max, paths...)
+}
+
+// Diff support for svn reader
+func (r *SvnReader) Diff(from, to Rev, paths ...string) ([]*FileDiff, Resulter, error) {
+	return SvnDiff(r, from, to, paths...)
+}
+
+// Nested support for svn reader
+func (r *SvnReader) Nested() ([]*NestedRepo, Resulter, error) {
+	return SvnNested(r)
+}
+
+// Export support for svn reader
+func (r *SvnReader) Export(w io.Writer, rev Rev, opts *ExportOptions) (Resulter, error) {
+	return SvnExport(r, w, rev, opts)
+}
+
+// Refs support for svn reader
+func (r *SvnReader) Refs(l Location) ([]*Ref, Resulter, error) {
+	return SvnRefs(r, l)
+}
+
 // Exists support for svn reader
 func (r *SvnReader) Exists(l Location) (string, Resulter, error) {
 	return SvnExists(r, l)
 }
+
+// WCVersion support for svn reader, gives the revision state of the whole
+// working copy (mixed revisions, local modifications, etc)
+func (r *SvnReader) WCVersion() (*SvnWCVersion, Resulter, error) {
+	return SvnReadWCVersion(r)
+}
@@ -0,0 +1,39 @@
+package vcs
+
+// SvnStatusReader implements the StatusReader interface for the Svn source
+// control.
+type SvnStatusReader struct {
+	Description
+}
+
+// NewSvnStatusReader creates a new instance of SvnStatusReader. The remote
+// and local directories need to be passed in (the remote location should
+// include the branch for SVN, eg: .../trunk).
+func NewSvnStatusReader(remote, localPath string) (*SvnStatusReader, error) {
+	ltype, err := DetectVcsFromFS(localPath)
+	// Found a VCS other than Svn. Need to report an error.
+	if err == nil && ltype != Svn {
+		return nil, ErrWrongVCS
+	}
+	s := &SvnStatusReader{}
+	s.setDescription(remote, "", localPath, defaultSvnSchemes, Svn)
+	if err == nil { // Have a localPath FS repo, try to validate/upd remote
+		s.setRemoteCheck(func() (string, Resulter, error) {
+			return SvnCheckRemote(s, remote)
+		})
+		if _, err = s.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Status support for svn status reader
+func (s *SvnStatusReader) Status() (*Status, Resulter, error) {
+	return SvnStatus(s)
+}
+
+// Exists support for svn status reader
+func (s *SvnStatusReader) Exists(l Location) (string, Resulter, error) {
+	return SvnExists(s, l)
+}
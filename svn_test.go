@@ -3,11 +3,12 @@ package vcs
 import (
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
 )
 
-// To verify svn is working we perform intergration testing
-// with a known svn service.
+// To verify svn is working we perform integration testing
+// against a local fixture repo (see fixture_test.go).
 
 // Canary test to ensure SvnReader implements the VCS Reader interface.
 var _ Reader = &SvnReader{}
@@ -24,8 +25,11 @@ func TestSvn(t *testing.T) {
 			t.Error(err)
 		}
 	}()
+	fixture := newFixture(t, Svn, tempDir)
+	secondRev := fixture.revs["second"]
+	tipRev := fixture.revs["tip"]
 
-	svnGetter, err := NewSvnGetter("https://svn.code.sf.net/p/dvlnsvntest/code/trunk", "", tempDir+"/VCSTestRepo", false)
+	svnGetter, err := NewSvnGetter(fixture.remote, "", tempDir+"/VCSTestRepo", false)
 	if err != nil {
 		t.Fatalf("Unable to instantiate new SVN VCS reader, Err: %s", err)
 	}
@@ -35,7 +39,7 @@ func TestSvn(t *testing.T) {
 	}
 
 	// Check the basic getters.
-	if svnGetter.Remote() != "https://svn.code.sf.net/p/dvlnsvntest/code/trunk" {
+	if svnGetter.Remote() != fixture.remote {
 		t.Error("Remote not set properly")
 	}
 	if svnGetter.LocalRepoPath() != tempDir+"/VCSTestRepo" {
@@ -58,7 +62,7 @@ func TestSvn(t *testing.T) {
 	}
 
 	// Verify an incorrect remote is caught when NewSvnReader is used on an existing location
-	_, err = NewSvnReader("https://svn.code.sf.net/p/dvlnsvntest/code/unknownbranch", tempDir+"/VCSTestRepo")
+	_, err = NewSvnReader(fixture.url+"/branches/unknownbranch", tempDir+"/VCSTestRepo")
 	if err != ErrWrongRemote {
 		t.Fatal("ErrWrongRemote was not triggered for SVN")
 	}
@@ -78,7 +82,7 @@ func TestSvn(t *testing.T) {
 	//
 	// Test NewReader on existing checkout. This should simply provide a working
 	// instance without error based on looking at the local directory.
-	// svnReader, err := NewReader("https://svn.code.sf.net/p/dvlnsvntest/code/trunk", tempDir+"/VCSTestRepo")
+	// svnReader, err := NewReader(fixture.remote, tempDir+"/VCSTestRepo")
 	// if err != nil {
 	// 	t.Fatal(err)
 	// }
@@ -92,19 +96,19 @@ func TestSvn(t *testing.T) {
 	// }
 
 	// Change the version in the workspace to a previous version.
-	svnReader, err := NewSvnReader("https://svn.code.sf.net/p/dvlnsvntest/code/trunk", tempDir+"/VCSTestRepo")
+	svnReader, err := NewSvnReader(fixture.remote, tempDir+"/VCSTestRepo")
 	if err != nil {
 		t.Fatalf("Unable to instantiate new SVN VCS reader, Err: %s", err)
 	}
-	results, err := svnReader.RevSet("r2")
+	results, err := svnReader.RevSet("r" + secondRev)
 	if err != nil {
 		t.Errorf("Unable to update SVN repo version. Err was %s, results:\n%s", err, results)
 	}
 
 	// Use RevRead to verify we are on the right version.
 	v, _, err := svnReader.RevRead(CoreRev)
-	if string(v[0].Core()) != "2" {
-		t.Errorf("Error reading SVN version after revset/checkout, expected \"2\", found: %s", string(v[0].Core()))
+	if v[0].Core() != secondRev {
+		t.Errorf("Error reading SVN version after revset/checkout, expected \"%s\", found: %s", secondRev, v[0].Core())
 	}
 	if err != nil {
 		t.Error(err)
@@ -112,7 +116,7 @@ func TestSvn(t *testing.T) {
 
 	// Perform an update which should take up back to the latest version.
 	mirror := true
-	svnUpdater, err := NewSvnUpdater("https://svn.code.sf.net/p/dvlnsvntest/code/trunk", "", tempDir+"/VCSTestRepo", !mirror, RebaseFalse, nil)
+	svnUpdater, err := NewSvnUpdater(fixture.remote, "", tempDir+"/VCSTestRepo", !mirror, RebaseFalse, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -123,12 +127,49 @@ func TestSvn(t *testing.T) {
 
 	// Make sure we are on a newer version because of the update.
 	v, _, err = svnReader.RevRead(CoreRev)
-	if string(v[0].Core()) != "3" {
-		t.Errorf("Unexpected version found after update, should be \"3\", found: %s", string(v[0].Core()))
+	if v[0].Core() != tipRev {
+		t.Errorf("Unexpected version found after update, should be \"%s\", found: %s", tipRev, v[0].Core())
 	}
 	if err != nil {
 		t.Error(err)
 	}
+
+	// Read full data on a specific (older) revision
+	v, results, err = svnReader.RevRead(AllData, secondRev)
+	if err != nil {
+		t.Fatalf("Unable to read full SVN revision data, err: %s, results:\n%s", err, results)
+	}
+	if v[0].Core() != secondRev {
+		t.Errorf("Unexpected version found reading specific rev, should be \"%s\", found: %s", secondRev, v[0].Core())
+	}
+	if name, _ := v[0].UserInfo(Author); name == "" || v[0].TStamp(Author) == nil {
+		t.Error("Full SVN revision read did not populate the author or date")
+	}
+	if len(v[0].Branches()) != 1 || v[0].Branches()[0] != "trunk" {
+		t.Errorf("Full SVN revision read did not find the trunk branch, found: %v", v[0].Branches())
+	}
+	if v[0].Comment() != "second commit" {
+		t.Errorf("Full SVN revision read has the wrong comment, found: %q", v[0].Comment())
+	}
+
+	// The working copy rev (the tag commit) didn't change trunk, it's still
+	// that commit that is described (not the last commit changing trunk)
+	v, results, err = svnReader.RevRead(AllData)
+	if err != nil {
+		t.Fatalf("Unable to read full SVN revision data, err: %s, results:\n%s", err, results)
+	}
+	if v[0].Core() != tipRev || v[0].Comment() != "tag testtag" {
+		t.Errorf("Full SVN working copy revision read incorrect, expected: %s \"tag testtag\", found: %s %q", tipRev, v[0].Core(), v[0].Comment())
+	}
+
+	// Check the working copy version state, should be a clean single rev
+	wcVersion, _, err := svnReader.WCVersion()
+	if err != nil {
+		t.Fatalf("Unable to read SVN working copy version, err: %s", err)
+	}
+	if wcVersion.Mixed() || wcVersion.Modified || wcVersion.MaxRev != tipRev {
+		t.Errorf("Unexpected SVN working copy version state, found: %s", wcVersion)
+	}
 }
 
 func TestSvnExists(t *testing.T) {
@@ -154,6 +195,26 @@ func TestSvnExists(t *testing.T) {
 		t.Fatal("SVN repo exists check incorrectlyi indicating existence")
 	}
 
+	// Try remote Svn existence checks via a Getter on a local fixture repo
+	fixture := newFixture(t, Svn, tempDir)
+	svnGetter, err := NewSvnGetter(fixture.remote, "", tempDir+"/VCSTestRepo", false)
+	if err != nil {
+		t.Fatalf("Failed to initialize new Svn getter, error: %s", err)
+	}
+	path, _, err = svnGetter.Exists(Remote)
+	if err != nil || path != fixture.remote {
+		t.Fatalf("Failed to find remote repo that should exist (URL: %s), found: %s, error: %s", fixture.remote, path, err)
+	}
+	badurl := fixture.url + "/notexist"
+	svnGetter, err = NewSvnGetter(badurl, "", tempDir+"/VCSTestRepo", false)
+	if err != nil {
+		t.Fatalf("Failed to initialize \"bad\" Svn getter, init should work, error: %s", err)
+	}
+	path, _, err = svnGetter.Exists(Remote)
+	if err == nil || path != "" {
+		t.Fatalf("Failed to detect an error scanning for bad VCS location (loc: %s), found path: %s", badurl, path)
+	}
+
 	// Test NewReader when there's no local. This should simply provide a working
 	// instance without error based on looking at the remote localtion.
 	_, err = NewReader("https://svn.code.sf.net/p/dvlnsvntest/code/trunk", tempDir+"/VCSTestRepo")
@@ -161,9 +222,12 @@ func TestSvnExists(t *testing.T) {
 		t.Fatalf("Unable to instantiate new SVN VCS reader (using generic init), Err: %s", err)
 	}
 
+	// Remote scheme detection needs a real remote host
+	skipUnlessNetwork(t)
+
 	// Try remote Svn existence checks via a Getter
 	url1 := "svn.code.sf.net/p/dvlnsvntest/code/trunk"
-	svnGetter, err := NewSvnGetter(url1, "", tempDir, false)
+	svnGetter, err = NewSvnGetter(url1, "", tempDir, false)
 	if err != nil {
 		t.Fatalf("Failed to initialize new Svn getter, error: %s", err)
 	}
@@ -219,3 +283,64 @@ func TestSvnExists(t *testing.T) {
 		t.Fatalf("Unexpectedly found a repo when shouldn't have (URL: %s), found path: %s", badurl2, err)
 	}
 }
+
+// TestSvnParseVersion verifies parsing of svnversion output
+func TestSvnParseVersion(t *testing.T) {
+	v, err := svnParseVersion("4123:4168MS\n")
+	if err != nil {
+		t.Fatalf("Failed to parse svn version, err: %s", err)
+	}
+	if !v.Mixed() || v.MinRev != "4123" || v.MaxRev != "4168" || !v.Modified || !v.Switched || v.Partial {
+		t.Errorf("Parsed svn version incorrect, found: %+v", v)
+	}
+	if v.String() != "4123:4168MS" {
+		t.Errorf("Svn version stringer incorrect, found: %s", v)
+	}
+	v, err = svnParseVersion("1234")
+	if err != nil || v.Mixed() || v.Modified || v.String() != "1234" {
+		t.Errorf("Parsed clean svn version incorrect, found: %+v, err: %s", v, err)
+	}
+	if _, err = svnParseVersion("Unversioned directory"); err == nil {
+		t.Error("Parsing non-revision svnversion output should have failed")
+	}
+}
+
+// TestSvnURLRefs verifies branch and tag detection from svn URLs
+func TestSvnURLRefs(t *testing.T) {
+	root := "https://svn.example.com/repo"
+	tests := []struct {
+		url, branch, tag string
+	}{
+		{root + "/trunk", "trunk", ""},
+		{root + "/trunk/src", "trunk", ""},
+		{root + "/branches/1.x", "1.x", ""},
+		{root + "/tags/v1.0.0/docs", "", "v1.0.0"},
+		{root + "/other", "", ""},
+	}
+	for _, test := range tests {
+		branches, tags := svnURLRefs(test.url, root)
+		if (test.branch == "" && branches != nil) || (test.branch != "" && (len(branches) != 1 || string(branches[0]) != test.branch)) {
+			t.Errorf("Incorrect branch for svn URL %s, found: %v", test.url, branches)
+		}
+		if (test.tag == "" && tags != nil) || (test.tag != "" && (len(tags) != 1 || string(tags[0]) != test.tag)) {
+			t.Errorf("Incorrect tag for svn URL %s, found: %v", test.url, tags)
+		}
+	}
+}
+
+// TestParallelSvnGetUpd runs several Svn get/update/read cycles at the same
+// time to look for race issues (run with: go test -race)
+func TestParallelSvnGetUpd(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-svn-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Svn, tempDir)
+	var wg sync.WaitGroup
+	wg.Add(4)
+	for i := 1; i <= 4; i++ {
+		go runGetUpdRead(t, &wg, fixture.remote, Svn)
+	}
+	wg.Wait()
+}
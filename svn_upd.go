@@ -35,11 +35,12 @@ func NewSvnUpdater(remote, remoteName, localPath string, mirror bool, rebase Reb
 	}
 	u.setDescription(remote, remoteName, localPath, defaultSvnSchemes, Svn)
 	if err == nil { // Have a localPath FS repo, try to validate/upd remote
-		remote, _, err = SvnCheckRemote(u, remote)
-		if err != nil {
+		u.setRemoteCheck(func() (string, Resulter, error) {
+			return SvnCheckRemote(u, remote)
+		})
+		if _, err = u.Check(); err != nil {
 			return nil, err
 		}
-		u.setRemote(remote)
 	}
 	return u, nil
 }
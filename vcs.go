@@ -20,12 +20,12 @@
 package vcs
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"os"
-	"os/exec"
-	"strings"
 	"sync"
+	"time"
+
+	"github.com/dvln/out"
 )
 
 var (
@@ -45,6 +45,16 @@ var (
 	// configured endpoint.
 	ErrWrongRemote = errors.New("The Remote does not match the VCS endpoint")
 
+	// ErrTimeout is returned (wrapped) when a VCS cmd was killed because
+	// the deadline of the context it was run with passed
+	ErrTimeout = errors.New("VCS command timed out")
+
+	// ErrCanceled is returned (wrapped) when a VCS cmd was killed because
+	// the context it was run with was canceled
+	ErrCanceled = errors.New("VCS command canceled")
+
+	killWaitDelay = 5 * time.Second // max wait for cmd output after a kill
+
 	mutex   sync.Mutex // local mutex for goroutine data safety
 	gitTool = "git"    // default: use path to run whatever git they have
 	hgTool  = "hg"     // default: use path to run whatever hg they have
@@ -103,48 +113,67 @@ const (
 
 // run will execute the given cmd and args and return the results and
 // any error that occurred.  Params:
+//	ctx (context.Context): if canceled or past its deadline the cmd (and any
+//	                       child processes it started) is killed
 //	cmd (string): top level cmd (eg: "git" or "/path/to/git")
 //	args (...string): what will be space separated args, empty args ignored
 // Note: the args should not have strings like "-o blah", instead: "-o", "blah"
 // Returns:
 //	*Result: a single result structure (command run, raw output from cmd)
-//	error: a Go error if anything goes astray in the exec.Command()
-func run(cmd string, args ...string) (*Result, error) {
-	var finalArgs []string
-	for _, arg := range args {
-		if arg != "" {
-			finalArgs = append(finalArgs, arg)
-		}
-	}
-	output, err := exec.Command(cmd, finalArgs...).CombinedOutput()
-	result := newResult()
-	result.Cmd = fmt.Sprintf("%s %s", cmd, strings.Join(finalArgs, " "))
-	result.Output = string(output)
-	return result, err
+//	error: a *CmdError if the cmd fails to run or exits non-zero (classifying
+//	       the failure, eg: ErrAuth, see CmdError), if the ctx ended the cmd
+//	       a wrapped ErrTimeout or ErrCanceled is returned
+func run(ctx context.Context, cmd string, args ...string) (*Result, error) {
+	return runWithEnv(ctx, nil, cmd, args...)
 }
 
-// runFromLocalRepoDir attempts to cd into the pkg's workspace root dir (VCS root)
-// and run the command from that location (then it cd's back).  The command
-// result is returned along with any error (see run() for details).
-// WARNING: any SCM using this must avoid goroutines, Chdir() is not safe!!!
-// WARNING: instead use the run() routine with SCM opts to specify repo location
-func runFromLocalRepoDir(localRepoDir, cmd string, args ...string) (*Result, error) {
-	oldDir, err := os.Getwd()
-	if err != nil {
-		return nil, err
-	}
-	err = os.Chdir(localRepoDir)
-	if err != nil {
-		return nil, err
+// runWithEnv is identical to run() but any given environment settings
+// (eg: "HGPLAIN=1") are added to the current environment for the cmd run,
+// this is goroutine safe as the process environment is never modified
+func runWithEnv(ctx context.Context, env []string, cmd string, args ...string) (*Result, error) {
+	return runInDir(ctx, "", env, cmd, args...)
+}
+
+// runInDir is the core cmd runner, identical to runWithEnv() but if a dir
+// is given the cmd is run with that as its working directory (the working
+// dir of this process is never changed so this is goroutine safe).  Git and
+// bzr cmds are run in the C locale (see cLocaleEnv).  The cmd is run by the
+// Runner for the ctx (see SetRunner), os/exec by default.
+func runInDir(ctx context.Context, dir string, env []string, cmd string, args ...string) (*Result, error) {
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	defer os.Chdir(oldDir)
 	var finalArgs []string
 	for _, arg := range args {
 		if arg != "" {
 			finalArgs = append(finalArgs, arg)
 		}
 	}
-	return run(cmd, finalArgs...)
+	switch toolType(cmd) {
+	case Git, Bzr:
+		env = append(append([]string{}, cLocaleEnv...), env...)
+	}
+	return ctxRunner(ctx).Run(ctx, dir, env, cmd, finalArgs...)
+}
+
+// ctxErr maps a context that has ended into a wrapped ErrTimeout (deadline
+// passed) or ErrCanceled error for the given cmd, nil if ctx is still active
+func ctxErr(ctx context.Context, cmd string) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return out.WrapErrf(ErrTimeout, 4529, "VCS cmd timed out and was killed: %s", cmd)
+	case context.Canceled:
+		return out.WrapErrf(ErrCanceled, 4530, "VCS cmd was canceled and killed: %s", cmd)
+	}
+	return nil
+}
+
+// runFromLocalRepoDir runs the command with the pkg's workspace root dir (VCS
+// root) as the working directory of the cmd.  The command result is returned
+// along with any error (see run() for details).  The current working dir of
+// this process is not changed so this is safe to use from goroutines.
+func runFromLocalRepoDir(ctx context.Context, localRepoDir, cmd string, args ...string) (*Result, error) {
+	return runInDir(ctx, localRepoDir, nil, cmd, args...)
 }
 
 // detectVCSType tries to determine what VCS we are working with and can
@@ -159,7 +188,9 @@ func detectVCSType(remote, localPath string, vcsType ...Type) (Type, string, err
 	if vcsType != nil && len(vcsType) == 1 && vcsType[0] != NoVCS {
 		vtype = vcsType[0]
 	} else {
-		vtype, remote, err = detectVcsFromRemote(remote)
+		// the factories have no op context yet, lookupClient bounds any
+		// network access with its timeout
+		vtype, remote, err = detectVcsFromRemote(context.Background(), remote)
 
 		// If from the remote URL the VCS could not be detected, see if the
 		// localPath repo contains enough information to figure out the VCS.
@@ -22,7 +22,7 @@ func DetectVcsFromFS(vcsPath string) (Type, error) {
 	// Walk through each of the different VCS types to see if
 	// one can be detected. Do this is order of guessed popularity.
 	if _, err := os.Stat(vcsPath + seperator + ".git"); err == nil {
-		return Git, nil // standard git clone
+		return Git, nil // standard git clone (or worktree, .git is a file)
 	}
 	if _, err := os.Stat(vcsPath + seperator + ".svn"); err == nil {
 		return Svn, nil
@@ -28,6 +28,7 @@
 package vcs
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -37,13 +38,14 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type vcsInfo struct {
 	host     string
 	pattern  string
 	vcs      Type
-	addCheck func(m map[string]string) (Type, error)
+	addCheck func(ctx context.Context, m map[string]string) (Type, error)
 	regex    *regexp.Regexp
 }
 
@@ -97,6 +99,10 @@ var vcsList = []*vcsInfo{
 	},
 }
 
+// lookupClient is used for the network lookups of the VCS type, the timeout
+// bounds a lookup even if the context given has no deadline
+var lookupClient = &http.Client{Timeout: 30 * time.Second}
+
 func init() {
 	// Precompile the regular expressions used to check VCS locations.
 	for _, v := range vcsList {
@@ -117,7 +123,8 @@ func init() {
 // - error: if any issues, ErrCannotDetectVCS indicates normal but w/no match
 // Note: this routine does NOT always check if the actual repo exists (although
 // for some systems like bitbucket there are add-on routines thta do check)
-func detectVcsFromRemote(vcsURI string) (Type, string, error) {
+// Any network access is done with the given context (to cancel it).
+func detectVcsFromRemote(ctx context.Context, vcsURI string) (Type, string, error) {
 	//TODO: consider check for local path (ie: starts with '/'), to do this
 	//      -> convert to OS specific path (forward/backward/etc)
 	//      -> use this: DetectVcsFromFS(vcsPath string) (Type, error)
@@ -125,7 +132,7 @@ func detectVcsFromRemote(vcsURI string) (Type, string, error) {
 	//            if so then tests will need to be updated that check these Errs
 	//      eg: support NFS path in pkg/codebase search "/nfs/somedir/<name>"
 	//      eg: maybe support 'Rcs' type for codebase defn optionally (?)
-	t, e := detectVcsFromURL(vcsURI)
+	t, e := detectVcsFromURL(ctx, vcsURI)
 	if e == nil {
 		return t, vcsURI, nil
 	}
@@ -152,7 +159,11 @@ func detectVcsFromRemote(vcsURI string) (Type, string, error) {
 		u.RawQuery = u.RawQuery + "+go-get=1"
 	}
 	checkURL := u.String()
-	resp, err := http.Get(checkURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL, nil)
+	if err != nil {
+		return NoVCS, "", ErrCannotDetectVCS
+	}
+	resp, err := lookupClient.Do(req)
 	if err != nil {
 		return NoVCS, "", ErrCannotDetectVCS
 	}
@@ -172,7 +183,7 @@ func detectVcsFromRemote(vcsURI string) (Type, string, error) {
 // matches "known" VCS serving systems (eg: github) or, failing that,
 // known VCS extensions on the repo name (eg: .git, .bzr, .hg, .svn).
 // It will return the type of VCS found (or NoVCS and any error hit.
-func detectVcsFromURL(vcsURL string) (Type, error) {
+func detectVcsFromURL(ctx context.Context, vcsURL string) (Type, error) {
 	u, err := url.Parse(vcsURL)
 	if err != nil {
 		return "", err
@@ -218,7 +229,7 @@ func detectVcsFromURL(vcsURL string) (Type, error) {
 				info[name] = m[i]
 			}
 		}
-		t, err := v.addCheck(info)
+		t, err := v.addCheck(ctx, info)
 		if err != nil {
 			return "", ErrCannotDetectVCS
 		}
@@ -231,14 +242,14 @@ func detectVcsFromURL(vcsURL string) (Type, error) {
 }
 
 // Bitbucket provides an API for checking the VCS.
-func checkBitbucket(i map[string]string) (Type, error) {
+func checkBitbucket(ctx context.Context, i map[string]string) (Type, error) {
 	// The part of the response we care about.
 	var response struct {
 		SCM Type `json:"scm"`
 	}
 
 	u := expand(i, "https://api.bitbucket.org/1.0/repositories/{name}")
-	data, err := get(u)
+	data, err := get(ctx, u)
 	if err != nil {
 		return "", err
 	}
@@ -253,13 +264,13 @@ func checkBitbucket(i map[string]string) (Type, error) {
 // Google supports Git, Hg, and Svn. The SVN style is only
 // supported through their legacy setup at <project>.googlecode.com.
 // I wonder if anyone is actually using SVN support.
-func checkGoogle(i map[string]string) (Type, error) {
+func checkGoogle(ctx context.Context, i map[string]string) (Type, error) {
 
 	// To figure out which of the VCS types is used in Google Code you need
 	// to parse a web page and find it. Ugh. I mean... ugh.
 	var hack = regexp.MustCompile(`id="checkoutcmd">(hg|git|svn)`)
 
-	d, err := get(expand(i, "https://code.google.com/p/{project}/source/checkout?repo={repo}"))
+	d, err := get(ctx, expand(i, "https://code.google.com/p/{project}/source/checkout?repo={repo}"))
 	if err != nil {
 		return "", err
 	}
@@ -282,12 +293,16 @@ func checkGoogle(i map[string]string) (Type, error) {
 }
 
 // Expect a type key on i with the exact type detected from the regex.
-func checkURL(i map[string]string) (Type, error) {
+func checkURL(ctx context.Context, i map[string]string) (Type, error) {
 	return Type(i["type"]), nil
 }
 
-func get(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+func get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := lookupClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1,9 +1,17 @@
 package vcs
 
 import (
+	"context"
+	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/dvln/out"
 )
 
 func ExampleNewReader() {
@@ -31,7 +39,8 @@ func TestTypeSwitch(t *testing.T) {
 		}
 	}()
 
-	getter, err := NewSvnGetter("https://github.com/Masterminds/VCSTestRepo/trunk", "", tempDir+"/VCSTestRepo", false)
+	fixture := newFixture(t, Svn, tempDir)
+	getter, err := NewSvnGetter(fixture.remote, "", tempDir+"/VCSTestRepo", false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -45,3 +54,194 @@ func TestTypeSwitch(t *testing.T) {
 		t.Errorf("Not detecting VCS/repo reader switch from SVN to Git")
 	}
 }
+
+// TestRunContext verifies cmds (and their children) are killed when the
+// context they are run with times out or is canceled
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := run(ctx, "sh", "-c", "sleep 10; echo done")
+	if !out.IsError(err, ErrTimeout) {
+		t.Fatalf("Expected a timeout error running cmd, found: %v, result:\n%s", err, result)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Timed out cmd was not killed in time, took: %s", time.Since(start))
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = run(ctx, "sleep", "10")
+	if !out.IsError(err, ErrCanceled) {
+		t.Fatalf("Expected a canceled error running cmd, found: %v", err)
+	}
+
+	// a describer with no context set runs cmds without limits
+	d := &Description{}
+	if _, err = run(d.Context(), "true"); err != nil {
+		t.Errorf("Failed to run cmd with the default context, err: %s", err)
+	}
+}
+
+// TestRunResult verifies the details recorded in the result of a cmd run
+func TestRunResult(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-result-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	result, err := runInDir(context.Background(), tempDir, []string{"VCS_TEST=yes"}, "sh", "-c", "echo out $VCS_TEST; echo err >&2; exit 3", "")
+	if err == nil {
+		t.Fatal("Expected an error running a cmd that exits non-zero")
+	}
+	if len(result.Args) != 3 || result.Args[0] != "sh" || result.Args[2] != "echo out $VCS_TEST; echo err >&2; exit 3" {
+		t.Errorf("Result args incorrect, found: %q", result.Args)
+	}
+	if result.Dir != tempDir || len(result.Env) != 1 || result.Env[0] != "VCS_TEST=yes" {
+		t.Errorf("Result dir or env incorrect, found: %s, %v", result.Dir, result.Env)
+	}
+	// the order of the stdout and stderr lines in the combined output isn't
+	// guaranteed as the streams are read independently
+	if result.Stdout != "out yes\n" || result.Stderr != "err\n" || len(result.Output) != len("out yes\nerr\n") ||
+		!strings.Contains(result.Output, "out yes\n") || !strings.Contains(result.Output, "err\n") {
+		t.Errorf("Result output incorrect, stdout: %q, stderr: %q, output: %q", result.Stdout, result.Stderr, result.Output)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("Result exit code incorrect, found: %d", result.ExitCode)
+	}
+	if result.Start.IsZero() || result.Duration <= 0 {
+		t.Errorf("Result timing not recorded, start: %s, duration: %s", result.Start, result.Duration)
+	}
+
+	results := newResults()
+	results.add(result)
+	data, err := json.Marshal(results)
+	if err != nil {
+		t.Fatalf("Failed to serialize results to JSON, err: %s", err)
+	}
+	var decoded []*Result
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to deserialize results JSON, err: %s", err)
+	}
+	if len(decoded) != 1 || decoded[0].ExitCode != 3 || decoded[0].Stderr != "err\n" || decoded[0].Duration != result.Duration {
+		t.Errorf("Results JSON round trip incorrect, JSON: %s", data)
+	}
+}
+
+// TestRevReadStderr verifies revision reads only parse the stdout of the
+// cmds run, whatever the tool writes to stderr
+func TestRevReadStderr(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	fixture := newFixture(t, Git, tempDir)
+	localPath := filepath.Join(tempDir, "VCSTestRepo")
+	fixture.run(tempDir, nil, gitTool, "clone", "-q", fixture.remote, localPath)
+	reader, err := NewReader(fixture.remote, localPath, Git)
+	if err != nil {
+		t.Fatalf("Unable to instantiate new git reader, err: %s", err)
+	}
+	reader.SetRunner(envRunner{traceEnv})
+	revs, results, err := reader.RevRead(AllData, fixture.revs["second"])
+	if err != nil || len(revs) != 1 || revs[0].Core() != fixture.revs["second"] || revs[0].Comment() == "" {
+		t.Fatalf("Unable to read git revision with stderr output, err: %v, revs: %v, results:\n%s", err, revs, results)
+	}
+	if results.Last().Stderr == "" {
+		t.Fatalf("Expected git to write trace output to stderr, results:\n%s", results)
+	}
+	revs, results, err = reader.RevLog(AllData, fixture.revs["first"], fixture.revs["third"], 0)
+	if err != nil || len(revs) != 2 || revs[0].Core() != fixture.revs["third"] || revs[1].Core() != fixture.revs["second"] {
+		t.Errorf("Unable to read git revision log with stderr output, err: %v, revs: %v, results:\n%s", err, revs, results)
+	}
+}
+
+// TestParallelMixedVcs runs get, update and revision reads for all of the
+// VCS types at the same time, mostly useful with the race detector enabled
+// (go test -race) and to verify no backend changes the process working dir
+func TestParallelMixedVcs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping mixed VCS parallel testing in short mode.")
+		return
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tempDir, err := ioutil.TempDir("", "go-vcs-mixed-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	var wg sync.WaitGroup
+	for _, vcsType := range []Type{Git, Hg, Svn, Bzr} {
+		if !haveFixtureTools(vcsType) {
+			continue
+		}
+		fixture := newFixture(t, vcsType, tempDir)
+		wg.Add(2)
+		go runGetUpdRead(t, &wg, fixture.remote, vcsType)
+		go runGetUpdRead(t, &wg, fixture.remote, vcsType)
+	}
+	wg.Wait()
+	if newWd, err := os.Getwd(); err != nil || newWd != wd {
+		t.Errorf("Working dir changed by parallel VCS ops, was: %s, now: %s (err: %v)", wd, newWd, err)
+	}
+}
+
+// runGetUpdRead is for multiple goroutine testing of any VCS type, it does a
+// get, an update and a revision read in its own temp workspace (errors are
+// reported via t.Error() as t.Fatal() can't be used outside the test routine)
+func runGetUpdRead(t *testing.T, wg *sync.WaitGroup, remote string, vcsType Type) {
+	defer wg.Done()
+	tempDir, err := ioutil.TempDir("", "go-vcs-"+string(vcsType)+"-tests")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		err = os.RemoveAll(tempDir)
+		if err != nil {
+			t.Error("Failed to remove temp workspace that should have existed, err:", err)
+		}
+	}()
+	localPath := filepath.Join(tempDir, "VCSTestRepo")
+
+	getter, err := NewGetter(remote, "", localPath, false, vcsType)
+	if err != nil {
+		t.Errorf("Unable to instantiate new %s VCS getter, err: %s", vcsType, err)
+		return
+	}
+	results, err := getter.Get()
+	if err != nil {
+		t.Errorf("Unable to get %s repo, err: %s, results:\n%s", vcsType, err, results)
+		return
+	}
+
+	updater, err := NewUpdater(remote, "", localPath, false, RebaseFalse, nil, vcsType)
+	if err != nil {
+		t.Errorf("Unable to instantiate new %s VCS updater, err: %s", vcsType, err)
+		return
+	}
+	results, err = updater.Update()
+	if err != nil {
+		t.Errorf("Unable to update %s repo, err: %s, results:\n%s", vcsType, err, results)
+		return
+	}
+
+	reader, err := NewReader(remote, localPath, vcsType)
+	if err != nil {
+		t.Errorf("Unable to instantiate new %s VCS reader, err: %s", vcsType, err)
+		return
+	}
+	revs, results, err := reader.RevRead(AllData)
+	if err != nil {
+		t.Errorf("Unable to read %s repo revision, err: %s, results:\n%s", vcsType, err, results)
+		return
+	}
+	if len(revs) != 1 || revs[0].Core() == "" {
+		t.Errorf("Unexpected %s revision read results, found: %v", vcsType, revs)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright © 2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+// NewRevWriter returns a VCS RevWriter based on the given VCS description info
+// about the remote and workspace (dir/path) locations.  The RevWriter can be
+// used to make sure a Revision's tags, semvers and branch pointers exist in the
+// local clone (RevCommit) and then publish them to the remote (RevPush).  Only
+// DVCS's that can move refs locally are supported (git, hg), others return
+// ErrNotImplemented.  Params:
+//	remote (string): URL of remote repo (can be "", remoteName will set it)
+//	remoteName (string): "" or remote repo "name" (eg: "origin" is default for git)
+//	localPath (string): Directory for the local repo/clone to write revision data to
+//	vcsType (Type): optional; forcibly tell the pkg what the vcs type is (no auto-determination)
+func NewRevWriter(remote, remoteName, localPath string, vcsType ...Type) (RevWriter, error) {
+	vtype, remote, err := detectVCSType(remote, localPath, vcsType...)
+	if err != nil {
+		return nil, err
+	}
+	switch vtype {
+	case Git:
+		return NewGitRevWriter(remote, remoteName, localPath)
+	case Hg:
+		return NewHgRevWriter(remote, remoteName, localPath)
+	case Svn, Bzr:
+		return nil, ErrNotImplemented
+	}
+
+	// Should never fall through to here but just in case.
+	return nil, ErrCannotDetectVCS
+}
@@ -0,0 +1,89 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRevWriter verifies tags, semvers and branches written onto revisions
+// in a clone are published to the remote for each VCS type that supports
+// it (skipping those with no tools installed)
+func TestRevWriter(t *testing.T) {
+	for _, vcsType := range []Type{Git, Hg} {
+		vcsType := vcsType
+		t.Run(string(vcsType), func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "go-vcs-write-tests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+			fixture := newFixture(t, vcsType, tempDir)
+			localPath := filepath.Join(tempDir, "VCSTestRepo")
+			fixture.checkout(localPath)
+			writer, err := NewRevWriter(fixture.remote, "", localPath, vcsType)
+			if err != nil {
+				t.Fatalf("Unable to instantiate new %s VCS rev writer, err: %s", vcsType, err)
+			}
+			writer.SetRunner(envRunner{fixtureEnv})
+			// remoteRev returns the rev the given ref targets in the remote
+			remoteRev := func(ref string) Rev {
+				if vcsType == Git {
+					return Rev(fixture.run(fixture.path, nil, gitTool, "rev-parse", ref+"^{commit}"))
+				}
+				return Rev(fixture.run(fixture.path, nil, hgTool, "log", "-r", ref, "--template", "{node}"))
+			}
+			rev := NewRevision()
+			rev.SetCore(fixture.revs["second"])
+			rev.SetTags([]Rev{"vcs-test-tag"})
+			rev.SetSemVers([]Rev{"v99.0.0"})
+			branches := []Rev{"vcs-test-branch"}
+			if vcsType == Hg { // testbr1 is a named branch in the hg fixture
+				branches = append(branches, "testbr1")
+			}
+			rev.SetBranches(branches)
+			if results, err := writer.RevCommit(rev); err != nil {
+				t.Fatalf("Unable to commit %s revision data, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if results, err := writer.RevPush(); err != nil {
+				t.Fatalf("Unable to push %s revision data, err: %s, results:\n%s", vcsType, err, results)
+			}
+			for _, ref := range []string{"vcs-test-tag", "v99.0.0", "vcs-test-branch"} {
+				if found := remoteRev(ref); found != fixture.revs["second"] {
+					t.Errorf("Expected the pushed %s ref %s at %s, found: %s", vcsType, ref, fixture.revs["second"], found)
+				}
+			}
+			if vcsType == Hg { // a named branch doesn't get a bookmark
+				if bookmarks := fixture.run(localPath, nil, hgTool, "bookmarks", "--template", "{bookmark}\n"); strings.Contains(bookmarks, "testbr1") {
+					t.Errorf("Expected no bookmark for the named hg branch testbr1, found: %s", bookmarks)
+				}
+			}
+			if results, err := writer.RevPush(); err != nil || len(results.All()) != 0 {
+				t.Errorf("Expected nothing to push for %s a second time, err: %v, results:\n%s", vcsType, err, results)
+			}
+
+			// moving the tag and adding a branch only moves refs (for hg a
+			// bookmark push with no changesets, which exits 1)
+			rev = NewRevision()
+			rev.SetCore(fixture.revs["third"])
+			if vcsType == Git {
+				rev.SetTags([]Rev{"vcs-test-tag"})
+			}
+			rev.SetBranches([]Rev{"vcs-test-branch2"})
+			if results, err := writer.RevCommit(rev); err != nil {
+				t.Fatalf("Unable to commit %s revision data, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if results, err := writer.RevPush(); err != nil {
+				t.Fatalf("Unable to push %s revision data, err: %s, results:\n%s", vcsType, err, results)
+			}
+			if found := remoteRev("vcs-test-branch2"); found != fixture.revs["third"] {
+				t.Errorf("Expected the pushed %s branch at third, found: %s", vcsType, found)
+			}
+			if found := remoteRev("vcs-test-tag"); vcsType == Git && found != fixture.revs["third"] {
+				t.Errorf("Expected the moved git tag at third, found: %s", found)
+			}
+		})
+	}
+}